@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/felipemalacarne/etheria/internal/game/engine"
+	"github.com/felipemalacarne/etheria/internal/game/mapgen"
+)
+
+func main() {
+	defaults := mapgen.DefaultConfig(1, engine.DefaultMapWidth, engine.DefaultMapHeight)
+
+	seed := flag.Int64("seed", defaults.Seed, "generator seed; the same seed and settings give the same map")
+	width := flag.Int("width", defaults.Width, "map width in tiles")
+	height := flag.Int("height", defaults.Height, "map height in tiles")
+	towns := flag.Int("towns", 0, "number of towns joined by roads (default scales with the map area)")
+	trees := flag.Int("trees", -1, "number of trees to chop (default scales with the map area)")
+	straight := flag.Bool("straight", false, "disable diagonal movement")
+	dir := flag.String("dir", ".", "directory to write the map to; list it in the server's maps.json to host it")
+	force := flag.Bool("force", false, "overwrite an existing map")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] <name>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	config := mapgen.DefaultConfig(*seed, *width, *height)
+	config.Diagonal = !*straight
+	if *towns > 0 {
+		config.Towns = *towns
+	}
+	if *trees >= 0 {
+		config.Trees = *trees
+	}
+
+	mapData, err := mapgen.Generate(config)
+	if err != nil {
+		log.Fatalf("generate map: %v", err)
+	}
+	report := engine.AnalyzeMap(mapData, engine.DefaultChunkSizeTiles)
+	if report.Errors() > 0 {
+		for _, issue := range report.Issues {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", issue.Severity, issue.Message)
+		}
+		log.Fatalf("generated map is not playable")
+	}
+
+	path := filepath.Join(*dir, flag.Arg(0)+".json")
+	if _, err := os.Stat(path); err == nil && !*force {
+		log.Fatalf("%s already exists (use -force to overwrite)", path)
+	} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Fatalf("stat %s: %v", path, err)
+	}
+
+	raw, err := json.Marshal(mapData)
+	if err != nil {
+		log.Fatalf("encode map: %v", err)
+	}
+	if err := os.MkdirAll(*dir, 0o755); err != nil {
+		log.Fatalf("create %s: %v", *dir, err)
+	}
+	if err := os.WriteFile(path, raw, 0o644); err != nil {
+		log.Fatalf("write map: %v", err)
+	}
+
+	fmt.Printf("wrote %s: %dx%d, seed %d, %d towns, %d trees, %d walkable tiles\n",
+		path, mapData.Width, mapData.Height, config.Seed, len(mapData.Spawns)-1, len(mapData.Objects), report.Walkable)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/felipemalacarne/etheria/internal/game/engine"
+)
+
+func main() {
+	chunkSize := flag.Int("chunk", engine.DefaultChunkSizeTiles, "chunk edge in tiles for chunk statistics")
+	strict := flag.Bool("strict", false, "treat warnings as errors")
+	links := flag.Bool("links", false, "check that portals lead to spawn points on the other maps given")
+	verbose := flag.Bool("v", false, "print every walkable area")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [-chunk n] [-strict] [-links] [-v] <map>...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	failed := false
+	worlds := engine.NewWorldManager()
+	for _, path := range flag.Args() {
+		mapData, err := engine.LoadMapData(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: error: %v\n", path, err)
+			failed = true
+			continue
+		}
+
+		report := engine.AnalyzeMap(mapData, *chunkSize)
+		printReport(path, report, *verbose)
+		if report.Errors() > 0 || (*strict && len(report.Issues) > 0) {
+			failed = true
+		}
+
+		name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		if _, err := worlds.AddMap(name, mapData); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: error: %v\n", path, err)
+			failed = true
+		}
+	}
+
+	if *links {
+		if err := worlds.ValidatePortals(); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			failed = true
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func printReport(path string, report engine.MapReport, verbose bool) {
+	fmt.Printf("%s: %dx%d, %d walkable tiles in %d areas, %d reachable from the default spawn\n",
+		path, report.Width, report.Height, report.Walkable, len(report.Areas), report.Main.Tiles)
+
+	chunks := report.Chunks
+	fmt.Printf("  chunks: %d of %dx%d tiles, %d fully blocked, walkable tiles per chunk min %d / avg %.1f / max %d\n",
+		chunks.Chunks, chunks.Size, chunks.Size, chunks.Blocked, chunks.MinWalkable, chunks.AvgWalkable, chunks.MaxWalkable)
+
+	if verbose {
+		for _, area := range report.Areas {
+			fmt.Printf("  area at %d,%d: %d tiles\n", area.Origin.X, area.Origin.Y, area.Tiles)
+		}
+	}
+
+	// Issues go to stderr, prefixed with the map so they still read on their
+	// own when stdout is discarded.
+	for _, issue := range report.Issues {
+		if issue.Tile != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s: %d,%d: %s\n", path, issue.Severity, issue.Tile.X, issue.Tile.Y, issue.Message)
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "%s: %s: %s\n", path, issue.Severity, issue.Message)
+	}
+}
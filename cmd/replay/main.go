@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/felipemalacarne/etheria/internal/game/engine"
+)
+
+func main() {
+	verbose := flag.Bool("v", false, "print every final player position")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [-v] <recording>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	file, err := os.Open(flag.Arg(0))
+	if err != nil {
+		log.Fatalf("open recording: %v", err)
+	}
+	defer file.Close()
+
+	result, err := engine.Replay(file)
+	if err != nil {
+		log.Fatalf("replay failed: %v", err)
+	}
+
+	fmt.Printf("replayed %d ticks, %d players at end\n", result.Ticks, len(result.Players))
+	if *verbose {
+		for _, player := range result.Players {
+			fmt.Printf("  %s (%.2f, %.2f)\n",
+				player.ID,
+				float64(player.X)/engine.PositionScale,
+				float64(player.Y)/engine.PositionScale,
+			)
+		}
+	}
+
+	if len(result.Mismatches) == 0 {
+		fmt.Println("all checksums match")
+		return
+	}
+
+	for _, mismatch := range result.Mismatches {
+		fmt.Printf("tick %d: recorded %016x, replayed %016x\n", mismatch.Tick, mismatch.Recorded, mismatch.Replayed)
+	}
+	fmt.Printf("%d of %d ticks diverged (first at tick %d)\n", len(result.Mismatches), result.Ticks, result.Mismatches[0].Tick)
+	os.Exit(1)
+}
@@ -2,19 +2,25 @@ package main
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	appauth "github.com/felipemalacarne/etheria/internal/app/auth"
 	"github.com/felipemalacarne/etheria/internal/app/auth/password"
 	"github.com/felipemalacarne/etheria/internal/domain/account"
+	"github.com/felipemalacarne/etheria/internal/domain/character"
 	"github.com/felipemalacarne/etheria/internal/game/engine"
 	"github.com/felipemalacarne/etheria/internal/infrastructure/id"
 	filerepo "github.com/felipemalacarne/etheria/internal/infrastructure/repositories/file"
@@ -25,8 +31,15 @@ import (
 const (
 	defaultPort       = "8080"
 	defaultTickMs     = 50
-	defaultMapPath    = "shared/maps/basic.json"
+	defaultMapDir     = "shared/maps"
+	defaultMapName    = "basic"
+	mapManifestName   = "maps.json"
 	defaultUserDBPath = "shared/data/users.json"
+	defaultCharDBPath = "shared/data/characters.json"
+	defaultNPCPath    = "shared/data/npcs.json"
+	defaultSaveSecs   = 30
+	defaultSnapDir    = "shared/data/snapshots"
+	defaultSnapSecs   = 60
 	shutdownTimeout   = 5 * time.Second
 	readHeaderTimeout = 5 * time.Second
 )
@@ -35,11 +48,10 @@ func main() {
 	addr := ":" + getenv("PORT", defaultPort)
 	tickRate := time.Duration(getenvInt("TICK_MS", defaultTickMs)) * time.Millisecond
 
-	mapPath := getenv("MAP_PATH", defaultMapPath)
-	mapData, err := engine.LoadMapData(mapPath)
-	if err != nil {
-		log.Printf("map load failed (%s): %v", mapPath, err)
-		mapData = engine.DefaultMapData(engine.DefaultMapWidth, engine.DefaultMapHeight)
+	worlds, mapPaths := loadWorlds(mapSettings())
+	reloader := newMapReloader(worlds, mapPaths)
+	if err := worlds.ValidatePortals(); err != nil {
+		log.Fatalf("invalid portals: %v", err)
 	}
 
 	userRepo, err := filerepo.NewUserRepository(getenv("USER_DB_PATH", defaultUserDBPath))
@@ -53,21 +65,64 @@ func main() {
 		id.NewUUIDGenerator(),
 	)
 
-	world := engine.NewWorld(mapData)
-	server := websocket.NewServer(world, authService)
+	characterRepo, err := filerepo.NewCharacterRepository(getenv("CHARACTER_DB_PATH", defaultCharDBPath))
+	if err != nil {
+		log.Fatalf("failed to load character store: %v", err)
+	}
+
+	seed := int64(getenvInt("WORLD_SEED", int(time.Now().UnixNano())))
+	for _, name := range worlds.Names() {
+		world, _ := worlds.World(name)
+		world.SetMaxPathNodes(getenvInt("PATH_MAX_NODES", engine.DefaultMaxPathNodes))
+		world.SetHierarchicalPathMinTiles(getenvInt("PATH_HIERARCHICAL_MIN_TILES", engine.DefaultHierarchicalMinTiles))
+		world.SetSeed(seed)
+	}
+	spawnNPCs(worlds, getenv("NPC_DATA_PATH", defaultNPCPath))
+
+	var snapshots *filerepo.WorldSnapshotStore
+	if snapshotDir := getenv("WORLD_SNAPSHOT_DIR", defaultSnapDir); snapshotDir != "" {
+		snapshots = filerepo.NewWorldSnapshotStore(snapshotDir, getenvInt("WORLD_SNAPSHOT_KEEP", filerepo.DefaultSnapshotsKept))
+		restoreSnapshot(context.Background(), worlds, snapshots, characterRepo)
+	}
+
+	server := websocket.NewServer(worlds, authService, characterRepo)
 	loop := engine.NewLoop(tickRate, func(tick int64, delta time.Duration) {
-		world.Step(delta.Seconds())
-		if world.DrainDirty() {
-			server.BroadcastState(tick)
+		server.ApplyMapChanges(worlds.Step(delta.Seconds()))
+		server.BroadcastState(tick)
+	})
+	if recordPath := getenv("RECORD_PATH", ""); recordPath != "" {
+		file, err := os.Create(recordPath)
+		if err != nil {
+			log.Fatalf("failed to create recording: %v", err)
 		}
+		// Recordings cover a single World, so only the default map is
+		// recorded.
+		recordMap := worlds.DefaultMap()
+		world, _ := worlds.World(recordMap)
+		mapData, _ := worlds.MapData(recordMap)
+		recorder := engine.NewRecorder(file)
+		world.StartRecording(recorder, mapData)
+		defer func() {
+			world.StopRecording()
+			if err := recorder.Close(); err != nil {
+				log.Printf("recording error: %v", err)
+			}
+		}()
+		log.Printf("recording %s world input to %s", recordMap, recordPath)
+	}
+	loop.SetOverrunHandler(func(overrun engine.TickOverrun) {
+		log.Printf("tick %d overran: took %s (budget %s)", overrun.Tick, overrun.Duration, tickRate)
 	})
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/ws", server.HandleWS)
-	mux.HandleFunc("/players", withCORS(handlePlayers(world, authService)))
-	mux.HandleFunc("/map", withCORS(handleMap(mapData)))
+	mux.HandleFunc("/players", withCORS(handlePlayers(worlds, authService)))
+	mux.HandleFunc("/map", withCORS(handleMap(worlds)))
 	mux.HandleFunc("/auth/login", withCORS(handleAuthLogin(authService)))
 	mux.HandleFunc("/auth/register", withCORS(handleAuthRegister(authService)))
+	if adminToken := getenv("ADMIN_TOKEN", ""); adminToken != "" {
+		mux.HandleFunc("/admin/maps/reload", handleMapReload(reloader, adminToken))
+	}
 
 	httpServer := &http.Server{
 		Addr:              addr,
@@ -79,6 +134,11 @@ func main() {
 	defer stop()
 
 	go loop.Start(ctx)
+	go runAutosave(ctx, server, time.Duration(getenvInt("CHARACTER_SAVE_SECS", defaultSaveSecs))*time.Second)
+	go reloader.watch(ctx, time.Duration(getenvInt("MAP_WATCH_SECS", 0))*time.Second)
+	if snapshots != nil {
+		go runSnapshots(ctx, worlds, snapshots, time.Duration(getenvInt("WORLD_SNAPSHOT_SECS", defaultSnapSecs))*time.Second)
+	}
 
 	serverErr := make(chan error, 1)
 	go func() {
@@ -103,6 +163,324 @@ func main() {
 	}
 
 	server.Close()
+	if snapshots != nil {
+		if err := saveSnapshot(worlds, snapshots); err != nil {
+			log.Printf("world snapshot failed: %v", err)
+		}
+	}
+}
+
+// mapSettings reads the map directory, the MAP_PATH of a single-map setup
+// and the default map name from the environment. MAP_PATH names the default
+// map and its directory stands in for MAP_DIR, so it cannot be combined with
+// either of the other two.
+func mapSettings() (dir, mapPath, defaultMap string) {
+	mapPath = getenv("MAP_PATH", "")
+	if mapPath == "" {
+		return getenv("MAP_DIR", defaultMapDir), "", getenv("DEFAULT_MAP", "")
+	}
+
+	for _, key := range []string{"MAP_DIR", "DEFAULT_MAP"} {
+		if _, ok := os.LookupEnv(key); ok {
+			log.Fatalf("MAP_PATH and %s are both set", key)
+		}
+	}
+
+	return filepath.Dir(mapPath), mapPath, ""
+}
+
+// loadWorlds hosts the maps listed in dir's manifest, or only mapPath when
+// there is no manifest. Without either it falls back to a generated map. It
+// also returns the file each hosted map was loaded from.
+func loadWorlds(dir, mapPath, defaultMap string) (*engine.WorldManager, map[string]string) {
+	worlds := engine.NewWorldManager()
+	loaded := make(map[string]string)
+
+	manifestPath := filepath.Join(dir, mapManifestName)
+	manifest, err := engine.LoadMapManifest(manifestPath)
+	switch {
+	case err == nil:
+	case errors.Is(err, os.ErrNotExist) && mapPath != "":
+		name := strings.TrimSuffix(filepath.Base(mapPath), filepath.Ext(mapPath))
+		manifest = engine.MapManifest{
+			Default: name,
+			Maps:    []engine.MapManifestEntry{{Name: name, Path: mapPath}},
+		}
+	case errors.Is(err, os.ErrNotExist):
+		log.Printf("no map manifest at %s, using a generated map", manifestPath)
+		worlds.AddMap(defaultMapName, engine.DefaultMapData(engine.DefaultMapWidth, engine.DefaultMapHeight))
+		return worlds, loaded
+	default:
+		log.Fatalf("map manifest %s: %v", manifestPath, err)
+	}
+
+	if mapPath != "" {
+		entry, ok := manifest.EntryForPath(mapPath)
+		if !ok {
+			log.Fatalf("MAP_PATH %s is not listed in %s", mapPath, manifestPath)
+		}
+		defaultMap = entry.Name
+	}
+	if defaultMap == "" {
+		defaultMap = manifest.Default
+	}
+
+	for _, entry := range manifest.Maps {
+		mapData, err := engine.LoadMapData(entry.Path)
+		if err != nil {
+			log.Printf("map load failed (%s): %v", entry.Path, err)
+			continue
+		}
+		if _, err := worlds.AddMap(entry.Name, mapData); err != nil {
+			log.Printf("map load failed (%s): %v", entry.Path, err)
+			continue
+		}
+		loaded[entry.Name] = entry.Path
+	}
+
+	if len(worlds.Names()) == 0 {
+		log.Printf("no maps loaded from %s, using a generated map", manifestPath)
+		worlds.AddMap(defaultMap, engine.DefaultMapData(engine.DefaultMapWidth, engine.DefaultMapHeight))
+	}
+	if err := worlds.SetDefaultMap(defaultMap); err != nil {
+		log.Printf("default map: %v, using %s", err, worlds.DefaultMap())
+	}
+
+	return worlds, loaded
+}
+
+// mapReloader reloads hosted maps from the files they were loaded from, on
+// request or when a file's modification time changes.
+type mapReloader struct {
+	worlds   *engine.WorldManager
+	paths    map[string]string
+	mu       sync.Mutex
+	modTimes map[string]time.Time
+}
+
+func newMapReloader(worlds *engine.WorldManager, paths map[string]string) *mapReloader {
+	reloader := &mapReloader{
+		worlds:   worlds,
+		paths:    paths,
+		modTimes: make(map[string]time.Time, len(paths)),
+	}
+	for name, path := range paths {
+		if info, err := os.Stat(path); err == nil {
+			reloader.modTimes[name] = info.ModTime()
+		}
+	}
+
+	return reloader
+}
+
+// reload reads the map's file again and hands it to the WorldManager, which
+// swaps it in on the next tick.
+func (r *mapReloader) reload(name string) error {
+	path, ok := r.paths[name]
+	if !ok {
+		return fmt.Errorf("map %q was not loaded from a file", name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if info, err := os.Stat(path); err == nil {
+		r.modTimes[name] = info.ModTime()
+	}
+	mapData, err := engine.LoadMapData(path)
+	if err != nil {
+		return fmt.Errorf("map %q: %w", name, err)
+	}
+
+	return r.worlds.ReloadMap(name, mapData)
+}
+
+// watch polls the map files every interval and reloads the ones that
+// changed. A non-positive interval disables it.
+func (r *mapReloader) watch(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, name := range r.changed() {
+				if err := r.reload(name); err != nil {
+					log.Printf("map reload failed: %v", err)
+					continue
+				}
+				log.Printf("map %s reloaded from %s", name, r.paths[name])
+			}
+		}
+	}
+}
+
+func (r *mapReloader) changed() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var names []string
+	for name, path := range r.paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if !info.ModTime().Equal(r.modTimes[name]) {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// spawnNPCs loads NPC definitions and spawns each on its map, the default
+// map when a definition names none.
+func spawnNPCs(worlds *engine.WorldManager, path string) {
+	npcs, err := engine.LoadNPCDefinitions(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("npc load failed (%s): %v", path, err)
+		}
+		return
+	}
+
+	byMap := make(map[string][]engine.NPCDefinition)
+	for _, npc := range npcs {
+		mapName := npc.Map
+		if mapName == "" {
+			mapName = worlds.DefaultMap()
+		}
+		byMap[mapName] = append(byMap[mapName], npc)
+	}
+
+	for mapName, defs := range byMap {
+		world, ok := worlds.World(mapName)
+		if !ok {
+			log.Printf("npc spawn failed (%s): unknown map %q", path, mapName)
+			continue
+		}
+		if err := world.SpawnNPCs(defs); err != nil {
+			log.Printf("npc spawn failed (%s, map %s): %v", path, mapName, err)
+		}
+	}
+}
+
+// restoreSnapshot restores the newest valid world snapshot. Players are not
+// put back on the map; their snapshotted positions are saved as their
+// character state instead, unless it was saved after the snapshot, so they
+// resume there when they reconnect.
+func restoreSnapshot(ctx context.Context, worlds *engine.WorldManager, snapshots *filerepo.WorldSnapshotStore, characters character.Repository) {
+	var snapshot engine.WorldSnapshot
+	path, ok, err := snapshots.Latest(func(data []byte) error {
+		snapshot = engine.WorldSnapshot{}
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			return err
+		}
+		if snapshot.Version != engine.SnapshotVersion {
+			return fmt.Errorf("unsupported snapshot version %d", snapshot.Version)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("world snapshot load failed: %v", err)
+		return
+	}
+	if !ok {
+		return
+	}
+
+	if err := worlds.Restore(snapshot); err != nil {
+		log.Printf("world snapshot partly restored: %v", err)
+	}
+
+	var states []character.State
+	for _, saved := range snapshot.Maps {
+		for _, entity := range saved.Entities {
+			if entity.Kind != engine.EntityPlayer {
+				continue
+			}
+			state, found, err := characters.Get(ctx, entity.PlayerID)
+			if err != nil {
+				log.Printf("character load failed (%s): %v", entity.PlayerID, err)
+				continue
+			}
+			if found && !state.UpdatedAt.Before(snapshot.SavedAt) {
+				continue
+			}
+			states = append(states, character.State{
+				UserID:    entity.PlayerID,
+				Map:       saved.Name,
+				X:         entity.X,
+				Y:         entity.Y,
+				UpdatedAt: snapshot.SavedAt,
+			})
+		}
+	}
+	if err := characters.SaveMany(ctx, states); err != nil {
+		log.Printf("character restore failed: %v", err)
+	}
+
+	log.Printf("world restored from %s (saved %s)", path, snapshot.SavedAt.Format(time.RFC3339))
+}
+
+// runSnapshots saves a world snapshot every interval. The copy is taken
+// under each World's lock; encoding and writing happen here, off the tick
+// goroutine. A non-positive interval disables it.
+func runSnapshots(ctx context.Context, worlds *engine.WorldManager, snapshots *filerepo.WorldSnapshotStore, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := saveSnapshot(worlds, snapshots); err != nil {
+				log.Printf("world snapshot failed: %v", err)
+			}
+		}
+	}
+}
+
+// saveSnapshot encodes a snapshot of every world and writes it to the store.
+func saveSnapshot(worlds *engine.WorldManager, snapshots *filerepo.WorldSnapshotStore) error {
+	snapshot := worlds.Snapshot()
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return snapshots.Save(snapshot.SavedAt, data)
+}
+
+func runAutosave(ctx context.Context, server *websocket.Server, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := server.SaveCharacters(ctx); err != nil {
+				log.Printf("character autosave failed: %v", err)
+			}
+		}
+	}
 }
 
 func getenv(key, fallback string) string {
@@ -137,7 +515,7 @@ type playerList struct {
 	Players []playerInfo `json:"players"`
 }
 
-func handlePlayers(world *engine.World, authService *appauth.Service) http.HandlerFunc {
+func handlePlayers(worlds *engine.WorldManager, authService *appauth.Service) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
 			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -156,6 +534,11 @@ func handlePlayers(world *engine.World, authService *appauth.Service) http.Handl
 			return
 		}
 
+		world, _, ok := worlds.PlayerWorld(user.ID)
+		if !ok {
+			http.Error(w, "player not found", http.StatusNotFound)
+			return
+		}
 		players, ok := world.SnapshotPlayersInChunkRadius(user.ID, websocket.ChunkRadius, websocket.ChunkSizeTiles)
 		if !ok {
 			http.Error(w, "player not found", http.StatusNotFound)
@@ -175,17 +558,59 @@ func handlePlayers(world *engine.World, authService *appauth.Service) http.Handl
 	}
 }
 
-func handleMap(mapData engine.MapData) http.HandlerFunc {
+// handleMap serves the map named by the "name" query parameter, the default
+// map when it is missing.
+func handleMap(worlds *engine.WorldManager) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
 			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 
+		name := r.URL.Query().Get("name")
+		if name == "" {
+			name = worlds.DefaultMap()
+		}
+		mapData, ok := worlds.MapData(name)
+		if !ok {
+			http.Error(w, "map not found", http.StatusNotFound)
+			return
+		}
+
 		writeJSON(w, mapData)
 	}
 }
 
+// handleMapReload reloads the map named by the "name" query parameter from
+// disk. Callers authenticate with "Authorization: Bearer <ADMIN_TOKEN>".
+func handleMapReload(reloader *mapReloader, adminToken string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		name := r.URL.Query().Get("name")
+		if _, ok := reloader.worlds.World(name); !ok {
+			http.Error(w, "map not found", http.StatusNotFound)
+			return
+		}
+		if err := reloader.reload(name); err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+
+		log.Printf("map %s reloaded by admin request", name)
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
 type authRequest struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
@@ -0,0 +1,10 @@
+package character
+
+import "context"
+
+// Repository defines persistence operations for character state.
+type Repository interface {
+	Get(ctx context.Context, userID string) (State, bool, error)
+	Save(ctx context.Context, state State) error
+	SaveMany(ctx context.Context, states []State) error
+}
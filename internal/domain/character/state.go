@@ -0,0 +1,14 @@
+package character
+
+import "time"
+
+// State is the persisted game state of a user's character. Map names the map
+// the character is on; positions are in scaled world units, matching
+// engine.Player.
+type State struct {
+	UserID    string
+	Map       string
+	X         int
+	Y         int
+	UpdatedAt time.Time
+}
@@ -0,0 +1,259 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+)
+
+type MapIssueSeverity string
+
+const (
+	MapIssueError   MapIssueSeverity = "error"
+	MapIssueWarning MapIssueSeverity = "warning"
+)
+
+// MapIssue is a problem found by AnalyzeMap, located at Tile when it has a
+// single place on the map.
+type MapIssue struct {
+	Severity MapIssueSeverity
+	Message  string
+	Tile     *MapPoint
+}
+
+// MapArea is a set of walkable tiles connected by the moves the pathfinder
+// allows. Origin is its first tile in row order.
+type MapArea struct {
+	Origin MapPoint
+	Tiles  int
+}
+
+// ChunkStats summarises how walkable tiles spread over chunks of Size tiles.
+type ChunkStats struct {
+	Size        int
+	Chunks      int
+	Blocked     int
+	MinWalkable int
+	MaxWalkable int
+	AvgWalkable float64
+}
+
+// MapReport is the result of AnalyzeMap. Areas lists every walkable area,
+// largest first; the one players spawn into is Main.
+type MapReport struct {
+	Width    int
+	Height   int
+	Walkable int
+	Areas    []MapArea
+	Main     MapArea
+	Chunks   ChunkStats
+	Issues   []MapIssue
+}
+
+// Errors counts the issues that make the map unplayable.
+func (r MapReport) Errors() int {
+	count := 0
+	for _, issue := range r.Issues {
+		if issue.Severity == MapIssueError {
+			count += 1
+		}
+	}
+
+	return count
+}
+
+// AnalyzeMap checks that a valid map is playable: every tile id has a
+// definition, and every spawn point, portal and object can be reached from
+// the area the default spawn puts players in. Walkable areas cut off from it
+// are reported as warnings, since they may be scenery. chunkSize is the
+// chunk edge in tiles, DefaultChunkSizeTiles when not positive.
+func AnalyzeMap(data MapData, chunkSize int) MapReport {
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSizeTiles
+	}
+
+	w := NewWorld(data)
+	report := MapReport{Width: w.mapWidth, Height: w.mapHeight}
+	report.Issues = append(report.Issues, w.undefinedTileIssues()...)
+
+	areaOf := make([]int, w.mapWidth*w.mapHeight)
+	for i := range areaOf {
+		areaOf[i] = -1
+	}
+	for y := 0; y < w.mapHeight; y += 1 {
+		for x := 0; x < w.mapWidth; x += 1 {
+			index := w.tileIndex(x, y)
+			if !w.tiles.walkable[index] || areaOf[index] >= 0 {
+				continue
+			}
+			tiles := w.floodArea(tilePoint{X: x, Y: y}, len(report.Areas), areaOf)
+			report.Areas = append(report.Areas, MapArea{Origin: MapPoint{X: x, Y: y}, Tiles: tiles})
+			report.Walkable += tiles
+		}
+	}
+	if len(report.Areas) == 0 {
+		report.Issues = append(report.Issues, MapIssue{Severity: MapIssueError, Message: "map has no walkable tiles"})
+		report.Chunks = w.chunkStats(chunkSize)
+		return report
+	}
+
+	anchor, anchorName := w.spawnAnchor()
+	main := areaOf[w.tileIndex(anchor.X, anchor.Y)]
+	report.Main = report.Areas[main]
+
+	for _, spawn := range data.Spawns {
+		if areaOf[w.tileIndex(spawn.X, spawn.Y)] != main {
+			report.Issues = append(report.Issues, MapIssue{
+				Severity: MapIssueError,
+				Message:  fmt.Sprintf("spawn point %q cannot be reached from %s", spawn.Name, anchorName),
+				Tile:     &MapPoint{X: spawn.X, Y: spawn.Y},
+			})
+		}
+	}
+	for i, portal := range data.Portals {
+		if areaOf[w.tileIndex(portal.X, portal.Y)] != main {
+			report.Issues = append(report.Issues, MapIssue{
+				Severity: MapIssueError,
+				Message:  fmt.Sprintf("portal %d to %q cannot be reached from %s", i, portal.Map, anchorName),
+				Tile:     &MapPoint{X: portal.X, Y: portal.Y},
+			})
+		}
+	}
+	for i, object := range data.Objects {
+		if !w.touchesArea(tilePoint{X: object.X, Y: object.Y}, main, areaOf) {
+			report.Issues = append(report.Issues, MapIssue{
+				Severity: MapIssueWarning,
+				Message:  fmt.Sprintf("object %d (%s) cannot be reached from %s", i, object.Type, anchorName),
+				Tile:     &MapPoint{X: object.X, Y: object.Y},
+			})
+		}
+	}
+	for i, area := range report.Areas {
+		if i != main {
+			report.Issues = append(report.Issues, MapIssue{
+				Severity: MapIssueWarning,
+				Message:  fmt.Sprintf("unreachable pocket of %d walkable tiles", area.Tiles),
+				Tile:     &MapPoint{X: area.Origin.X, Y: area.Origin.Y},
+			})
+		}
+	}
+
+	sort.SliceStable(report.Areas, func(i, j int) bool { return report.Areas[i].Tiles > report.Areas[j].Tiles })
+	report.Chunks = w.chunkStats(chunkSize)
+
+	return report
+}
+
+// undefinedTileIssues reports each tile id the map uses without a tile type,
+// once, at its first tile.
+func (w *World) undefinedTileIssues() []MapIssue {
+	counts := make(map[int]int)
+	first := make(map[int]MapPoint)
+	var ids []int
+	for y, row := range w.mapData {
+		for x, id := range row {
+			if _, ok := w.tiles.types[id]; ok {
+				continue
+			}
+			if counts[id] == 0 {
+				ids = append(ids, id)
+				first[id] = MapPoint{X: x, Y: y}
+			}
+			counts[id] += 1
+		}
+	}
+	sort.Ints(ids)
+
+	issues := make([]MapIssue, 0, len(ids))
+	for _, id := range ids {
+		tile := first[id]
+		issues = append(issues, MapIssue{
+			Severity: MapIssueError,
+			Message:  fmt.Sprintf("tile id %d has no tile type (used on %d tiles)", id, counts[id]),
+			Tile:     &tile,
+		})
+	}
+
+	return issues
+}
+
+// floodArea marks every tile reachable from start with area and returns how
+// many there are.
+func (w *World) floodArea(start tilePoint, area int, areaOf []int) int {
+	directions := straightDirections
+	if w.diagonal {
+		directions = allDirections
+	}
+
+	areaOf[w.tileIndex(start.X, start.Y)] = area
+	queue := []tilePoint{start}
+	for head := 0; head < len(queue); head += 1 {
+		current := queue[head]
+		for _, direction := range directions {
+			next := tilePoint{X: current.X + direction.X, Y: current.Y + direction.Y}
+			if !w.canStep(current, next) {
+				continue
+			}
+			index := w.tileIndex(next.X, next.Y)
+			if areaOf[index] >= 0 {
+				continue
+			}
+			areaOf[index] = area
+			queue = append(queue, next)
+		}
+	}
+
+	return len(queue)
+}
+
+// spawnAnchor is the tile players join on: the default spawn point, or the
+// walkable tile nearest the map centre when there is none.
+func (w *World) spawnAnchor() (tilePoint, string) {
+	if spawn, ok := w.spawns[SpawnDefault]; ok {
+		return tilePoint{X: spawn.X, Y: spawn.Y}, fmt.Sprintf("spawn point %q", SpawnDefault)
+	}
+
+	return w.nearestFreeTile(tilePoint{X: w.mapWidth / 2, Y: w.mapHeight / 2}, 0), "the default spawn"
+}
+
+// touchesArea reports whether a player in area can stand next to the tile,
+// using the same adjacency as interactions.
+func (w *World) touchesArea(tile tilePoint, area int, areaOf []int) bool {
+	for _, direction := range allDirections {
+		next := tilePoint{X: tile.X + direction.X, Y: tile.Y + direction.Y}
+		if !w.inBounds(next.X, next.Y) || !w.isAdjacent(tile, next) {
+			continue
+		}
+		if areaOf[w.tileIndex(next.X, next.Y)] == area {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (w *World) chunkStats(size int) ChunkStats {
+	columns := (w.mapWidth + size - 1) / size
+	rows := (w.mapHeight + size - 1) / size
+	walkable := make([]int, columns*rows)
+	for y := 0; y < w.mapHeight; y += 1 {
+		for x := 0; x < w.mapWidth; x += 1 {
+			if w.tiles.walkable[w.tileIndex(x, y)] {
+				walkable[(y/size)*columns+x/size] += 1
+			}
+		}
+	}
+
+	stats := ChunkStats{Size: size, Chunks: len(walkable), MinWalkable: walkable[0]}
+	total := 0
+	for _, count := range walkable {
+		total += count
+		if count == 0 {
+			stats.Blocked += 1
+		}
+		stats.MinWalkable = min(stats.MinWalkable, count)
+		stats.MaxWalkable = max(stats.MaxWalkable, count)
+	}
+	stats.AvgWalkable = float64(total) / float64(len(walkable))
+
+	return stats
+}
@@ -0,0 +1,168 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAnalyzeMap(t *testing.T) {
+	// A wall splits the map into a west area of 9 tiles and an east one of 6.
+	rows := []string{
+		"...#..",
+		"...#..",
+		"...#..",
+	}
+
+	tests := []struct {
+		name       string
+		prepare    func(data *MapData)
+		wantMain   MapArea
+		wantIssues []MapIssue
+		wantErrors int
+	}{
+		{
+			name: "reachable",
+			prepare: func(data *MapData) {
+				data.Spawns = []SpawnPoint{{Name: SpawnDefault, X: 0, Y: 0}}
+				data.Portals = []Portal{{X: 2, Y: 2, Map: "mine"}}
+				// Standing west of the wall is close enough.
+				data.Objects = []MapObject{{Type: "ore", X: 3, Y: 1}}
+			},
+			wantMain: MapArea{Origin: MapPoint{X: 0, Y: 0}, Tiles: 9},
+			wantIssues: []MapIssue{
+				{Severity: MapIssueWarning, Message: "unreachable pocket of 6 walkable tiles", Tile: &MapPoint{X: 4, Y: 0}},
+			},
+		},
+		{
+			name: "cut off",
+			prepare: func(data *MapData) {
+				data.Spawns = []SpawnPoint{{Name: SpawnDefault, X: 0, Y: 0}, {Name: "east", X: 5, Y: 1}}
+				data.Portals = []Portal{{X: 5, Y: 0, Map: "mine"}}
+				data.Objects = []MapObject{{Type: "tree", X: 5, Y: 2}}
+			},
+			wantMain: MapArea{Origin: MapPoint{X: 0, Y: 0}, Tiles: 9},
+			wantIssues: []MapIssue{
+				{Severity: MapIssueError, Message: `spawn point "east" cannot be reached from spawn point "default"`, Tile: &MapPoint{X: 5, Y: 1}},
+				{Severity: MapIssueError, Message: `portal 0 to "mine" cannot be reached from spawn point "default"`, Tile: &MapPoint{X: 5, Y: 0}},
+				{Severity: MapIssueWarning, Message: "object 0 (tree) cannot be reached from spawn point \"default\"", Tile: &MapPoint{X: 5, Y: 2}},
+				{Severity: MapIssueWarning, Message: "unreachable pocket of 6 walkable tiles", Tile: &MapPoint{X: 4, Y: 0}},
+			},
+			wantErrors: 2,
+		},
+		{
+			name: "no default spawn",
+			prepare: func(data *MapData) {
+				data.Spawns = []SpawnPoint{{Name: "west", X: 0, Y: 1}}
+			},
+			// Players join next to the centre, which is east of the wall.
+			wantMain: MapArea{Origin: MapPoint{X: 4, Y: 0}, Tiles: 6},
+			wantIssues: []MapIssue{
+				{Severity: MapIssueError, Message: `spawn point "west" cannot be reached from the default spawn`, Tile: &MapPoint{X: 0, Y: 1}},
+				{Severity: MapIssueWarning, Message: "unreachable pocket of 9 walkable tiles", Tile: &MapPoint{X: 0, Y: 0}},
+			},
+			wantErrors: 1,
+		},
+		{
+			name: "undefined tiles",
+			prepare: func(data *MapData) {
+				data.Spawns = []SpawnPoint{{Name: SpawnDefault, X: 0, Y: 0}}
+				data.Tiles[0][4] = 7
+				data.Tiles[0][5] = 7
+				data.Tiles[1][4] = 7
+				data.Tiles[1][5] = 7
+				data.Tiles[2][4] = 7
+				data.Tiles[2][5] = 7
+			},
+			wantMain: MapArea{Origin: MapPoint{X: 0, Y: 0}, Tiles: 9},
+			wantIssues: []MapIssue{
+				{Severity: MapIssueError, Message: "tile id 7 has no tile type (used on 6 tiles)", Tile: &MapPoint{X: 4, Y: 0}},
+			},
+			wantErrors: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := testMap(rows, false)
+			tt.prepare(&data)
+			if err := validateMapData(data); err != nil {
+				t.Fatal(err)
+			}
+
+			report := AnalyzeMap(data, 0)
+			if report.Main != tt.wantMain {
+				t.Fatalf("main area %+v, want %+v", report.Main, tt.wantMain)
+			}
+			if !reflect.DeepEqual(report.Issues, tt.wantIssues) {
+				t.Fatalf("issues %+v, want %+v", report.Issues, tt.wantIssues)
+			}
+			if got := report.Errors(); got != tt.wantErrors {
+				t.Fatalf("Errors() = %d, want %d", got, tt.wantErrors)
+			}
+		})
+	}
+}
+
+func TestAnalyzeMapAreas(t *testing.T) {
+	tests := []struct {
+		name     string
+		diagonal bool
+		want     []MapArea
+	}{
+		{
+			name: "straight moves",
+			want: []MapArea{
+				{Origin: MapPoint{X: 0, Y: 0}, Tiles: 2},
+				{Origin: MapPoint{X: 2, Y: 1}, Tiles: 1},
+			},
+		},
+		// Diagonal steps never cut a wall corner, so the tiles meeting at
+		// one stay apart.
+		{
+			name:     "diagonal moves",
+			diagonal: true,
+			want: []MapArea{
+				{Origin: MapPoint{X: 0, Y: 0}, Tiles: 2},
+				{Origin: MapPoint{X: 2, Y: 1}, Tiles: 1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := testMap([]string{
+				"..#",
+				"##.",
+			}, tt.diagonal)
+
+			report := AnalyzeMap(data, 0)
+			if !reflect.DeepEqual(report.Areas, tt.want) {
+				t.Fatalf("areas %+v, want %+v", report.Areas, tt.want)
+			}
+			if report.Walkable != 3 {
+				t.Fatalf("%d walkable tiles, want 3", report.Walkable)
+			}
+		})
+	}
+}
+
+func TestAnalyzeMapChunkStats(t *testing.T) {
+	data := testMap([]string{
+		"..##",
+		"..##",
+		"....",
+	}, false)
+
+	report := AnalyzeMap(data, 2)
+	want := ChunkStats{Size: 2, Chunks: 4, Blocked: 1, MinWalkable: 0, MaxWalkable: 4, AvgWalkable: 2}
+	if report.Chunks != want {
+		t.Fatalf("chunk stats %+v, want %+v", report.Chunks, want)
+	}
+}
+
+func TestAnalyzeMapWithoutWalkableTiles(t *testing.T) {
+	report := AnalyzeMap(testMap([]string{"##", "##"}, false), 0)
+	if report.Errors() != 1 || len(report.Areas) != 0 {
+		t.Fatalf("report %+v, want one error and no areas", report)
+	}
+}
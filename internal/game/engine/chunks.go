@@ -0,0 +1,167 @@
+package engine
+
+// DefaultChunkSizeTiles is the edge length of the square chunks the world
+// indexes entities by. Interest management should use the same size so radius
+// queries can be answered from the index.
+const DefaultChunkSizeTiles = 8
+
+type ChunkCoord struct {
+	X int
+	Y int
+}
+
+// ChunkTransition reports an entity crossing a chunk boundary. Joined is set
+// when the entity entered the world (From is meaningless) and Left when it
+// was removed (To is meaningless).
+type ChunkTransition struct {
+	ID     EntityID
+	Kind   EntityKind
+	From   ChunkCoord
+	To     ChunkCoord
+	Joined bool
+	Left   bool
+}
+
+// chunkIndex buckets entities by chunk. Access is guarded by World.mu.
+type chunkIndex struct {
+	size     int
+	chunks   map[ChunkCoord]map[EntityID]*Entity
+	owner    map[EntityID]ChunkCoord
+	pending  []ChunkTransition
+	listener func(ChunkTransition)
+}
+
+func newChunkIndex(size int) chunkIndex {
+	if size <= 0 {
+		size = DefaultChunkSizeTiles
+	}
+
+	return chunkIndex{
+		size:   size,
+		chunks: make(map[ChunkCoord]map[EntityID]*Entity),
+		owner:  make(map[EntityID]ChunkCoord),
+	}
+}
+
+func (c *chunkIndex) coordFor(tileX, tileY int) ChunkCoord {
+	return ChunkCoord{X: tileX / c.size, Y: tileY / c.size}
+}
+
+func (c *chunkIndex) insert(entity *Entity, coord ChunkCoord) {
+	bucket, ok := c.chunks[coord]
+	if !ok {
+		bucket = make(map[EntityID]*Entity)
+		c.chunks[coord] = bucket
+	}
+	bucket[entity.ID] = entity
+	c.owner[entity.ID] = coord
+}
+
+func (c *chunkIndex) detach(id EntityID) (ChunkCoord, bool) {
+	coord, ok := c.owner[id]
+	if !ok {
+		return ChunkCoord{}, false
+	}
+
+	bucket := c.chunks[coord]
+	delete(bucket, id)
+	if len(bucket) == 0 {
+		delete(c.chunks, coord)
+	}
+	delete(c.owner, id)
+
+	return coord, true
+}
+
+func (c *chunkIndex) add(entity *Entity, coord ChunkCoord) {
+	if previous, ok := c.detach(entity.ID); ok {
+		c.notify(ChunkTransition{ID: entity.ID, Kind: entity.Kind, From: previous, Left: true})
+	}
+	c.insert(entity, coord)
+	c.notify(ChunkTransition{ID: entity.ID, Kind: entity.Kind, To: coord, Joined: true})
+}
+
+func (c *chunkIndex) remove(entity *Entity) {
+	if coord, ok := c.detach(entity.ID); ok {
+		c.notify(ChunkTransition{ID: entity.ID, Kind: entity.Kind, From: coord, Left: true})
+	}
+}
+
+func (c *chunkIndex) move(entity *Entity, coord ChunkCoord) {
+	previous, ok := c.owner[entity.ID]
+	if ok && previous == coord {
+		return
+	}
+
+	c.detach(entity.ID)
+	c.insert(entity, coord)
+	if ok {
+		c.notify(ChunkTransition{ID: entity.ID, Kind: entity.Kind, From: previous, To: coord})
+	}
+}
+
+func (c *chunkIndex) notify(transition ChunkTransition) {
+	if c.listener == nil {
+		return
+	}
+
+	c.pending = append(c.pending, transition)
+}
+
+func (c *chunkIndex) appendInRadius(entities []Entity, center ChunkCoord, radius int) []Entity {
+	for y := center.Y - radius; y <= center.Y+radius; y += 1 {
+		for x := center.X - radius; x <= center.X+radius; x += 1 {
+			for _, entity := range c.chunks[ChunkCoord{X: x, Y: y}] {
+				entities = append(entities, entity.snapshot())
+			}
+		}
+	}
+
+	return entities
+}
+
+// SetChunkListener registers a callback for chunk transitions. It is invoked
+// after the world lock is released, in the order transitions happened.
+func (w *World) SetChunkListener(listener func(ChunkTransition)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.chunks.listener = listener
+	w.chunks.pending = nil
+}
+
+// ChunkSizeTiles returns the chunk size the world indexes entities by.
+func (w *World) ChunkSizeTiles() int {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	return w.chunks.size
+}
+
+func (w *World) chunkOf(entity *Entity) ChunkCoord {
+	tileX, tileY := w.toTileCoords(entity.X, entity.Y)
+	return w.chunks.coordFor(tileX, tileY)
+}
+
+// unlockAndNotify releases w.mu and then delivers any chunk transitions and
+// events that were queued while it was held, so listeners and subscribers
+// may call back into the world.
+func (w *World) unlockAndNotify() {
+	pending := w.chunks.pending
+	listener := w.chunks.listener
+	w.chunks.pending = nil
+	var events []Event
+	if w.eventHolds == 0 {
+		events = w.events
+		w.events = nil
+	}
+	bus := w.bus
+	w.mu.Unlock()
+
+	if listener != nil {
+		for _, transition := range pending {
+			listener(transition)
+		}
+	}
+	bus.Publish(events...)
+}
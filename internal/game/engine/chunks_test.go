@@ -0,0 +1,122 @@
+package engine
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// openWorld is a size x size world without walls.
+func openWorld(size int) *World {
+	rows := make([]string, size)
+	for i := range rows {
+		rows[i] = strings.Repeat(".", size)
+	}
+
+	return NewWorld(testMap(rows, false))
+}
+
+// placePlayer teleports a player to the center of a tile, keeping the chunk
+// index current.
+func placePlayer(w *World, id string, tileX, tileY int) {
+	player := w.players[id]
+	player.X = w.tileCenter(tileX)
+	player.Y = w.tileCenter(tileY)
+	w.chunks.move(player, w.chunkOf(player))
+}
+
+func playerIDs(players []Player) []string {
+	ids := make([]string, 0, len(players))
+	for _, player := range players {
+		ids = append(ids, player.ID)
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+func TestChunkRadiusQueries(t *testing.T) {
+	w := openWorld(64)
+	rng := rand.New(rand.NewSource(5))
+	for i := 0; i < 60; i += 1 {
+		id := fmt.Sprintf("p%d", i)
+		w.AddPlayer(id, nil)
+		placePlayer(w, id, rng.Intn(64), rng.Intn(64))
+	}
+
+	tests := []struct {
+		name      string
+		radius    int
+		chunkSize int
+	}{
+		{name: "indexed", radius: 1, chunkSize: DefaultChunkSizeTiles},
+		{name: "indexed, own chunk", radius: 0, chunkSize: DefaultChunkSizeTiles},
+		{name: "indexed, past the map edge", radius: 3, chunkSize: DefaultChunkSizeTiles},
+		{name: "other chunk size", radius: 2, chunkSize: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, center := range w.SnapshotPlayers() {
+				got, ok := w.SnapshotPlayersInChunkRadius(center.ID, tt.radius, tt.chunkSize)
+				if !ok {
+					t.Fatalf("player %s not found", center.ID)
+				}
+
+				centerX, centerY := w.toTileCoords(center.X, center.Y)
+				var want []Player
+				for _, other := range w.SnapshotPlayers() {
+					x, y := w.toTileCoords(other.X, other.Y)
+					if absInt(x/tt.chunkSize-centerX/tt.chunkSize) <= tt.radius && absInt(y/tt.chunkSize-centerY/tt.chunkSize) <= tt.radius {
+						want = append(want, other)
+					}
+				}
+				if !reflect.DeepEqual(playerIDs(got), playerIDs(want)) {
+					t.Fatalf("players near %s = %v, want %v", center.ID, playerIDs(got), playerIDs(want))
+				}
+			}
+		})
+	}
+
+	if _, ok := w.SnapshotPlayersInChunkRadius("missing", 1, DefaultChunkSizeTiles); ok {
+		t.Fatal("found a missing player")
+	}
+}
+
+func TestChunkIndexFollowsPlayers(t *testing.T) {
+	w := openWorld(64)
+	var transitions []ChunkTransition
+	w.SetChunkListener(func(transition ChunkTransition) {
+		// Listeners run without the world lock held.
+		w.ChunkSizeTiles()
+		transitions = append(transitions, transition)
+	})
+
+	w.AddPlayer("a", nil)
+	if _, ok := w.SetPlayerTarget("a", w.tileCenter(41), w.tileCenter(32)); !ok {
+		t.Fatal("target rejected")
+	}
+	for i := 0; i < 200 && w.players["a"].Movement.Path != nil; i += 1 {
+		w.Step(0.05)
+	}
+	if tileX, _ := w.toTileCoords(w.players["a"].X, 0); tileX != 41 {
+		t.Fatalf("player stopped at tile %d, want 41", tileX)
+	}
+	id := w.players["a"].ID
+	w.RemovePlayer("a")
+
+	want := []ChunkTransition{
+		{ID: id, Kind: EntityPlayer, To: ChunkCoord{X: 4, Y: 4}, Joined: true},
+		{ID: id, Kind: EntityPlayer, From: ChunkCoord{X: 4, Y: 4}, To: ChunkCoord{X: 5, Y: 4}},
+		{ID: id, Kind: EntityPlayer, From: ChunkCoord{X: 5, Y: 4}, Left: true},
+	}
+	if !reflect.DeepEqual(transitions, want) {
+		t.Fatalf("transitions = %+v, want %+v", transitions, want)
+	}
+	if len(w.chunks.chunks) != 0 || len(w.chunks.owner) != 0 {
+		t.Fatalf("index not emptied: %v", w.chunks.chunks)
+	}
+}
@@ -0,0 +1,124 @@
+package engine
+
+// An entity blocked by an occupied tile re-plans its route every
+// collisionRepathTicks ticks and drops it after collisionGiveUpTicks.
+const (
+	collisionRepathTicks = 4
+	collisionGiveUpTicks = 40
+)
+
+// collisionTiles resolves where entities block each other: everywhere when
+// the map sets Collision, overridden for the tiles covered by any marker with
+// a boolean "collision" property. Later markers win.
+func collisionTiles(data MapData) []bool {
+	tiles := make([]bool, data.Width*data.Height)
+	if data.Collision {
+		for i := range tiles {
+			tiles[i] = true
+		}
+	}
+
+	for _, marker := range data.Markers {
+		collision, ok := marker.Properties["collision"].(bool)
+		if !ok {
+			continue
+		}
+		for y := 0; y < data.Height; y += 1 {
+			for x := 0; x < data.Width; x += 1 {
+				if marker.Contains(x, y) {
+					tiles[y*data.Width+x] = collision
+				}
+			}
+		}
+	}
+
+	return tiles
+}
+
+// CollisionAt reports whether entities block each other on the given tile.
+func (w *World) CollisionAt(tileX, tileY int) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if !w.inBounds(tileX, tileY) {
+		return false
+	}
+
+	return w.tiles.collision[w.tileIndex(tileX, tileY)]
+}
+
+// occupy moves the entity's reservation to the tile at index. Entities hold
+// the tile they stand on, or the one they are walking to, so two entities
+// never commit to the same tile where collision applies.
+func (w *World) occupy(entity *Entity, index int32) {
+	if entity.tile >= 0 {
+		w.occupants[entity.tile] -= 1
+	}
+	entity.tile = index
+	if index >= 0 {
+		w.occupants[index] += 1
+	}
+}
+
+// blockedFor reports whether some entity other than self holds the tile at
+// index and the tile has collision.
+func (w *World) blockedFor(index int32, self *Entity) bool {
+	if !w.tiles.collision[index] {
+		return false
+	}
+
+	count := w.occupants[index]
+	if self != nil && self.tile == index {
+		count -= 1
+	}
+
+	return count > 0
+}
+
+// commitNext reserves the entity's next path tile and starts it moving there.
+// It reports false when the step is impossible or the tile is taken.
+func (w *World) commitNext(entity *Entity) bool {
+	movement := entity.Movement
+	next := movement.Path[movement.PathIndex]
+	if movement.PathIndex > 0 && !w.canStep(movement.Path[movement.PathIndex-1], next) {
+		w.cancelPath(entity)
+		return false
+	}
+
+	index := w.tileIndex(next.X, next.Y)
+	if w.blockedFor(index, entity) {
+		w.waitForTile(entity)
+		return false
+	}
+
+	movement.blocked = 0
+	w.occupy(entity, index)
+	movement.TargetX = w.tileCenter(next.X)
+	movement.TargetY = w.tileCenter(next.Y)
+	movement.HasTarget = true
+
+	return true
+}
+
+// waitForTile keeps a blocked entity in place, periodically re-planning
+// around the occupied tiles and giving up if the way stays shut.
+func (w *World) waitForTile(entity *Entity) {
+	movement := entity.Movement
+	movement.blocked += 1
+
+	if movement.blocked >= collisionGiveUpTicks {
+		w.cancelPath(entity)
+		movement.blocked = 0
+		return
+	}
+
+	if movement.blocked%collisionRepathTicks == 0 {
+		goal := movement.Path[len(movement.Path)-1]
+		blocked := movement.blocked
+		if !w.setEntityPath(entity, goal) {
+			w.cancelPath(entity)
+			blocked = 0
+		}
+		movement.blocked = blocked
+	}
+}
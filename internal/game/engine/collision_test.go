@@ -0,0 +1,230 @@
+package engine
+
+import "testing"
+
+func TestCollisionAt(t *testing.T) {
+	rect := func(x, width int, collision bool) MapMarker {
+		return MapMarker{X: x, Y: 0, Width: width, Height: 1, Properties: map[string]any{"collision": collision}}
+	}
+
+	tests := []struct {
+		name      string
+		collision bool
+		markers   []MapMarker
+		want      string
+	}{
+		{name: "off", want: "......"},
+		{name: "on", collision: true, want: "######"},
+		{name: "marker turns it on", markers: []MapMarker{rect(1, 2, true)}, want: ".##..."},
+		{name: "marker turns it off", collision: true, markers: []MapMarker{rect(4, 2, false)}, want: "####.."},
+		{name: "later markers win", markers: []MapMarker{rect(0, 4, true), rect(2, 4, false)}, want: "##...."},
+		{
+			name:    "non-boolean property is ignored",
+			markers: []MapMarker{{X: 0, Y: 0, Width: 6, Height: 1, Properties: map[string]any{"collision": "yes"}}},
+			want:    "......",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := testMap([]string{"......"}, false)
+			data.Collision = tt.collision
+			data.Markers = tt.markers
+			w := NewWorld(data)
+
+			got := make([]byte, 6)
+			for x := range got {
+				got[x] = '.'
+				if w.CollisionAt(x, 0) {
+					got[x] = '#'
+				}
+			}
+			if string(got) != tt.want {
+				t.Fatalf("collision = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// playerTile returns the tile a player stands on.
+func playerTile(w *World, id string) tilePoint {
+	for _, player := range w.SnapshotPlayers() {
+		if player.ID == id {
+			tileX, tileY := w.toTileCoords(player.X, player.Y)
+			return tilePoint{X: tileX, Y: tileY}
+		}
+	}
+
+	return tilePoint{X: -1, Y: -1}
+}
+
+func TestEntitiesDoNotShareTiles(t *testing.T) {
+	tests := []struct {
+		name      string
+		rows      []string
+		collision bool
+		// alice walks from the left to the right and bob, a few ticks later,
+		// the other way.
+		wantPassed bool
+	}{
+		{name: "no collision", rows: []string{"........"}, wantPassed: true},
+		{name: "one lane", rows: []string{"........"}, collision: true, wantPassed: false},
+		{name: "two lanes", rows: []string{"........", "........"}, collision: true, wantPassed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := testMap(tt.rows, false)
+			data.Collision = tt.collision
+			w := NewWorld(data)
+			w.AddPlayer("alice", &Position{X: w.tileCenter(0), Y: w.tileCenter(0)})
+			w.AddPlayer("bob", &Position{X: w.tileCenter(7), Y: w.tileCenter(0)})
+			w.SetPlayerTarget("alice", w.tileCenter(7), w.tileCenter(0))
+
+			for i := 0; i < 200; i += 1 {
+				if i == 2 {
+					w.SetPlayerTarget("bob", w.tileCenter(0), w.tileCenter(0))
+				}
+				w.Step(0.05)
+				if tt.collision && playerTile(w, "alice") == playerTile(w, "bob") {
+					t.Fatalf("alice and bob share %v on tick %d", playerTile(w, "alice"), i)
+				}
+			}
+
+			passed := playerTile(w, "alice").X > playerTile(w, "bob").X
+			if passed != tt.wantPassed {
+				t.Fatalf("alice at %v and bob at %v, passed = %v, want %v",
+					playerTile(w, "alice"), playerTile(w, "bob"), passed, tt.wantPassed)
+			}
+		})
+	}
+}
+
+func TestBlockedEntitiesGiveUp(t *testing.T) {
+	data := testMap([]string{"....."}, false)
+	data.Collision = true
+	w := NewWorld(data)
+	w.AddPlayer("alice", &Position{X: w.tileCenter(0), Y: w.tileCenter(0)})
+	w.AddPlayer("bob", &Position{X: w.tileCenter(2), Y: w.tileCenter(0)})
+
+	// Bob never moves, so alice's only route stays shut.
+	if _, ok := w.SetPlayerTarget("alice", w.tileCenter(4), w.tileCenter(0)); !ok {
+		t.Fatal("alice could not plan through an occupied tile")
+	}
+	for i := 0; i < collisionGiveUpTicks+10; i += 1 {
+		w.Step(0.05)
+	}
+
+	for _, player := range w.SnapshotPlayers() {
+		if player.ID == "alice" && player.Path != nil {
+			t.Fatalf("alice still has a path after %d blocked ticks", collisionGiveUpTicks+10)
+		}
+	}
+	if got := playerTile(w, "alice"); got != (tilePoint{X: 1, Y: 0}) {
+		t.Fatalf("alice stopped at %v, want next to bob", got)
+	}
+}
+
+func TestFindPathAvoidsOccupiedTiles(t *testing.T) {
+	rows := []string{
+		".....#.",
+		".###.#.",
+		".....#.",
+	}
+
+	tests := []struct {
+		name         string
+		blocker      tilePoint
+		goal         tilePoint
+		wantLength   int
+		wantOccupied bool
+	}{
+		{name: "short way open", blocker: tilePoint{X: 2, Y: 2}, goal: tilePoint{X: 4, Y: 0}, wantLength: 5},
+		{name: "around the blocker", blocker: tilePoint{X: 2, Y: 0}, goal: tilePoint{X: 4, Y: 0}, wantLength: 9, wantOccupied: true},
+		{name: "unreachable", blocker: tilePoint{X: 6, Y: 0}, goal: tilePoint{X: 6, Y: 2}, wantLength: 0},
+		{name: "unreachable past the blocker", blocker: tilePoint{X: 2, Y: 0}, goal: tilePoint{X: 6, Y: 2}, wantLength: 0, wantOccupied: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := testMap(rows, false)
+			data.Collision = true
+			w := NewWorld(data)
+			w.AddPlayer("alice", &Position{X: w.tileCenter(0), Y: w.tileCenter(0)})
+			w.AddPlayer("bob", &Position{X: w.tileCenter(tt.blocker.X), Y: w.tileCenter(tt.blocker.Y)})
+			alice := w.players["alice"]
+
+			path := w.findPath(tilePoint{X: 0, Y: 0}, tt.goal, alice)
+			if len(path) != tt.wantLength {
+				t.Fatalf("path %v has %d tiles, want %d", path, len(path), tt.wantLength)
+			}
+			if w.search.occupied != tt.wantOccupied {
+				t.Fatalf("occupied = %v, want %v", w.search.occupied, tt.wantOccupied)
+			}
+		})
+	}
+}
+
+func TestAddPlayerAvoidsOccupiedSavedPositions(t *testing.T) {
+	data := testMap([]string{"....."}, false)
+	data.Collision = true
+	w := NewWorld(data)
+	saved := &Position{X: w.tileCenter(2) + 5, Y: w.tileCenter(0)}
+
+	w.AddPlayer("alice", saved)
+	if got := playerTile(w, "alice"); got != (tilePoint{X: 2, Y: 0}) {
+		t.Fatalf("alice spawned at %v, want her saved tile", got)
+	}
+	// Joining again keeps the tile alice already stands on.
+	w.AddPlayer("alice", saved)
+	if got := playerTile(w, "alice"); got != (tilePoint{X: 2, Y: 0}) {
+		t.Fatalf("alice re-joined at %v, want her saved tile", got)
+	}
+
+	w.AddPlayer("bob", saved)
+	if got := playerTile(w, "bob"); got == playerTile(w, "alice") {
+		t.Fatalf("bob spawned on alice's tile %v", got)
+	}
+}
+
+func TestNPCsPauseAfterFailedPlans(t *testing.T) {
+	rows := []string{
+		"#####",
+		"#.#.#",
+		"#####",
+	}
+	unreachable := tilePoint{X: 3, Y: 1}
+
+	tests := []struct {
+		name      string
+		behaviour Behaviour
+		wait      func(b Behaviour) float64
+	}{
+		{
+			name:      "wander",
+			behaviour: &wanderBehaviour{home: unreachable, radius: 1},
+			wait:      func(b Behaviour) float64 { return b.(*wanderBehaviour).wait },
+		},
+		{
+			name:      "patrol",
+			behaviour: &patrolBehaviour{route: []tilePoint{unreachable}},
+			wait:      func(b Behaviour) float64 { return b.(*patrolBehaviour).wait },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewWorld(testMap(rows, false))
+			npc := &Entity{Kind: EntityNPC, X: w.tileCenter(1), Y: w.tileCenter(1), Movement: &Movement{}, tile: -1}
+			w.addEntityLocked(npc)
+
+			tt.behaviour.Update(w, npc, 0.05)
+			if isMoving(npc) {
+				t.Fatal("NPC found a path to an unreachable tile")
+			}
+			if got := tt.wait(tt.behaviour); got < failedPlanPause {
+				t.Fatalf("NPC waits %v after a failed plan, want at least %v", got, failedPlanPause)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package engine
+
+import "sync"
+
+// MaxQueuedCommands bounds the commands waiting for the next tick so a
+// flooding client cannot grow the queue without limit.
+const MaxQueuedCommands = 4096
+
+type CommandType string
+
+const (
+	CommandMoveTo   CommandType = "move_to"
+	CommandInteract CommandType = "interact"
+	CommandRun      CommandType = "run"
+)
+
+// Command is a player input waiting to be applied by Step. Seq is assigned
+// on enqueue and defines the order commands are applied within a tick. X and
+// Y are used by move_to, Target and Action by interact, Run by run.
+type Command struct {
+	Seq      uint64      `json:"seq"`
+	PlayerID string      `json:"playerId"`
+	Type     CommandType `json:"type"`
+	X        int         `json:"x,omitempty"`
+	Y        int         `json:"y,omitempty"`
+	Target   EntityID    `json:"target,omitempty"`
+	Action   string      `json:"action,omitempty"`
+	Run      bool        `json:"run,omitempty"`
+}
+
+// commandQueue has its own lock so network goroutines can enqueue without
+// contending on World.mu.
+type commandQueue struct {
+	mu      sync.Mutex
+	nextSeq uint64
+	pending []Command
+}
+
+func (q *commandQueue) push(cmd Command) (uint64, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.pending) >= MaxQueuedCommands {
+		return 0, false
+	}
+
+	q.nextSeq += 1
+	cmd.Seq = q.nextSeq
+	q.pending = append(q.pending, cmd)
+
+	return cmd.Seq, true
+}
+
+func (q *commandQueue) drain(buf []Command) []Command {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	buf = append(buf[:0], q.pending...)
+	q.pending = q.pending[:0]
+
+	return buf
+}
+
+// EnqueueCommand stamps cmd with the next sequence number and queues it for
+// the start of the next Step. It reports false when the queue is full.
+func (w *World) EnqueueCommand(cmd Command) (uint64, bool) {
+	return w.commands.push(cmd)
+}
+
+// applyCommands drains the queue in sequence order. Callers must hold w.mu.
+func (w *World) applyCommands() {
+	w.applied = w.commands.drain(w.applied)
+	for _, cmd := range w.applied {
+		w.applyCommand(cmd)
+	}
+}
+
+func (w *World) applyCommand(cmd Command) bool {
+	switch cmd.Type {
+	case CommandMoveTo:
+		return w.setPlayerTarget(cmd.PlayerID, cmd.X, cmd.Y)
+	case CommandInteract:
+		return w.startInteraction(cmd.PlayerID, cmd.Target, cmd.Action)
+	case CommandRun:
+		return w.setRunning(cmd.PlayerID, cmd.Run)
+	default:
+		return false
+	}
+}
@@ -0,0 +1,96 @@
+package engine
+
+import (
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestEnqueueCommandSequences(t *testing.T) {
+	w := openWorld(8)
+
+	const writers = 8
+	const perWriter = 100
+	seqs := make(chan uint64, writers*perWriter)
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i += 1 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perWriter; j += 1 {
+				seq, ok := w.EnqueueCommand(Command{PlayerID: "a", Type: CommandMoveTo})
+				if !ok {
+					t.Error("queue rejected a command")
+				}
+				seqs <- seq
+			}
+		}()
+	}
+	wg.Wait()
+	close(seqs)
+
+	var got []uint64
+	for seq := range seqs {
+		got = append(got, seq)
+	}
+	sort.Slice(got, func(i, j int) bool { return got[i] < got[j] })
+	for i, seq := range got {
+		if seq != uint64(i+1) {
+			t.Fatalf("sequence numbers %v are not 1..%d", got, len(got))
+		}
+	}
+
+	// The queue keeps them in sequence order for Step.
+	queued := w.commands.drain(nil)
+	for i, cmd := range queued {
+		if cmd.Seq != uint64(i+1) {
+			t.Fatalf("command %d has seq %d", i, cmd.Seq)
+		}
+	}
+}
+
+func TestEnqueueCommandQueueFull(t *testing.T) {
+	w := openWorld(8)
+	for i := 0; i < MaxQueuedCommands; i += 1 {
+		if _, ok := w.EnqueueCommand(Command{Type: CommandMoveTo}); !ok {
+			t.Fatalf("command %d rejected", i)
+		}
+	}
+	if _, ok := w.EnqueueCommand(Command{Type: CommandMoveTo}); ok {
+		t.Fatal("queue accepted a command past its limit")
+	}
+
+	// Draining at the next tick makes room again.
+	w.Step(0)
+	if seq, ok := w.EnqueueCommand(Command{Type: CommandMoveTo}); !ok || seq != MaxQueuedCommands+1 {
+		t.Fatalf("EnqueueCommand() = %d, %v after the queue drained", seq, ok)
+	}
+}
+
+func TestCommandsApplyAtTickStart(t *testing.T) {
+	w := openWorld(8)
+	w.AddPlayer("a", nil)
+
+	w.EnqueueCommand(Command{PlayerID: "a", Type: CommandMoveTo, X: w.tileCenter(1), Y: w.tileCenter(4)})
+	w.EnqueueCommand(Command{PlayerID: "a", Type: "teleport", X: 0, Y: 0})
+	w.EnqueueCommand(Command{PlayerID: "missing", Type: CommandMoveTo, X: 0, Y: 0})
+	// Later commands win within a tick.
+	w.EnqueueCommand(Command{PlayerID: "a", Type: CommandMoveTo, X: w.tileCenter(6), Y: w.tileCenter(4)})
+	if w.players["a"].Movement.Path != nil {
+		t.Fatal("command applied before the tick")
+	}
+
+	w.Step(0.05)
+
+	player := w.players["a"]
+	goal := player.Movement.Path[len(player.Movement.Path)-1]
+	if goal != (tilePoint{X: 6, Y: 4}) {
+		t.Fatalf("player heading to %v, want (6, 4)", goal)
+	}
+	if player.X <= w.tileCenter(4) {
+		t.Fatal("player did not move in the tick its command was applied")
+	}
+	if len(w.commands.drain(nil)) != 0 {
+		t.Fatal("commands left in the queue after the tick")
+	}
+}
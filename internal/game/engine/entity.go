@@ -0,0 +1,355 @@
+package engine
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// EntityID identifies an entity for the lifetime of a World. IDs are
+// assigned in increasing order and never reused.
+type EntityID uint64
+
+type EntityKind string
+
+const (
+	EntityPlayer EntityKind = "player"
+	EntityNPC    EntityKind = "npc"
+	EntityObject EntityKind = "object"
+)
+
+// Movement is the optional path-following component of an entity. Speed is
+// in unscaled units per second, DefaultMoveSpeed when zero.
+type Movement struct {
+	TargetX   int
+	TargetY   int
+	HasTarget bool
+	Path      []tilePoint
+	PathIndex int
+	Speed     float64
+	Running   bool
+	RunEnergy int
+	blocked   int
+	// announced is the speed of the last path update sent for the entity.
+	announced float64
+}
+
+// Behaviour drives a non-player entity. Update runs once per tick before
+// movement, with the world lock held, so it may use the world's unexported
+// helpers but must not call its exported methods.
+type Behaviour interface {
+	Update(w *World, e *Entity, deltaSeconds float64)
+}
+
+// Entity is anything that occupies a position in the world. PlayerID is set
+// only for player entities. Type names the NPC definition or object type and
+// State is only used by objects. Snapshots returned by the World carry copies
+// of Movement and never expose Behaviour.
+type Entity struct {
+	ID        EntityID
+	Kind      EntityKind
+	PlayerID  string
+	Type      string
+	State     ObjectState
+	X         int
+	Y         int
+	Movement  *Movement
+	Behaviour Behaviour
+	tile      int32
+}
+
+// NetID is the identifier clients know the entity by: the user id for
+// players and "e<id>" for everything else.
+func (e Entity) NetID() string {
+	if e.Kind == EntityPlayer {
+		return e.PlayerID
+	}
+
+	return "e" + strconv.FormatUint(uint64(e.ID), 10)
+}
+
+// ParseNetID reverses NetID for non-player entities.
+func ParseNetID(id string) (EntityID, bool) {
+	if !strings.HasPrefix(id, "e") {
+		return 0, false
+	}
+
+	value, err := strconv.ParseUint(id[1:], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return EntityID(value), true
+}
+
+func (e *Entity) snapshot() Entity {
+	copied := *e
+	copied.Behaviour = nil
+	if e.Movement != nil {
+		movement := *e.Movement
+		copied.Movement = &movement
+	}
+
+	return copied
+}
+
+// AddEntity places a non-player entity on the world at a scaled position.
+// Entities with movable set get a Movement component.
+func (w *World) AddEntity(kind EntityKind, x, y int, movable bool, behaviour Behaviour) EntityID {
+	w.mu.Lock()
+	defer w.unlockAndNotify()
+
+	entity := &Entity{
+		Kind:      kind,
+		X:         x,
+		Y:         y,
+		Behaviour: behaviour,
+	}
+	if movable {
+		entity.Movement = &Movement{TargetX: x, TargetY: y}
+	}
+	w.addEntityLocked(entity)
+
+	return entity.ID
+}
+
+// RemoveEntity deletes a non-player entity. Players must leave through
+// RemovePlayer.
+func (w *World) RemoveEntity(id EntityID) (Entity, bool) {
+	w.mu.Lock()
+	defer w.unlockAndNotify()
+
+	entity, ok := w.entities[id]
+	if !ok || entity.Kind == EntityPlayer {
+		return Entity{}, false
+	}
+
+	w.removeEntityLocked(entity)
+	return entity.snapshot(), true
+}
+
+func (w *World) Entity(id EntityID) (Entity, bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	entity, ok := w.entities[id]
+	if !ok {
+		return Entity{}, false
+	}
+
+	return entity.snapshot(), true
+}
+
+// SetEntityTarget paths a movable entity towards a scaled world position.
+func (w *World) SetEntityTarget(id EntityID, x, y int) bool {
+	w.mu.Lock()
+	defer w.unlockAndNotify()
+
+	entity, ok := w.entities[id]
+	if !ok {
+		return false
+	}
+
+	return w.setEntityTarget(entity, x, y)
+}
+
+func (w *World) SnapshotEntities() []Entity {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	entities := make([]Entity, 0, len(w.order))
+	for _, entity := range w.order {
+		entities = append(entities, entity.snapshot())
+	}
+
+	return entities
+}
+
+// SnapshotEntitiesInChunkRadius returns every entity within chunkRadius
+// chunks of the given player.
+func (w *World) SnapshotEntitiesInChunkRadius(playerID string, chunkRadius int, chunkSizeTiles int) ([]Entity, bool) {
+	if chunkSizeTiles <= 0 {
+		chunkSizeTiles = 1
+	}
+	if chunkRadius < 0 {
+		chunkRadius = 0
+	}
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	player, ok := w.players[playerID]
+	if !ok {
+		return nil, false
+	}
+
+	centerTileX, centerTileY := w.toTileCoords(player.X, player.Y)
+	if chunkSizeTiles == w.chunks.size {
+		entities := w.chunks.appendInRadius(nil, w.chunks.coordFor(centerTileX, centerTileY), chunkRadius)
+		return entities, true
+	}
+
+	centerChunkX := centerTileX / chunkSizeTiles
+	centerChunkY := centerTileY / chunkSizeTiles
+
+	entities := make([]Entity, 0, len(w.order))
+	for _, other := range w.order {
+		tileX, tileY := w.toTileCoords(other.X, other.Y)
+		chunkX := tileX / chunkSizeTiles
+		chunkY := tileY / chunkSizeTiles
+		if absInt(chunkX-centerChunkX) <= chunkRadius && absInt(chunkY-centerChunkY) <= chunkRadius {
+			entities = append(entities, other.snapshot())
+		}
+	}
+
+	return entities, true
+}
+
+func (w *World) addEntityLocked(entity *Entity) {
+	w.nextEntityID += 1
+	entity.ID = w.nextEntityID
+	w.insertEntityLocked(entity)
+}
+
+// insertEntityLocked registers an entity that already has an ID higher than
+// every entity in the world.
+func (w *World) insertEntityLocked(entity *Entity) {
+	w.entities[entity.ID] = entity
+	w.order = append(w.order, entity)
+	tileX, tileY := w.toTileCoords(entity.X, entity.Y)
+	entity.tile = -1
+	if w.inBounds(tileX, tileY) {
+		w.occupy(entity, w.tileIndex(tileX, tileY))
+	}
+	if entity.Kind == EntityPlayer {
+		w.players[entity.PlayerID] = entity
+	}
+	w.chunks.add(entity, w.chunkOf(entity))
+
+	w.dirty = true
+}
+
+func (w *World) removeEntityLocked(entity *Entity) {
+	delete(w.entities, entity.ID)
+	delete(w.npcs, entity.ID)
+	delete(w.objects, entity.ID)
+	delete(w.interactions, entity.ID)
+	if entity.Kind == EntityPlayer {
+		delete(w.players, entity.PlayerID)
+		w.emit(PlayerLeft{EventMeta: w.meta(), PlayerID: entity.PlayerID, Entity: entity.ID, Position: Position{X: entity.X, Y: entity.Y}})
+	}
+
+	index := sort.Search(len(w.order), func(i int) bool {
+		return w.order[i].ID >= entity.ID
+	})
+	if index < len(w.order) && w.order[index].ID == entity.ID {
+		w.order = append(w.order[:index], w.order[index+1:]...)
+	}
+	w.chunks.remove(entity)
+	w.occupy(entity, -1)
+
+	w.dirty = true
+}
+
+func (w *World) setEntityTarget(entity *Entity, x, y int) bool {
+	if entity.Movement == nil {
+		return false
+	}
+
+	targetTileX, targetTileY := w.toTileCoords(x, y)
+	return w.setEntityPath(entity, tilePoint{X: targetTileX, Y: targetTileY})
+}
+
+// setEntityPath plans a path to goal and starts the entity along it.
+func (w *World) setEntityPath(entity *Entity, goal tilePoint) bool {
+	movement := entity.Movement
+	if movement == nil || !w.isWalkable(goal.X, goal.Y) {
+		return false
+	}
+
+	startTileX, startTileY := w.toTileCoords(entity.X, entity.Y)
+	start := tilePoint{X: startTileX, Y: startTileY}
+	path := w.findPath(start, goal, entity)
+	if len(path) == 0 && w.search.occupied {
+		// Other entities may be all that stands in the way: take the route
+		// anyway and let stepEntity wait for them to move. Goals that are
+		// unreachable regardless are not searched twice.
+		path = w.findPath(start, goal, nil)
+	}
+	if len(path) == 0 {
+		return false
+	}
+
+	movement.blocked = 0
+	if len(path) <= 1 {
+		movement.TargetX = entity.X
+		movement.TargetY = entity.Y
+		movement.HasTarget = false
+		w.cancelPath(entity)
+		w.occupy(entity, w.tileIndex(startTileX, startTileY))
+		return true
+	}
+
+	// The first tile is reserved by the next stepEntity, which also handles
+	// it being occupied.
+	movement.Path = path
+	movement.PathIndex = 1
+	movement.HasTarget = false
+	if !w.isAtTileCenter(entity) && stepCost(path[0], path[1]) == diagonalStepCost {
+		// Re-center before a diagonal step so the straight-line move cannot
+		// clip the corners canStep already ruled out.
+		movement.PathIndex = 0
+	}
+	w.notePath(entity)
+
+	return true
+}
+
+func (w *World) stepEntity(entity *Entity, step float64) {
+	movement := entity.Movement
+	if movement.PathIndex >= len(movement.Path) {
+		movement.Path = nil
+		movement.PathIndex = 0
+		movement.HasTarget = false
+		return
+	}
+
+	if !movement.HasTarget && !w.commitNext(entity) {
+		return
+	}
+
+	dx := float64(movement.TargetX - entity.X)
+	dy := float64(movement.TargetY - entity.Y)
+	distance := math.Hypot(dx, dy)
+	if distance == 0 {
+		movement.PathIndex += 1
+		movement.HasTarget = false
+		if movement.PathIndex >= len(movement.Path) {
+			w.completePath(entity)
+		}
+		return
+	}
+
+	if distance <= step {
+		entity.X = movement.TargetX
+		entity.Y = movement.TargetY
+		movement.PathIndex += 1
+		movement.HasTarget = false
+		if movement.PathIndex >= len(movement.Path) {
+			w.completePath(entity)
+		}
+		w.chunks.move(entity, w.chunkOf(entity))
+		w.dirty = true
+		if entity.Kind == EntityPlayer {
+			w.enterPortal(entity)
+		}
+		return
+	}
+
+	ratio := step / distance
+	entity.X += int(math.Round(dx * ratio))
+	entity.Y += int(math.Round(dy * ratio))
+	w.chunks.move(entity, w.chunkOf(entity))
+	w.dirty = true
+}
@@ -0,0 +1,113 @@
+package engine
+
+import "testing"
+
+// countingBehaviour counts the ticks it was updated on.
+type countingBehaviour struct {
+	updates int
+}
+
+func (b *countingBehaviour) Update(w *World, e *Entity, deltaSeconds float64) {
+	b.updates += 1
+}
+
+func TestEntityLifecycle(t *testing.T) {
+	w := NewWorld(testMap([]string{"......"}, false))
+	w.AddPlayer("alice", nil)
+	first := w.AddEntity(EntityObject, w.tileCenter(1), w.tileCenter(0), false, nil)
+	second := w.AddEntity(EntityNPC, w.tileCenter(2), w.tileCenter(0), true, nil)
+	if second <= first {
+		t.Fatalf("entity ids %d then %d, want increasing", first, second)
+	}
+
+	removed, ok := w.RemoveEntity(first)
+	if !ok || removed.ID != first || removed.Kind != EntityObject {
+		t.Fatalf("RemoveEntity(%d) = %+v, %v", first, removed, ok)
+	}
+	if _, ok := w.Entity(first); ok {
+		t.Fatal("found an entity after removing it")
+	}
+	if third := w.AddEntity(EntityObject, w.tileCenter(1), w.tileCenter(0), false, nil); third <= second {
+		t.Fatalf("id %d was reused after a removal", third)
+	}
+
+	player := w.SnapshotPlayers()[0]
+	if _, ok := w.RemoveEntity(player.EntityID); ok {
+		t.Fatal("RemoveEntity removed a player")
+	}
+	if len(w.SnapshotPlayers()) != 1 {
+		t.Fatal("player left through RemoveEntity")
+	}
+}
+
+func TestEntityNetID(t *testing.T) {
+	tests := []struct {
+		name   string
+		entity Entity
+		want   string
+	}{
+		{name: "player", entity: Entity{ID: 4, Kind: EntityPlayer, PlayerID: "alice"}, want: "alice"},
+		{name: "npc", entity: Entity{ID: 4, Kind: EntityNPC}, want: "e4"},
+		{name: "object", entity: Entity{ID: 12, Kind: EntityObject}, want: "e12"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.entity.NetID(); got != tt.want {
+				t.Fatalf("NetID() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEntitySnapshotsAreCopies(t *testing.T) {
+	w := NewWorld(testMap([]string{"......"}, false))
+	id := w.AddEntity(EntityNPC, w.tileCenter(0), w.tileCenter(0), true, &countingBehaviour{})
+
+	snapshot, _ := w.Entity(id)
+	if snapshot.Behaviour != nil {
+		t.Fatal("snapshot exposes the behaviour")
+	}
+	snapshot.Movement.TargetX = 999
+	snapshot.X = 999
+
+	again, _ := w.Entity(id)
+	if again.X == 999 || again.Movement.TargetX == 999 {
+		t.Fatal("changing a snapshot changed the world")
+	}
+}
+
+func TestEntitiesMoveAndUpdate(t *testing.T) {
+	tests := []struct {
+		name      string
+		movable   bool
+		wantMoved bool
+	}{
+		{name: "movable", movable: true, wantMoved: true},
+		{name: "fixed", movable: false, wantMoved: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewWorld(testMap([]string{"......"}, false))
+			behaviour := &countingBehaviour{}
+			id := w.AddEntity(EntityNPC, w.tileCenter(0), w.tileCenter(0), tt.movable, behaviour)
+
+			if got := w.SetEntityTarget(id, w.tileCenter(5), w.tileCenter(0)); got != tt.movable {
+				t.Fatalf("SetEntityTarget() = %v, want %v", got, tt.movable)
+			}
+			for i := 0; i < 100; i += 1 {
+				w.Step(0.05)
+			}
+
+			if behaviour.updates != 100 {
+				t.Fatalf("behaviour updated %d times, want 100", behaviour.updates)
+			}
+			entity, _ := w.Entity(id)
+			moved := entity.X == w.tileCenter(5)
+			if moved != tt.wantMoved {
+				t.Fatalf("entity at x=%d, moved = %v, want %v", entity.X, moved, tt.wantMoved)
+			}
+		})
+	}
+}
@@ -0,0 +1,312 @@
+package engine
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultEventQueueSize is the queue length of asynchronous subscribers that
+// do not pick one.
+const DefaultEventQueueSize = 256
+
+// Event is anything published on an EventBus. EventName identifies the kind
+// of event in logs and analytics.
+type Event interface {
+	EventName() string
+}
+
+// EventMeta is embedded in every world event. Tick is the tick the event
+// belongs to: the one being stepped, or the next one for changes made
+// between Steps. Map is the name the WorldManager hosts the world under.
+type EventMeta struct {
+	Map  string
+	Tick int64
+}
+
+type PlayerJoined struct {
+	EventMeta
+	PlayerID string
+	Entity   EntityID
+	Position Position
+}
+
+type PlayerLeft struct {
+	EventMeta
+	PlayerID string
+	Entity   EntityID
+	Position Position
+}
+
+// PlayerChangedMap is published by the WorldManager after a portal moved a
+// player, following its PlayerLeft and PlayerJoined events.
+type PlayerChangedMap struct {
+	EventMeta
+	PlayerID string
+	From     string
+}
+
+// EntityMoved reports an entity crossing into another tile.
+type EntityMoved struct {
+	EventMeta
+	Entity   EntityID
+	Kind     EntityKind
+	PlayerID string
+	From     MapPoint
+	To       MapPoint
+}
+
+// PathCompleted reports an entity arriving at the end of its path.
+type PathCompleted struct {
+	EventMeta
+	Entity   EntityID
+	Kind     EntityKind
+	PlayerID string
+	Goal     MapPoint
+}
+
+// PathCancelled reports an entity stopping before the end of its path.
+type PathCancelled struct {
+	EventMeta
+	Entity   EntityID
+	Kind     EntityKind
+	PlayerID string
+}
+
+type RegionEntered struct {
+	EventMeta
+	PlayerID string
+	Entity   EntityID
+	Region   Region
+}
+
+type RegionLeft struct {
+	EventMeta
+	PlayerID string
+	Entity   EntityID
+	Region   Region
+}
+
+// ObjectInteracted reports a player acting on an object; State is the
+// object's state afterwards.
+type ObjectInteracted struct {
+	EventMeta
+	PlayerID string
+	Object   EntityID
+	Type     string
+	Action   string
+	State    ObjectState
+}
+
+type ObjectRespawned struct {
+	EventMeta
+	Object EntityID
+	Type   string
+}
+
+type NPCSpawned struct {
+	EventMeta
+	Entity   EntityID
+	Type     string
+	Position Position
+}
+
+type NPCDespawned struct {
+	EventMeta
+	Entity EntityID
+	Type   string
+}
+
+type MapReloaded struct {
+	EventMeta
+}
+
+// TileChanged reports a tile set to another tile type while the world runs.
+type TileChanged struct {
+	EventMeta
+	X    int
+	Y    int
+	From int
+	To   int
+}
+
+func (PlayerJoined) EventName() string     { return "player_joined" }
+func (PlayerLeft) EventName() string       { return "player_left" }
+func (PlayerChangedMap) EventName() string { return "player_changed_map" }
+func (EntityMoved) EventName() string      { return "entity_moved" }
+func (PathCompleted) EventName() string    { return "path_completed" }
+func (PathCancelled) EventName() string    { return "path_cancelled" }
+func (RegionEntered) EventName() string    { return "region_entered" }
+func (RegionLeft) EventName() string       { return "region_left" }
+func (ObjectInteracted) EventName() string { return "object_interacted" }
+func (ObjectRespawned) EventName() string  { return "object_respawned" }
+func (NPCSpawned) EventName() string       { return "npc_spawned" }
+func (NPCDespawned) EventName() string     { return "npc_despawned" }
+func (MapReloaded) EventName() string      { return "map_reloaded" }
+func (TileChanged) EventName() string      { return "tile_changed" }
+
+// EventBus fans events out to subscribers. Synchronous subscribers run on the
+// publishing goroutine, in subscription order; asynchronous ones each get a
+// bounded queue drained by their own goroutine.
+type EventBus struct {
+	mu       sync.RWMutex
+	nextID   int
+	handlers []eventHandler
+	async    []*AsyncSubscriber
+}
+
+type eventHandler struct {
+	id     int
+	handle func(Event)
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{}
+}
+
+// Subscribe registers a synchronous handler for events of type E, or for
+// every event when E is Event, and returns a function that removes it.
+//
+// World events are published as the world call that produced them returns:
+// events from a Step reach handlers, in the order they happened, before Step
+// returns and after the world lock is released, so handlers may call back
+// into the world. They should be quick; slow work belongs in SubscribeAsync.
+func Subscribe[E Event](bus *EventBus, handler func(E)) func() {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+
+	bus.nextID += 1
+	id := bus.nextID
+	bus.handlers = append(bus.handlers, eventHandler{id: id, handle: func(event Event) {
+		if typed, ok := event.(E); ok {
+			handler(typed)
+		}
+	}})
+
+	return func() {
+		bus.mu.Lock()
+		defer bus.mu.Unlock()
+
+		for i, registered := range bus.handlers {
+			if registered.id == id {
+				bus.handlers = append(bus.handlers[:i:i], bus.handlers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// AsyncSubscriber handles events on its own goroutine. Publishing never
+// waits for it: events that do not fit in its queue are dropped and counted.
+type AsyncSubscriber struct {
+	bus     *EventBus
+	queue   chan Event
+	accepts func(Event) bool
+	done    chan struct{}
+	dropped atomic.Uint64
+	once    sync.Once
+}
+
+// SubscribeAsync registers handler for events of type E, or every event when
+// E is Event, behind a queue of queueSize events (DefaultEventQueueSize when
+// not positive).
+func SubscribeAsync[E Event](bus *EventBus, queueSize int, handler func(E)) *AsyncSubscriber {
+	if queueSize <= 0 {
+		queueSize = DefaultEventQueueSize
+	}
+
+	subscriber := &AsyncSubscriber{
+		bus:   bus,
+		queue: make(chan Event, queueSize),
+		accepts: func(event Event) bool {
+			_, ok := event.(E)
+			return ok
+		},
+		done: make(chan struct{}),
+	}
+	go func() {
+		defer close(subscriber.done)
+		for event := range subscriber.queue {
+			handler(event.(E))
+		}
+	}()
+
+	bus.mu.Lock()
+	bus.async = append(bus.async, subscriber)
+	bus.mu.Unlock()
+
+	return subscriber
+}
+
+// Dropped returns how many events did not fit in the queue.
+func (s *AsyncSubscriber) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Close unsubscribes and waits for the events already queued to be handled.
+func (s *AsyncSubscriber) Close() {
+	s.once.Do(func() {
+		s.bus.mu.Lock()
+		for i, subscriber := range s.bus.async {
+			if subscriber == s {
+				s.bus.async = append(s.bus.async[:i:i], s.bus.async[i+1:]...)
+				break
+			}
+		}
+		close(s.queue)
+		s.bus.mu.Unlock()
+	})
+	<-s.done
+}
+
+// Publish delivers events to every subscriber, in order.
+func (b *EventBus) Publish(events ...Event) {
+	if len(events) == 0 {
+		return
+	}
+
+	b.mu.RLock()
+	handlers := b.handlers
+	b.mu.RUnlock()
+
+	for _, event := range events {
+		for _, handler := range handlers {
+			handler.handle(event)
+		}
+
+		// Queues are filled under the read lock so Close cannot close one
+		// mid-send.
+		b.mu.RLock()
+		for _, subscriber := range b.async {
+			if !subscriber.accepts(event) {
+				continue
+			}
+			select {
+			case subscriber.queue <- event:
+			default:
+				subscriber.dropped.Add(1)
+			}
+		}
+		b.mu.RUnlock()
+	}
+}
+
+// Events returns the bus the world publishes on, shared by every world of a
+// WorldManager.
+func (w *World) Events() *EventBus {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	return w.bus
+}
+
+// meta stamps an event raised now.
+func (w *World) meta() EventMeta {
+	return EventMeta{Map: w.name, Tick: w.tick + 1}
+}
+
+// emit queues an event for publication once the world lock is released.
+// Callers must hold w.mu and release it with unlockAndNotify, or the event
+// waits for the next call that does.
+func (w *World) emit(event Event) {
+	w.events = append(w.events, event)
+}
@@ -0,0 +1,124 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSubscribe(t *testing.T) {
+	bus := NewEventBus()
+	var joins []string
+	var all []string
+	Subscribe(bus, func(event PlayerJoined) { joins = append(joins, event.PlayerID) })
+	unsubscribe := Subscribe(bus, func(event Event) { all = append(all, event.EventName()) })
+
+	bus.Publish(PlayerJoined{PlayerID: "alice"}, PlayerLeft{PlayerID: "alice"})
+	unsubscribe()
+	bus.Publish(PlayerJoined{PlayerID: "bob"})
+
+	if want := []string{"alice", "bob"}; !reflect.DeepEqual(joins, want) {
+		t.Fatalf("join subscriber got %v, want %v", joins, want)
+	}
+	if want := []string{"player_joined", "player_left"}; !reflect.DeepEqual(all, want) {
+		t.Fatalf("catch-all subscriber got %v, want %v", all, want)
+	}
+}
+
+func TestSubscribeAsync(t *testing.T) {
+	bus := NewEventBus()
+	started := make(chan struct{}, 3)
+	release := make(chan struct{})
+	var handled []string
+	subscriber := SubscribeAsync(bus, 2, func(event PlayerJoined) {
+		started <- struct{}{}
+		<-release
+		handled = append(handled, event.PlayerID)
+	})
+
+	// The first event is taken off the queue while the handler waits, so
+	// two more fit and the rest are dropped. Events of other types never
+	// reach the queue.
+	bus.Publish(PlayerJoined{PlayerID: "a"})
+	<-started
+	bus.Publish(PlayerLeft{PlayerID: "a"}, PlayerJoined{PlayerID: "b"}, PlayerJoined{PlayerID: "c"}, PlayerJoined{PlayerID: "d"})
+	if got := subscriber.Dropped(); got != 1 {
+		t.Fatalf("Dropped() = %d, want 1", got)
+	}
+
+	close(release)
+	subscriber.Close()
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(handled, want) {
+		t.Fatalf("handled %v, want %v", handled, want)
+	}
+
+	// Publishing after Close must not reach the closed queue.
+	bus.Publish(PlayerJoined{PlayerID: "e"})
+}
+
+func TestWorldEvents(t *testing.T) {
+	w := NewWorld(corridor(4))
+	var names []string
+	var ticks []int64
+	Subscribe(w.Events(), func(event Event) {
+		names = append(names, event.EventName())
+		// Subscribers run without the world lock and may read the world.
+		w.SnapshotPlayers()
+	})
+	Subscribe(w.Events(), func(event EntityMoved) { ticks = append(ticks, event.Tick) })
+
+	w.AddPlayer("alice", &Position{X: w.tileCenter(0), Y: w.tileCenter(0)})
+	w.SetPlayerTarget("alice", w.tileCenter(2), w.tileCenter(0))
+	for i := 0; i < 30; i += 1 {
+		w.Step(0.05)
+	}
+	w.SetPlayerTarget("alice", w.tileCenter(0), w.tileCenter(0))
+	w.Step(0.05)
+	w.SetPlayerTarget("alice", w.tileCenter(2), w.tileCenter(0))
+	w.RemovePlayer("alice")
+
+	want := []string{
+		"player_joined",
+		"entity_moved", "entity_moved", "path_completed",
+		"path_cancelled",
+		"player_left",
+	}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("events = %v, want %v", names, want)
+	}
+	for i := 1; i < len(ticks); i += 1 {
+		if ticks[i] <= ticks[i-1] {
+			t.Fatalf("moves on ticks %v, want increasing", ticks)
+		}
+	}
+}
+
+func TestWorldManagerEvents(t *testing.T) {
+	town := corridor(3)
+	town.Portals = []Portal{{X: 2, Y: 0, Map: "mine"}}
+	m := NewWorldManager()
+	townWorld, _ := m.AddMap("town", town)
+	m.AddMap("mine", openMap(4))
+
+	var got []string
+	Subscribe(m.Events(), func(event Event) {
+		switch event := event.(type) {
+		case PlayerJoined:
+			got = append(got, "joined "+event.Map)
+		case PlayerLeft:
+			got = append(got, "left "+event.Map)
+		case PlayerChangedMap:
+			got = append(got, "changed "+event.From+" to "+event.Map)
+		}
+	})
+
+	m.AddPlayer("alice", "town", &Position{X: townWorld.tileCenter(0), Y: townWorld.tileCenter(0)})
+	townWorld.SetPlayerTarget("alice", townWorld.tileCenter(2), townWorld.tileCenter(0))
+	for i := 0; i < 30; i += 1 {
+		m.Step(0.05)
+	}
+
+	want := []string{"joined town", "left town", "joined mine", "changed town to mine"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,483 @@
+package engine
+
+import "sort"
+
+// DefaultHierarchicalMinTiles is the map area, in tiles, from which routes
+// spanning several clusters are planned on the path hierarchy instead of
+// with a single search over the whole grid.
+const DefaultHierarchicalMinTiles = 256 * 256
+
+// hierarchyClusterSize is the edge length of the square clusters the
+// hierarchy splits the map into.
+const hierarchyClusterSize = 16
+
+// entranceSplitLength is the shortest opening between two clusters that
+// gets a transition at each end rather than one in the middle.
+const entranceSplitLength = 6
+
+// pathHierarchy is an HPA*-style abstraction of the map. Where two clusters
+// touch, every opening between them has one or two transitions: pairs of
+// facing walkable tiles, one on each side. The transition tiles are the
+// nodes of an abstract graph, linked to their partner across the border and
+// to the other nodes of their cluster by the cost of the best route that
+// stays inside it. The graph is built on the first hierarchical search and
+// tile changes only rebuild the borders and clusters they touch. Access is
+// guarded by World.mu.
+type pathHierarchy struct {
+	built         bool
+	columns       int
+	rows          int
+	clusters      []hierarchyCluster
+	borders       [][]transition
+	dirtyClusters []int
+	dirtyBorders  []int
+	clusterDirty  []bool
+	borderDirty   []bool
+}
+
+// transition links a tile to the facing one in the cluster east or south of
+// it.
+type transition struct {
+	from int32
+	to   int32
+}
+
+// hierarchyCluster holds the nodes of one cluster, sorted by tile index.
+// cost[i][j] is the cost from node i to node j inside the cluster, -1 when
+// there is no such route, and partners[i] the tiles node i links to across
+// borders.
+type hierarchyCluster struct {
+	nodes    []int32
+	index    map[int32]int
+	cost     [][]int
+	partners [][]int32
+}
+
+// tileRect is a rectangle of tiles from X0, Y0 up to, but excluding, X1, Y1.
+type tileRect struct {
+	X0, Y0, X1, Y1 int
+}
+
+func (r tileRect) contains(tile tilePoint) bool {
+	return tile.X >= r.X0 && tile.Y >= r.Y0 && tile.X < r.X1 && tile.Y < r.Y1
+}
+
+// SetHierarchicalPathMinTiles sets the map area from which long routes use
+// the path hierarchy. A limit <= 0 disables it.
+func (w *World) SetHierarchicalPathMinTiles(limit int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.hierarchyMinTiles = limit
+}
+
+// useHierarchy reports whether a route is long enough, on a map large enough,
+// to be planned on the hierarchy. Routes between neighbouring clusters are
+// cheap to search directly and would not gain from it.
+func (w *World) useHierarchy(start, goal tilePoint) bool {
+	if w.hierarchyMinTiles <= 0 || w.mapWidth*w.mapHeight < w.hierarchyMinTiles {
+		return false
+	}
+
+	dx := absInt(start.X/hierarchyClusterSize - goal.X/hierarchyClusterSize)
+	dy := absInt(start.Y/hierarchyClusterSize - goal.Y/hierarchyClusterSize)
+	return dx > 1 || dy > 1
+}
+
+// findHierarchicalPath searches the abstract graph between the start and
+// goal tiles, then refines each step of the abstract route into tiles with a
+// search bounded to one cluster. Like findPath it avoids tiles held by other
+// entities when self is set, falling back to ignoring them for a cluster
+// they block completely. maxPathNodes caps the abstract nodes expanded, as
+// it caps tiles in a flat search, and each refinement.
+func (w *World) findHierarchicalPath(start, goal tilePoint, self *Entity) []tilePoint {
+	h := &w.hierarchy
+	h.refresh(w)
+
+	startIndex := w.tileIndex(start.X, start.Y)
+	goalIndex := w.tileIndex(goal.X, goal.Y)
+	startCluster := h.clusterOf(start)
+	goalCluster := h.clusterOf(goal)
+	startBounds := h.bounds(w, startCluster)
+	goalBounds := h.bounds(w, goalCluster)
+	fromStart := w.clusterCosts(start, startBounds, false)
+	toGoal := w.clusterCosts(goal, goalBounds, true)
+
+	// Abstract nodes are tiles, so the search reuses the tile buffers. The
+	// route is read back before refinement runs searches of its own.
+	s := &w.search
+	s.reset(w.mapWidth * w.mapHeight)
+	s.seen[startIndex] = s.generation
+	s.gScore[startIndex] = 0
+	s.cameFrom[startIndex] = -1
+	startH := w.heuristic(start, goal)
+	s.open.push(pathNode{index: startIndex, f: startH, h: startH})
+
+	relax := func(from, to int32, cost int) {
+		if s.closed[to] == s.generation {
+			return
+		}
+		tentative := s.gScore[from] + cost
+		if s.seen[to] == s.generation && tentative >= s.gScore[to] {
+			return
+		}
+		s.seen[to] = s.generation
+		s.gScore[to] = tentative
+		s.cameFrom[to] = from
+		estimate := w.heuristic(w.tileAt(to), goal)
+		s.open.push(pathNode{index: to, f: tentative + estimate, h: estimate})
+	}
+
+	found := false
+	expanded := 0
+	for len(s.open) > 0 {
+		current := s.open.pop()
+		if s.closed[current.index] == s.generation {
+			continue
+		}
+		if current.index == goalIndex {
+			found = true
+			break
+		}
+		s.closed[current.index] = s.generation
+		expanded += 1
+		if w.maxPathNodes > 0 && expanded > w.maxPathNodes {
+			return nil
+		}
+
+		point := w.tileAt(current.index)
+		cluster := h.clusterOf(point)
+		if current.index == startIndex {
+			for _, node := range h.clusters[startCluster].nodes {
+				if cost := fromStart[startBounds.offset(w.tileAt(node))]; cost > 0 {
+					relax(current.index, node, cost)
+				}
+			}
+		}
+		if i, ok := h.clusters[cluster].index[current.index]; ok {
+			nodes := &h.clusters[cluster]
+			for j, node := range nodes.nodes {
+				if cost := nodes.cost[i][j]; cost > 0 {
+					relax(current.index, node, cost)
+				}
+			}
+			for _, partner := range nodes.partners[i] {
+				relax(current.index, partner, straightStepCost*w.tiles.cost[partner])
+			}
+		}
+		if cluster == goalCluster {
+			if cost := toGoal[goalBounds.offset(point)]; cost >= 0 {
+				relax(current.index, goalIndex, cost)
+			}
+		}
+	}
+	if !found {
+		return nil
+	}
+
+	var route []int32
+	for index := goalIndex; index >= 0; index = s.cameFrom[index] {
+		route = append(route, index)
+	}
+
+	path := []tilePoint{start}
+	for i := len(route) - 1; i > 0; i -= 1 {
+		from, to := w.tileAt(route[i]), w.tileAt(route[i-1])
+		if h.clusterOf(from) != h.clusterOf(to) {
+			path = append(path, to)
+			continue
+		}
+
+		bounds := h.bounds(w, h.clusterOf(from))
+		segment := w.searchPath(from, to, self, &bounds, w.maxPathNodes)
+		if segment == nil && self != nil {
+			segment = w.searchPath(from, to, nil, &bounds, w.maxPathNodes)
+		}
+		if segment == nil {
+			return nil
+		}
+		path = append(path, segment[1:]...)
+	}
+
+	return path
+}
+
+// clusterCosts runs Dijkstra from origin without leaving bounds and returns,
+// for every tile in bounds in row order, the cost of the best route from
+// origin to it, or to origin from it when reverse is set; -1 marks tiles
+// that cannot be reached.
+func (w *World) clusterCosts(origin tilePoint, bounds tileRect, reverse bool) []int {
+	costs := make([]int, (bounds.X1-bounds.X0)*(bounds.Y1-bounds.Y0))
+	for i := range costs {
+		costs[i] = -1
+	}
+	costs[bounds.offset(origin)] = 0
+
+	directions := straightDirections
+	if w.diagonal {
+		directions = allDirections
+	}
+
+	open := pathHeap{{index: w.tileIndex(origin.X, origin.Y)}}
+	for len(open) > 0 {
+		current := open.pop()
+		point := w.tileAt(current.index)
+		if current.f > costs[bounds.offset(point)] {
+			continue
+		}
+
+		for _, direction := range directions {
+			next := tilePoint{X: point.X + direction.X, Y: point.Y + direction.Y}
+			if !bounds.contains(next) {
+				continue
+			}
+
+			var cost int
+			if reverse {
+				if !w.isWalkable(next.X, next.Y) || !w.canStep(next, point) {
+					continue
+				}
+				cost = current.f + stepCost(next, point)*w.tiles.cost[current.index]
+			} else {
+				if !w.canStep(point, next) {
+					continue
+				}
+				cost = current.f + stepCost(point, next)*w.tiles.cost[w.tileIndex(next.X, next.Y)]
+			}
+
+			offset := bounds.offset(next)
+			if costs[offset] >= 0 && cost >= costs[offset] {
+				continue
+			}
+			costs[offset] = cost
+			open.push(pathNode{index: w.tileIndex(next.X, next.Y), f: cost})
+		}
+	}
+
+	return costs
+}
+
+func (r tileRect) offset(tile tilePoint) int {
+	return (tile.Y-r.Y0)*(r.X1-r.X0) + tile.X - r.X0
+}
+
+func (h *pathHierarchy) clusterOf(tile tilePoint) int {
+	return (tile.Y/hierarchyClusterSize)*h.columns + tile.X/hierarchyClusterSize
+}
+
+func (h *pathHierarchy) bounds(w *World, cluster int) tileRect {
+	x0 := (cluster % h.columns) * hierarchyClusterSize
+	y0 := (cluster / h.columns) * hierarchyClusterSize
+
+	return tileRect{
+		X0: x0,
+		Y0: y0,
+		X1: min(x0+hierarchyClusterSize, w.mapWidth),
+		Y1: min(y0+hierarchyClusterSize, w.mapHeight),
+	}
+}
+
+// reset drops the graph so the next hierarchical search rebuilds it.
+func (h *pathHierarchy) reset() {
+	*h = pathHierarchy{}
+}
+
+// invalidate schedules the cluster holding the tile for a rebuild, along with
+// the borders the tile lies on.
+func (h *pathHierarchy) invalidate(w *World, tile tilePoint) {
+	if !h.built {
+		return
+	}
+
+	cluster := h.clusterOf(tile)
+	h.markCluster(cluster)
+
+	bounds := h.bounds(w, cluster)
+	column, row := cluster%h.columns, cluster/h.columns
+	if tile.X == bounds.X0 && column > 0 {
+		h.markBorder(2 * (cluster - 1))
+	}
+	if tile.X == bounds.X1-1 && column < h.columns-1 {
+		h.markBorder(2 * cluster)
+	}
+	if tile.Y == bounds.Y0 && row > 0 {
+		h.markBorder(2*(cluster-h.columns) + 1)
+	}
+	if tile.Y == bounds.Y1-1 && row < h.rows-1 {
+		h.markBorder(2*cluster + 1)
+	}
+}
+
+func (h *pathHierarchy) markCluster(cluster int) {
+	if !h.clusterDirty[cluster] {
+		h.clusterDirty[cluster] = true
+		h.dirtyClusters = append(h.dirtyClusters, cluster)
+	}
+}
+
+func (h *pathHierarchy) markBorder(border int) {
+	if !h.borderDirty[border] {
+		h.borderDirty[border] = true
+		h.dirtyBorders = append(h.dirtyBorders, border)
+	}
+}
+
+// refresh builds the graph, or rebuilds the parts invalidated since the last
+// search. A rebuilt border changes the nodes of the clusters on both sides.
+func (h *pathHierarchy) refresh(w *World) {
+	if !h.built {
+		h.columns = (w.mapWidth + hierarchyClusterSize - 1) / hierarchyClusterSize
+		h.rows = (w.mapHeight + hierarchyClusterSize - 1) / hierarchyClusterSize
+		count := h.columns * h.rows
+		h.clusters = make([]hierarchyCluster, count)
+		h.borders = make([][]transition, 2*count)
+		h.clusterDirty = make([]bool, count)
+		h.borderDirty = make([]bool, 2*count)
+		h.dirtyClusters = h.dirtyClusters[:0]
+		h.dirtyBorders = h.dirtyBorders[:0]
+		for border := range h.borders {
+			h.markBorder(border)
+		}
+		h.built = true
+	}
+
+	for _, border := range h.dirtyBorders {
+		h.borderDirty[border] = false
+		h.borders[border] = h.findTransitions(w, border)
+
+		cluster := border / 2
+		h.markCluster(cluster)
+		if border%2 == 0 && cluster%h.columns < h.columns-1 {
+			h.markCluster(cluster + 1)
+		}
+		if border%2 == 1 && cluster/h.columns < h.rows-1 {
+			h.markCluster(cluster + h.columns)
+		}
+	}
+	h.dirtyBorders = h.dirtyBorders[:0]
+
+	for _, cluster := range h.dirtyClusters {
+		h.clusterDirty[cluster] = false
+		h.buildCluster(w, cluster)
+	}
+	h.dirtyClusters = h.dirtyClusters[:0]
+}
+
+// findTransitions scans a border for openings: runs of tiles walkable on
+// both sides. Border 2c lies east of cluster c and border 2c+1 south of it.
+func (h *pathHierarchy) findTransitions(w *World, border int) []transition {
+	cluster := border / 2
+	east := border%2 == 0
+	bounds := h.bounds(w, cluster)
+	if east && bounds.X1 >= w.mapWidth || !east && bounds.Y1 >= w.mapHeight {
+		return nil
+	}
+
+	facing := func(i int) (tilePoint, tilePoint) {
+		if east {
+			return tilePoint{X: bounds.X1 - 1, Y: bounds.Y0 + i}, tilePoint{X: bounds.X1, Y: bounds.Y0 + i}
+		}
+		return tilePoint{X: bounds.X0 + i, Y: bounds.Y1 - 1}, tilePoint{X: bounds.X0 + i, Y: bounds.Y1}
+	}
+	length := bounds.X1 - bounds.X0
+	if east {
+		length = bounds.Y1 - bounds.Y0
+	}
+
+	var transitions []transition
+	add := func(i int) {
+		from, to := facing(i)
+		transitions = append(transitions, transition{from: w.tileIndex(from.X, from.Y), to: w.tileIndex(to.X, to.Y)})
+	}
+	for i := 0; i < length; {
+		from, to := facing(i)
+		if !w.isWalkable(from.X, from.Y) || !w.isWalkable(to.X, to.Y) {
+			i += 1
+			continue
+		}
+
+		end := i
+		for end+1 < length {
+			nextFrom, nextTo := facing(end + 1)
+			if !w.isWalkable(nextFrom.X, nextFrom.Y) || !w.isWalkable(nextTo.X, nextTo.Y) {
+				break
+			}
+			end += 1
+		}
+
+		if end-i+1 >= entranceSplitLength {
+			add(i)
+			add(end)
+		} else {
+			add((i + end) / 2)
+		}
+		i = end + 1
+	}
+
+	return transitions
+}
+
+// buildCluster collects the cluster's nodes from the four borders around it
+// and computes the costs between them.
+func (h *pathHierarchy) buildCluster(w *World, cluster int) {
+	partners := make(map[int32][]int32)
+	link := func(border int, west bool) {
+		for _, transition := range h.borders[border] {
+			if west {
+				partners[transition.from] = append(partners[transition.from], transition.to)
+			} else {
+				partners[transition.to] = append(partners[transition.to], transition.from)
+			}
+		}
+	}
+	column, row := cluster%h.columns, cluster/h.columns
+	link(2*cluster, true)
+	link(2*cluster+1, true)
+	if column > 0 {
+		link(2*(cluster-1), false)
+	}
+	if row > 0 {
+		link(2*(cluster-h.columns)+1, false)
+	}
+
+	nodes := make([]int32, 0, len(partners))
+	for node := range partners {
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i] < nodes[j] })
+
+	built := hierarchyCluster{
+		nodes:    nodes,
+		index:    make(map[int32]int, len(nodes)),
+		cost:     make([][]int, len(nodes)),
+		partners: make([][]int32, len(nodes)),
+	}
+	bounds := h.bounds(w, cluster)
+	for i, node := range nodes {
+		built.index[node] = i
+		built.partners[i] = partners[node]
+
+		costs := w.clusterCosts(w.tileAt(node), bounds, false)
+		built.cost[i] = make([]int, len(nodes))
+		for j, other := range nodes {
+			built.cost[i][j] = costs[bounds.offset(w.tileAt(other))]
+		}
+	}
+
+	h.clusters[cluster] = built
+}
+
+// invalidateChangedTiles updates the hierarchy after the tile grid was
+// replaced by one of the same size, touching only the tiles whose
+// walkability or cost changed.
+func (w *World) invalidateChangedTiles(previous tileGrid) {
+	if !w.hierarchy.built {
+		return
+	}
+
+	for index := range w.tiles.walkable {
+		if previous.walkable[index] != w.tiles.walkable[index] || previous.cost[index] != w.tiles.cost[index] {
+			w.hierarchy.invalidate(w, w.tileAt(int32(index)))
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package engine
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// hierarchyWorld returns a world that plans every route spanning more than
+// neighbouring clusters on the hierarchy, without a node budget.
+func hierarchyWorld(data MapData) *World {
+	w := NewWorld(data)
+	w.SetHierarchicalPathMinTiles(1)
+	w.SetMaxPathNodes(0)
+
+	return w
+}
+
+// compareWithGrid plans routes between random tile pairs on the hierarchy
+// and checks them against the cheapest routes on the full grid: both must
+// agree on reachability, and hierarchical routes may only be a little
+// longer.
+func compareWithGrid(t *testing.T, w *World, seed int64, pairs int) {
+	t.Helper()
+
+	rng := rand.New(rand.NewSource(seed))
+	total, optimal := 0, 0
+	for compared := 0; compared < pairs; {
+		start := tilePoint{X: rng.Intn(w.mapWidth), Y: rng.Intn(w.mapHeight)}
+		goal := tilePoint{X: rng.Intn(w.mapWidth), Y: rng.Intn(w.mapHeight)}
+		if !w.isWalkable(start.X, start.Y) || !w.isWalkable(goal.X, goal.Y) || !w.useHierarchy(start, goal) {
+			continue
+		}
+		compared += 1
+
+		want := referenceCost(w, start, goal)
+		path := w.findPath(start, goal, nil)
+		if want < 0 {
+			if path != nil {
+				t.Fatalf("found path %v -> %v, want none", start, goal)
+			}
+			continue
+		}
+		if path == nil {
+			t.Fatalf("found no path %v -> %v", start, goal)
+		}
+
+		cost := checkPath(t, w, path, start, goal)
+		if cost*2 > want*3 {
+			t.Fatalf("path %v -> %v costs %d, over 1.5 times the best %d", start, goal, cost, want)
+		}
+		total += cost
+		optimal += want
+	}
+	if total*10 > optimal*11 {
+		t.Fatalf("paths cost %d in total, over 1.1 times the best %d", total, optimal)
+	}
+}
+
+func TestHierarchicalPathMatchesGrid(t *testing.T) {
+	tests := []struct {
+		name     string
+		size     int
+		density  float64
+		diagonal bool
+	}{
+		{name: "open", size: 96, density: 0.05, diagonal: true},
+		{name: "scattered walls", size: 96, density: 0.2, diagonal: true},
+		{name: "scattered walls, straight", size: 96, density: 0.2, diagonal: false},
+		{name: "dense walls", size: 128, density: 0.35, diagonal: false},
+		{name: "uneven edge", size: 101, density: 0.25, diagonal: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := hierarchyWorld(noiseMap(tt.size, tt.density, 4, tt.diagonal))
+			compareWithGrid(t, w, 1, 100)
+		})
+	}
+}
+
+func TestHierarchyFollowsMapChanges(t *testing.T) {
+	const size = 96
+	wall := size / 2
+
+	// split has a wall across the middle with a single door.
+	split := func(diagonal bool) MapData {
+		data := noiseMap(size, 0.1, 8, diagonal)
+		for y := 1; y < size-1; y += 1 {
+			data.Tiles[y][wall] = TileWall
+		}
+		data.Tiles[size/2][wall] = TileGrass
+		return data
+	}
+	west := tilePoint{X: 5, Y: 5}
+	east := tilePoint{X: size - 6, Y: size - 6}
+
+	tests := []struct {
+		name      string
+		change    func(t *testing.T, w *World)
+		wantFound bool
+	}{
+		{
+			name:      "unchanged",
+			change:    func(t *testing.T, w *World) {},
+			wantFound: true,
+		},
+		{
+			name: "door closed",
+			change: func(t *testing.T, w *World) {
+				if err := w.SetTile(wall, size/2, TileWall); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantFound: false,
+		},
+		{
+			name: "door moved",
+			change: func(t *testing.T, w *World) {
+				if err := w.SetTile(wall, size/2, TileWall); err != nil {
+					t.Fatal(err)
+				}
+				if err := w.SetTile(wall, 10, TileGrass); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantFound: true,
+		},
+		{
+			name: "reloaded without a door",
+			change: func(t *testing.T, w *World) {
+				data := split(true)
+				data.Tiles[size/2][wall] = TileWall
+				if err := w.ReloadMap(data); err != nil {
+					t.Fatal(err)
+				}
+				w.Step(0)
+			},
+			wantFound: false,
+		},
+		{
+			name: "reloaded with straight movement",
+			change: func(t *testing.T, w *World) {
+				if err := w.ReloadMap(split(false)); err != nil {
+					t.Fatal(err)
+				}
+				w.Step(0)
+			},
+			wantFound: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := hierarchyWorld(split(true))
+			for _, tile := range []tilePoint{west, east} {
+				w.tiles.walkable[w.tileIndex(tile.X, tile.Y)] = true
+			}
+			if w.findPath(west, east, nil) == nil {
+				t.Fatal("found no path before the change")
+			}
+
+			tt.change(t, w)
+			path := w.findPath(west, east, nil)
+			if found := path != nil; found != tt.wantFound {
+				t.Fatalf("found = %v, want %v", found, tt.wantFound)
+			}
+			if path != nil {
+				checkPath(t, w, path, west, east)
+			}
+			compareWithGrid(t, w, 2, 40)
+		})
+	}
+}
+
+func TestHierarchicalPathNodeBudget(t *testing.T) {
+	tests := []struct {
+		name      string
+		limit     int
+		wantFound bool
+	}{
+		{name: "unlimited", limit: 0, wantFound: true},
+		{name: "generous", limit: 10000, wantFound: true},
+		{name: "exhausted", limit: 3, wantFound: false},
+	}
+
+	rows := make([]string, 96)
+	for y := range rows {
+		row := make([]byte, 96)
+		for x := range row {
+			row[x] = '.'
+		}
+		rows[y] = string(row)
+	}
+	start := tilePoint{X: 0, Y: 0}
+	goal := tilePoint{X: 95, Y: 95}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := hierarchyWorld(testMap(rows, true))
+			w.SetMaxPathNodes(tt.limit)
+			if !w.useHierarchy(start, goal) {
+				t.Fatal("route is not planned on the hierarchy")
+			}
+			if found := w.findPath(start, goal, nil) != nil; found != tt.wantFound {
+				t.Fatalf("found = %v, want %v", found, tt.wantFound)
+			}
+		})
+	}
+}
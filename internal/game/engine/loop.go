@@ -2,40 +2,172 @@ package engine
 
 import (
 	"context"
+	"sync"
 	"time"
 )
 
+// DefaultMaxCatchUpSteps limits how many fixed steps a single wake-up may run
+// after a stall; older backlog is dropped instead of spiralling.
+const DefaultMaxCatchUpSteps = 5
+
+// Clock abstracts time so the loop can be driven deterministically.
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTicker(d time.Duration) Ticker {
+	return systemTicker{ticker: time.NewTicker(d)}
+}
+
+type systemTicker struct {
+	ticker *time.Ticker
+}
+
+func (t systemTicker) C() <-chan time.Time {
+	return t.ticker.C
+}
+
+func (t systemTicker) Stop() {
+	t.ticker.Stop()
+}
+
+// TickOverrun describes a single tick whose callback took longer than the
+// tick rate.
+type TickOverrun struct {
+	Tick     int64
+	Duration time.Duration
+}
+
+// LoopStats summarises loop health since Start.
+type LoopStats struct {
+	Ticks        int64
+	Overruns     int64
+	WorstTick    time.Duration
+	DroppedSteps int64
+}
+
 type Loop struct {
-	tickRate time.Duration
-	onTick   func(tick int64, delta time.Duration)
+	tickRate   time.Duration
+	onTick     func(tick int64, delta time.Duration)
+	onOverrun  func(TickOverrun)
+	clock      Clock
+	maxCatchUp int
+
+	mu    sync.Mutex
+	stats LoopStats
 }
 
 func NewLoop(tickRate time.Duration, onTick func(tick int64, delta time.Duration)) *Loop {
 	return &Loop{
-		tickRate: tickRate,
-		onTick:   onTick,
+		tickRate:   tickRate,
+		onTick:     onTick,
+		clock:      systemClock{},
+		maxCatchUp: DefaultMaxCatchUpSteps,
 	}
 }
 
+// SetClock replaces the time source. It must be called before Start.
+func (l *Loop) SetClock(clock Clock) {
+	l.clock = clock
+}
+
+// SetMaxCatchUpSteps caps the fixed steps run per wake-up. It must be called
+// before Start; values below one are treated as one.
+func (l *Loop) SetMaxCatchUpSteps(steps int) {
+	if steps < 1 {
+		steps = 1
+	}
+	l.maxCatchUp = steps
+}
+
+// SetOverrunHandler registers a callback invoked on the loop goroutine after
+// any tick that overran. It must be called before Start.
+func (l *Loop) SetOverrunHandler(handler func(TickOverrun)) {
+	l.onOverrun = handler
+}
+
+func (l *Loop) Stats() LoopStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.stats
+}
+
+// Start runs fixed steps of tickRate for as long as ctx is alive. Each
+// wake-up measures real elapsed time and runs as many steps as it covers, up
+// to the catch-up cap, so a slow tick delays later ones rather than losing
+// them.
 func (l *Loop) Start(ctx context.Context) {
 	if l.tickRate <= 0 {
 		return
 	}
 
-	ticker := time.NewTicker(l.tickRate)
+	ticker := l.clock.NewTicker(l.tickRate)
 	defer ticker.Stop()
 
 	var tick int64
+	var accumulator time.Duration
+	last := l.clock.Now()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-ticker.C:
-			tick += 1
-			if l.onTick != nil {
-				l.onTick(tick, l.tickRate)
+		case <-ticker.C():
+			now := l.clock.Now()
+			accumulator += now.Sub(last)
+			last = now
+
+			steps := 0
+			for accumulator >= l.tickRate && steps < l.maxCatchUp {
+				tick += 1
+				steps += 1
+				accumulator -= l.tickRate
+				l.runTick(tick)
+			}
+
+			if accumulator >= l.tickRate {
+				dropped := int64(accumulator / l.tickRate)
+				accumulator -= time.Duration(dropped) * l.tickRate
+				l.mu.Lock()
+				l.stats.DroppedSteps += dropped
+				l.mu.Unlock()
 			}
 		}
 	}
 }
+
+func (l *Loop) runTick(tick int64) {
+	started := l.clock.Now()
+	if l.onTick != nil {
+		l.onTick(tick, l.tickRate)
+	}
+	duration := l.clock.Now().Sub(started)
+
+	overran := duration > l.tickRate
+	l.mu.Lock()
+	l.stats.Ticks += 1
+	if overran {
+		l.stats.Overruns += 1
+	}
+	if duration > l.stats.WorstTick {
+		l.stats.WorstTick = duration
+	}
+	l.mu.Unlock()
+
+	if overran && l.onOverrun != nil {
+		l.onOverrun(TickOverrun{Tick: tick, Duration: duration})
+	}
+}
@@ -0,0 +1,153 @@
+package engine
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock only moves when told to. Its ticker reports on ready each time
+// the loop comes back to wait for a tick, so a test never advances the clock
+// while a wake-up is still running.
+type fakeClock struct {
+	mu    sync.Mutex
+	now   time.Time
+	ticks chan time.Time
+	ready chan struct{}
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{
+		now:   time.Unix(0, 0),
+		ticks: make(chan time.Time),
+		ready: make(chan struct{}, 1),
+	}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *fakeClock) NewTicker(time.Duration) Ticker {
+	return c
+}
+
+func (c *fakeClock) C() <-chan time.Time {
+	select {
+	case c.ready <- struct{}{}:
+	default:
+	}
+
+	return c.ticks
+}
+
+func (c *fakeClock) Stop() {}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+// wake lets d pass and fires the ticker once the loop is waiting for it.
+func (c *fakeClock) wake(d time.Duration) {
+	<-c.ready
+	c.advance(d)
+	c.ticks <- c.Now()
+}
+
+// runLoop starts loop on clock, wakes it after each of elapsed and returns
+// once it has finished the last wake-up.
+func runLoop(loop *Loop, clock *fakeClock, elapsed ...time.Duration) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		loop.Start(ctx)
+		close(done)
+	}()
+
+	for _, d := range elapsed {
+		clock.wake(d)
+	}
+	<-clock.ready
+	cancel()
+	<-done
+}
+
+func TestLoopRunsFixedSteps(t *testing.T) {
+	const rate = 10 * time.Millisecond
+	clock := newFakeClock()
+	var ticks []int64
+	loop := NewLoop(rate, func(tick int64, delta time.Duration) {
+		if delta != rate {
+			t.Errorf("tick %d delta = %v, want %v", tick, delta, rate)
+		}
+		ticks = append(ticks, tick)
+	})
+	loop.SetClock(clock)
+
+	// Late and early wake-ups are evened out: 25ms runs two steps and
+	// leaves 5ms for the next one.
+	runLoop(loop, clock, rate, 25*time.Millisecond, 5*time.Millisecond, 3*time.Millisecond)
+
+	if want := []int64{1, 2, 3, 4}; !reflect.DeepEqual(ticks, want) {
+		t.Fatalf("ticks = %v, want %v", ticks, want)
+	}
+	if stats := loop.Stats(); stats != (LoopStats{Ticks: 4}) {
+		t.Fatalf("stats = %+v, want 4 clean ticks", stats)
+	}
+}
+
+func TestLoopCapsCatchUp(t *testing.T) {
+	clock := newFakeClock()
+	ticks := 0
+	loop := NewLoop(10*time.Millisecond, func(int64, time.Duration) { ticks += 1 })
+	loop.SetClock(clock)
+	loop.SetMaxCatchUpSteps(3)
+
+	// A 105ms stall runs three steps and drops the seven it cannot afford;
+	// the leftover 5ms carries over.
+	runLoop(loop, clock, 105*time.Millisecond, 5*time.Millisecond)
+
+	if ticks != 4 {
+		t.Fatalf("ran %d ticks, want 4", ticks)
+	}
+	if stats := loop.Stats(); stats.Ticks != 4 || stats.DroppedSteps != 7 {
+		t.Fatalf("stats = %+v, want 4 ticks and 7 dropped steps", stats)
+	}
+}
+
+func TestLoopReportsOverruns(t *testing.T) {
+	const rate = 10 * time.Millisecond
+	clock := newFakeClock()
+	loop := NewLoop(rate, func(tick int64, _ time.Duration) {
+		switch tick {
+		case 1:
+			clock.advance(rate)
+		case 2:
+			clock.advance(25 * time.Millisecond)
+		}
+	})
+	loop.SetClock(clock)
+	var overruns []TickOverrun
+	loop.SetOverrunHandler(func(overrun TickOverrun) {
+		overruns = append(overruns, overrun)
+	})
+
+	runLoop(loop, clock, rate, rate, rate)
+
+	// A tick that takes exactly the tick rate is not an overrun.
+	if want := []TickOverrun{{Tick: 2, Duration: 25 * time.Millisecond}}; !reflect.DeepEqual(overruns, want) {
+		t.Fatalf("overruns = %+v, want %+v", overruns, want)
+	}
+	stats := loop.Stats()
+	if stats.Overruns != 1 || stats.WorstTick != 25*time.Millisecond {
+		t.Fatalf("stats = %+v, want one 25ms overrun", stats)
+	}
+}
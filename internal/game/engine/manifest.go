@@ -0,0 +1,89 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// MapManifest lists the maps a server hosts and the one new players join,
+// the first map when Default is empty.
+type MapManifest struct {
+	Default string             `json:"default,omitempty"`
+	Maps    []MapManifestEntry `json:"maps"`
+}
+
+// MapManifestEntry names a map file. Relative paths are resolved against the
+// manifest's directory when the manifest is loaded.
+type MapManifestEntry struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+// LoadMapManifest reads and validates a map manifest.
+func LoadMapManifest(path string) (MapManifest, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return MapManifest{}, err
+	}
+
+	var manifest MapManifest
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		return MapManifest{}, err
+	}
+
+	if err := validateMapManifest(manifest); err != nil {
+		return MapManifest{}, err
+	}
+
+	dir := filepath.Dir(path)
+	for i, entry := range manifest.Maps {
+		if !filepath.IsAbs(entry.Path) {
+			manifest.Maps[i].Path = filepath.Join(dir, entry.Path)
+		}
+	}
+	if manifest.Default == "" {
+		manifest.Default = manifest.Maps[0].Name
+	}
+
+	return manifest, nil
+}
+
+// EntryForPath returns the entry whose map file is path.
+func (m MapManifest) EntryForPath(path string) (MapManifestEntry, bool) {
+	path = filepath.Clean(path)
+	for _, entry := range m.Maps {
+		if filepath.Clean(entry.Path) == path {
+			return entry, true
+		}
+	}
+
+	return MapManifestEntry{}, false
+}
+
+func validateMapManifest(manifest MapManifest) error {
+	if len(manifest.Maps) == 0 {
+		return fmt.Errorf("manifest lists no maps")
+	}
+
+	names := make(map[string]struct{}, len(manifest.Maps))
+	for i, entry := range manifest.Maps {
+		if entry.Name == "" {
+			return fmt.Errorf("map %d has no name", i)
+		}
+		if entry.Path == "" {
+			return fmt.Errorf("map %q has no path", entry.Name)
+		}
+		if _, ok := names[entry.Name]; ok {
+			return fmt.Errorf("duplicate map %q", entry.Name)
+		}
+		names[entry.Name] = struct{}{}
+	}
+
+	if _, ok := names[manifest.Default]; manifest.Default != "" && !ok {
+		return fmt.Errorf("default map %q is not listed", manifest.Default)
+	}
+
+	return nil
+}
@@ -0,0 +1,89 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadMapManifest(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    MapManifest
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			raw:  `{"default": "mine", "maps": [{"name": "town", "path": "town.json"}, {"name": "mine", "path": "/srv/mine.tmj"}]}`,
+			want: MapManifest{Default: "mine", Maps: []MapManifestEntry{
+				{Name: "town", Path: "town.json"},
+				{Name: "mine", Path: "/srv/mine.tmj"},
+			}},
+		},
+		{
+			name: "first map is the default",
+			raw:  `{"maps": [{"name": "town", "path": "town.json"}]}`,
+			want: MapManifest{Default: "town", Maps: []MapManifestEntry{{Name: "town", Path: "town.json"}}},
+		},
+		{name: "no maps", raw: `{"maps": []}`, wantErr: true},
+		{name: "no name", raw: `{"maps": [{"path": "town.json"}]}`, wantErr: true},
+		{name: "no path", raw: `{"maps": [{"name": "town"}]}`, wantErr: true},
+		{name: "duplicate name", raw: `{"maps": [{"name": "town", "path": "a.json"}, {"name": "town", "path": "b.json"}]}`, wantErr: true},
+		{name: "unknown default", raw: `{"default": "cave", "maps": [{"name": "town", "path": "town.json"}]}`, wantErr: true},
+		{name: "corrupt", raw: `{"maps": [`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "maps.json")
+			if err := os.WriteFile(path, []byte(tt.raw), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			manifest, err := LoadMapManifest(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadMapManifest() error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			for i, entry := range tt.want.Maps {
+				if !filepath.IsAbs(entry.Path) {
+					tt.want.Maps[i].Path = filepath.Join(dir, entry.Path)
+				}
+			}
+			if !reflect.DeepEqual(manifest, tt.want) {
+				t.Fatalf("LoadMapManifest() = %+v, want %+v", manifest, tt.want)
+			}
+
+			entry, ok := manifest.EntryForPath(filepath.Join(dir, ".", "town.json"))
+			if !ok || entry.Name != "town" {
+				t.Fatalf("EntryForPath(town.json) = %+v, %v", entry, ok)
+			}
+		})
+	}
+}
+
+func TestShippedMapManifest(t *testing.T) {
+	manifest, err := LoadMapManifest(filepath.Join("..", "..", "..", "shared", "maps", "maps.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewWorldManager()
+	for _, entry := range manifest.Maps {
+		data, err := LoadMapData(entry.Path)
+		if err != nil {
+			t.Fatalf("map %q: %v", entry.Name, err)
+		}
+		if _, err := m.AddMap(entry.Name, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.ValidatePortals(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -4,26 +4,87 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 )
 
 const DefaultMapWidth = 100
 const DefaultMapHeight = 100
 
 type MapData struct {
-	Width  int     `json:"width"`
-	Height int     `json:"height"`
-	Tiles  [][]int `json:"tiles"`
+	Width      int            `json:"width"`
+	Height     int            `json:"height"`
+	Tiles      [][]int        `json:"tiles"`
+	Diagonal   bool           `json:"diagonal,omitempty"`
+	Collision  bool           `json:"collision,omitempty"`
+	TileTypes  []TileType     `json:"tileTypes,omitempty"`
+	Spawns     []SpawnPoint   `json:"spawns,omitempty"`
+	Markers    []MapMarker    `json:"markers,omitempty"`
+	Objects    []MapObject    `json:"objects,omitempty"`
+	Portals    []Portal       `json:"portals,omitempty"`
+	Regions    []Region       `json:"regions,omitempty"`
+	Properties map[string]any `json:"properties,omitempty"`
 }
 
+// MapMarker is a named point or region authored on the map, in tile units.
+// Rectangles cover X..X+Width-1 and Y..Y+Height-1; polygons use Polygon and
+// leave Width and Height at zero.
+type MapMarker struct {
+	ID         int            `json:"id"`
+	Name       string         `json:"name"`
+	Type       string         `json:"type,omitempty"`
+	Layer      string         `json:"layer,omitempty"`
+	X          int            `json:"x"`
+	Y          int            `json:"y"`
+	Width      int            `json:"width,omitempty"`
+	Height     int            `json:"height,omitempty"`
+	Polygon    []MapPoint     `json:"polygon,omitempty"`
+	Properties map[string]any `json:"properties,omitempty"`
+}
+
+type MapPoint struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// Contains reports whether the tile at (x, y) lies inside the marker.
+func (m MapMarker) Contains(x, y int) bool {
+	if len(m.Polygon) == 0 {
+		return x >= m.X && y >= m.Y && x < m.X+m.Width && y < m.Y+m.Height
+	}
+
+	// Even-odd test against the tile center.
+	px := float64(x) + 0.5
+	py := float64(y) + 0.5
+	inside := false
+	for i, j := 0, len(m.Polygon)-1; i < len(m.Polygon); j, i = i, i+1 {
+		a := m.Polygon[i]
+		b := m.Polygon[j]
+		if (float64(a.Y) > py) == (float64(b.Y) > py) {
+			continue
+		}
+		crossX := float64(b.X-a.X)*(py-float64(a.Y))/float64(b.Y-a.Y) + float64(a.X)
+		if px < crossX {
+			inside = !inside
+		}
+	}
+
+	return inside
+}
+
+// LoadMapData reads either the native map format or a Tiled JSON export,
+// which is converted with DefaultTiledOptions.
 func LoadMapData(path string) (MapData, error) {
-	file, err := os.Open(path)
+	raw, err := os.ReadFile(path)
 	if err != nil {
 		return MapData{}, err
 	}
-	defer file.Close()
+
+	if isTiledMap(raw) {
+		return parseTiledMap(raw, filepath.Dir(path), DefaultTiledOptions())
+	}
 
 	var data MapData
-	if err := json.NewDecoder(file).Decode(&data); err != nil {
+	if err := json.Unmarshal(raw, &data); err != nil {
 		return MapData{}, err
 	}
 
@@ -36,9 +97,13 @@ func LoadMapData(path string) (MapData, error) {
 
 func DefaultMapData(width, height int) MapData {
 	return MapData{
-		Width:  width,
-		Height: height,
-		Tiles:  buildMapTiles(width, height),
+		Width:     width,
+		Height:    height,
+		Tiles:     buildMapTiles(width, height),
+		TileTypes: DefaultTileTypes(),
+		Spawns: []SpawnPoint{
+			{Name: SpawnDefault, X: width / 2, Y: height / 2},
+		},
 	}
 }
 
@@ -57,7 +122,24 @@ func validateMapData(data MapData) error {
 		}
 	}
 
-	return nil
+	if err := validateTileTypes(data.TileTypes); err != nil {
+		return err
+	}
+
+	grid := newTileGrid(data)
+	if err := validateSpawns(data, grid); err != nil {
+		return err
+	}
+
+	if err := validateObjects(data, grid); err != nil {
+		return err
+	}
+
+	if err := validateRegions(data); err != nil {
+		return err
+	}
+
+	return validatePortals(data, grid)
 }
 
 func buildMapTiles(width, height int) [][]int {
@@ -67,12 +149,12 @@ func buildMapTiles(width, height int) [][]int {
 		row := make([]int, 0, width)
 		for x := 0; x < width; x += 1 {
 			isBorder := x == 0 || y == 0 || x == width-1 || y == height-1
-			tileIndex := 0
+			tileIndex := TileGrass
 
 			if isBorder {
-				tileIndex = 2
+				tileIndex = TileWall
 			} else if (x+y)%7 == 0 {
-				tileIndex = 1
+				tileIndex = TileDirt
 			}
 
 			row = append(row, tileIndex)
@@ -0,0 +1,351 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+type NPCBehaviourType string
+
+const (
+	NPCIdle   NPCBehaviourType = "idle"
+	NPCWander NPCBehaviourType = "wander"
+	NPCPatrol NPCBehaviourType = "patrol"
+)
+
+// npcSpawnAttempts bounds the random tile picks made before falling back to a
+// breadth-first search for a free tile.
+const npcSpawnAttempts = 16
+
+// NPCDefinition describes a group of identical NPCs. Count of them (at least
+// one) spawn within the spawn area and each comes back RespawnSeconds after
+// it is despawned, moving at Speed (DefaultMoveSpeed when zero). Map is only
+// used by hosts running several maps to pick the World the definition
+// belongs to.
+type NPCDefinition struct {
+	ID             string       `json:"id"`
+	Name           string       `json:"name,omitempty"`
+	Map            string       `json:"map,omitempty"`
+	Count          int          `json:"count,omitempty"`
+	Spawn          NPCSpawnArea `json:"spawn"`
+	Behaviour      NPCBehaviour `json:"behaviour"`
+	RespawnSeconds float64      `json:"respawnSeconds,omitempty"`
+	Speed          float64      `json:"speed,omitempty"`
+}
+
+// NPCSpawnArea is a square of tiles Radius tiles around X, Y.
+type NPCSpawnArea struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Radius int `json:"radius,omitempty"`
+}
+
+// NPCBehaviour configures how an NPC moves. Wander picks tiles within Radius
+// of the NPC's spawn tile, Patrol walks Route (tile coordinates) in a loop.
+// Both wait between PauseMin and PauseMax seconds at every stop.
+type NPCBehaviour struct {
+	Type     NPCBehaviourType `json:"type"`
+	Radius   int              `json:"radius,omitempty"`
+	Route    []MapPoint       `json:"route,omitempty"`
+	PauseMin float64          `json:"pauseMin,omitempty"`
+	PauseMax float64          `json:"pauseMax,omitempty"`
+}
+
+type npcPayload struct {
+	NPCs []NPCDefinition `json:"npcs"`
+}
+
+type npcRespawn struct {
+	def       *NPCDefinition
+	remaining float64
+}
+
+// LoadNPCDefinitions reads NPC definitions from a JSON file. They are checked
+// against the map when passed to World.SpawnNPCs.
+func LoadNPCDefinitions(path string) ([]NPCDefinition, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var payload npcPayload
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return nil, err
+	}
+
+	return payload.NPCs, nil
+}
+
+func (d NPCDefinition) count() int {
+	if d.Count <= 0 {
+		return 1
+	}
+
+	return d.Count
+}
+
+func (w *World) validateNPCDefinitions(defs []NPCDefinition) error {
+	seen := make(map[string]struct{}, len(defs))
+	for _, def := range defs {
+		if def.ID == "" {
+			return fmt.Errorf("npc definition without an id")
+		}
+		if _, ok := seen[def.ID]; ok {
+			return fmt.Errorf("duplicate npc definition %q", def.ID)
+		}
+		seen[def.ID] = struct{}{}
+
+		if !w.inBounds(def.Spawn.X, def.Spawn.Y) {
+			return fmt.Errorf("npc %q spawns outside the map", def.ID)
+		}
+		if def.Spawn.Radius < 0 || def.Behaviour.Radius < 0 {
+			return fmt.Errorf("npc %q has a negative radius", def.ID)
+		}
+		if def.Speed < 0 {
+			return fmt.Errorf("npc %q has a negative speed", def.ID)
+		}
+		if def.RespawnSeconds < 0 {
+			return fmt.Errorf("npc %q has a negative respawn delay", def.ID)
+		}
+		if def.Behaviour.PauseMin < 0 || def.Behaviour.PauseMax < def.Behaviour.PauseMin {
+			return fmt.Errorf("npc %q has an invalid pause range", def.ID)
+		}
+
+		switch def.Behaviour.Type {
+		case NPCIdle, NPCWander:
+		case NPCPatrol:
+			if len(def.Behaviour.Route) == 0 {
+				return fmt.Errorf("npc %q patrols an empty route", def.ID)
+			}
+			for _, point := range def.Behaviour.Route {
+				if !w.isWalkable(point.X, point.Y) {
+					return fmt.Errorf("npc %q patrol point %d,%d is not walkable", def.ID, point.X, point.Y)
+				}
+			}
+		default:
+			return fmt.Errorf("npc %q has unknown behaviour %q", def.ID, def.Behaviour.Type)
+		}
+	}
+
+	return nil
+}
+
+// SpawnNPCs validates defs against the map and spawns every NPC they declare,
+// replacing NPCs from an earlier call. Spawn tiles and wander goals come from
+// the world's seeded random source, so replays must spawn the same
+// definitions after the same SetSeed.
+func (w *World) SpawnNPCs(defs []NPCDefinition) error {
+	w.mu.Lock()
+	defer w.unlockAndNotify()
+
+	if err := w.validateNPCDefinitions(defs); err != nil {
+		return err
+	}
+
+	for _, entity := range append([]*Entity(nil), w.order...) {
+		if _, ok := w.npcs[entity.ID]; ok {
+			w.removeEntityLocked(entity)
+		}
+	}
+	w.respawns = nil
+	w.npcDefs = append([]NPCDefinition(nil), defs...)
+
+	for i := range w.npcDefs {
+		def := &w.npcDefs[i]
+		for n := 0; n < def.count(); n += 1 {
+			w.spawnNPC(def)
+		}
+	}
+
+	return nil
+}
+
+// DespawnNPC removes an NPC and schedules it to come back after its
+// definition's respawn delay.
+func (w *World) DespawnNPC(id EntityID) bool {
+	w.mu.Lock()
+	defer w.unlockAndNotify()
+
+	return w.despawnNPC(id)
+}
+
+func (w *World) despawnNPC(id EntityID) bool {
+	def, ok := w.npcs[id]
+	if !ok {
+		return false
+	}
+
+	w.removeEntityLocked(w.entities[id])
+	w.emit(NPCDespawned{EventMeta: w.meta(), Entity: id, Type: def.ID})
+	w.respawns = append(w.respawns, npcRespawn{def: def, remaining: def.RespawnSeconds})
+	if w.recorder != nil {
+		w.recorder.recordEvent(RecordedEvent{Type: RecordedDespawn, Entity: id})
+	}
+
+	return true
+}
+
+// NPCDefinitionOf returns the definition an NPC entity was spawned from.
+func (w *World) NPCDefinitionOf(id EntityID) (NPCDefinition, bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	def, ok := w.npcs[id]
+	if !ok {
+		return NPCDefinition{}, false
+	}
+
+	return *def, true
+}
+
+func (w *World) spawnNPC(def *NPCDefinition) {
+	origin := tilePoint{X: def.Spawn.X, Y: def.Spawn.Y}
+	tile := w.randomFreeTile(origin, def.Spawn.Radius)
+
+	entity := &Entity{
+		Kind: EntityNPC,
+		Type: def.ID,
+		X:    w.tileCenter(tile.X),
+		Y:    w.tileCenter(tile.Y),
+	}
+
+	config := def.Behaviour
+	switch config.Type {
+	case NPCWander:
+		entity.Behaviour = &wanderBehaviour{home: tile, radius: config.Radius, pauseMin: config.PauseMin, pauseMax: config.PauseMax}
+	case NPCPatrol:
+		route := make([]tilePoint, 0, len(config.Route))
+		for _, point := range config.Route {
+			route = append(route, tilePoint{X: point.X, Y: point.Y})
+		}
+		entity.Behaviour = &patrolBehaviour{route: route, pauseMin: config.PauseMin, pauseMax: config.PauseMax}
+	}
+	if entity.Behaviour != nil {
+		entity.Movement = &Movement{TargetX: entity.X, TargetY: entity.Y, Speed: def.Speed}
+	}
+
+	w.addEntityLocked(entity)
+	w.npcs[entity.ID] = def
+	w.emit(NPCSpawned{EventMeta: w.meta(), Entity: entity.ID, Type: def.ID, Position: Position{X: entity.X, Y: entity.Y}})
+}
+
+// respawnNPCs counts down pending respawns and brings back the ones that
+// are due, in the order they were despawned.
+func (w *World) respawnNPCs(deltaSeconds float64) {
+	if len(w.respawns) == 0 || deltaSeconds <= 0 {
+		return
+	}
+
+	pending := w.respawns[:0]
+	for _, respawn := range w.respawns {
+		respawn.remaining -= deltaSeconds
+		if respawn.remaining <= 0 {
+			w.spawnNPC(respawn.def)
+			continue
+		}
+		pending = append(pending, respawn)
+	}
+	w.respawns = pending
+}
+
+// randomFreeTile picks a free walkable tile within radius of origin, falling
+// back to the nearest free tile when random picks keep missing.
+func (w *World) randomFreeTile(origin tilePoint, radius int) tilePoint {
+	if radius > 0 {
+		for attempt := 0; attempt < npcSpawnAttempts; attempt += 1 {
+			tile := w.randomTileWithin(origin, radius)
+			if w.isWalkable(tile.X, tile.Y) && !w.tileHasOtherEntity(tile, 0) {
+				return tile
+			}
+		}
+	}
+
+	return w.nearestFreeTile(origin, 0)
+}
+
+func (w *World) randomTileWithin(origin tilePoint, radius int) tilePoint {
+	return tilePoint{
+		X: origin.X + w.rng.Intn(2*radius+1) - radius,
+		Y: origin.Y + w.rng.Intn(2*radius+1) - radius,
+	}
+}
+
+func (w *World) randomPause(min, max float64) float64 {
+	if max <= min {
+		return min
+	}
+
+	return min + w.rng.Float64()*(max-min)
+}
+
+func (w *World) inBounds(tileX, tileY int) bool {
+	return tileX >= 0 && tileY >= 0 && tileX < w.mapWidth && tileY < w.mapHeight
+}
+
+func isMoving(entity *Entity) bool {
+	return entity.Movement != nil && entity.Movement.Path != nil
+}
+
+// failedPlanPause is how long, in seconds, an NPC waits after failing to
+// plan a path before searching again, so an unreachable goal does not cost
+// a search every tick.
+const failedPlanPause = 1.0
+
+// wanderBehaviour walks to random tiles around home, pausing between moves.
+type wanderBehaviour struct {
+	home     tilePoint
+	radius   int
+	pauseMin float64
+	pauseMax float64
+	wait     float64
+}
+
+func (b *wanderBehaviour) Update(w *World, e *Entity, deltaSeconds float64) {
+	if isMoving(e) || b.radius == 0 {
+		return
+	}
+	if b.wait > 0 {
+		b.wait -= deltaSeconds
+		return
+	}
+
+	goal := w.randomTileWithin(b.home, b.radius)
+	b.wait = w.randomPause(b.pauseMin, b.pauseMax)
+	if !w.setEntityPath(e, goal) {
+		b.wait = max(b.wait, failedPlanPause)
+	}
+}
+
+// patrolBehaviour walks its route in order and loops, pausing at each point.
+// Unreachable points are skipped.
+type patrolBehaviour struct {
+	route    []tilePoint
+	next     int
+	pauseMin float64
+	pauseMax float64
+	wait     float64
+}
+
+func (b *patrolBehaviour) Update(w *World, e *Entity, deltaSeconds float64) {
+	if isMoving(e) {
+		return
+	}
+	if b.wait > 0 {
+		b.wait -= deltaSeconds
+		return
+	}
+
+	goal := b.route[b.next]
+	tileX, tileY := w.toTileCoords(e.X, e.Y)
+	if tileX == goal.X && tileY == goal.Y {
+		b.next = (b.next + 1) % len(b.route)
+		b.wait = w.randomPause(b.pauseMin, b.pauseMax)
+		return
+	}
+
+	if !w.setEntityPath(e, goal) {
+		b.next = (b.next + 1) % len(b.route)
+		b.wait = failedPlanPause
+	}
+}
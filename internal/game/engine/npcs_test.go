@@ -0,0 +1,296 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func openMap(size int) MapData {
+	rows := make([]string, size)
+	for y := range rows {
+		row := make([]byte, size)
+		for x := range row {
+			row[x] = '.'
+		}
+		rows[y] = string(row)
+	}
+
+	return testMap(rows, false)
+}
+
+func npcEntities(w *World) []Entity {
+	var npcs []Entity
+	for _, entity := range w.SnapshotEntities() {
+		if entity.Kind == EntityNPC {
+			npcs = append(npcs, entity)
+		}
+	}
+
+	return npcs
+}
+
+func TestSpawnNPCsValidates(t *testing.T) {
+	data := testMap([]string{
+		".....",
+		".#...",
+		".....",
+	}, false)
+	idle := NPCBehaviour{Type: NPCIdle}
+
+	tests := []struct {
+		name    string
+		defs    []NPCDefinition
+		wantErr bool
+	}{
+		{name: "valid", defs: []NPCDefinition{{ID: "a", Behaviour: idle}, {ID: "b", Behaviour: NPCBehaviour{Type: NPCPatrol, Route: []MapPoint{{X: 4, Y: 2}}}}}},
+		{name: "missing id", defs: []NPCDefinition{{Behaviour: idle}}, wantErr: true},
+		{name: "duplicate id", defs: []NPCDefinition{{ID: "a", Behaviour: idle}, {ID: "a", Behaviour: idle}}, wantErr: true},
+		{name: "spawn outside the map", defs: []NPCDefinition{{ID: "a", Spawn: NPCSpawnArea{X: 5}, Behaviour: idle}}, wantErr: true},
+		{name: "negative spawn radius", defs: []NPCDefinition{{ID: "a", Spawn: NPCSpawnArea{Radius: -1}, Behaviour: idle}}, wantErr: true},
+		{name: "negative wander radius", defs: []NPCDefinition{{ID: "a", Behaviour: NPCBehaviour{Type: NPCWander, Radius: -1}}}, wantErr: true},
+		{name: "negative speed", defs: []NPCDefinition{{ID: "a", Behaviour: idle, Speed: -1}}, wantErr: true},
+		{name: "negative respawn", defs: []NPCDefinition{{ID: "a", Behaviour: idle, RespawnSeconds: -1}}, wantErr: true},
+		{name: "inverted pause", defs: []NPCDefinition{{ID: "a", Behaviour: NPCBehaviour{Type: NPCWander, PauseMin: 2, PauseMax: 1}}}, wantErr: true},
+		{name: "empty patrol", defs: []NPCDefinition{{ID: "a", Behaviour: NPCBehaviour{Type: NPCPatrol}}}, wantErr: true},
+		{name: "patrol through a wall", defs: []NPCDefinition{{ID: "a", Behaviour: NPCBehaviour{Type: NPCPatrol, Route: []MapPoint{{X: 1, Y: 1}}}}}, wantErr: true},
+		{name: "unknown behaviour", defs: []NPCDefinition{{ID: "a", Behaviour: NPCBehaviour{Type: "dance"}}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewWorld(data)
+			err := w.SpawnNPCs(tt.defs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SpawnNPCs() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && len(npcEntities(w)) != 0 {
+				t.Fatal("invalid definitions spawned NPCs")
+			}
+		})
+	}
+}
+
+func TestSpawnNPCsPlacesEveryNPC(t *testing.T) {
+	w := NewWorld(openMap(16))
+	defs := []NPCDefinition{
+		{ID: "flock", Count: 5, Spawn: NPCSpawnArea{X: 8, Y: 8, Radius: 2}, Behaviour: NPCBehaviour{Type: NPCIdle}},
+		{ID: "single", Spawn: NPCSpawnArea{X: 1, Y: 1}, Behaviour: NPCBehaviour{Type: NPCIdle}},
+	}
+	if err := w.SpawnNPCs(defs); err != nil {
+		t.Fatal(err)
+	}
+
+	npcs := npcEntities(w)
+	if len(npcs) != 6 {
+		t.Fatalf("spawned %d NPCs, want 6", len(npcs))
+	}
+	tiles := make(map[tilePoint]bool)
+	for _, npc := range npcs {
+		tileX, tileY := w.toTileCoords(npc.X, npc.Y)
+		tile := tilePoint{X: tileX, Y: tileY}
+		if tiles[tile] {
+			t.Fatalf("two NPCs spawned on %v", tile)
+		}
+		tiles[tile] = true
+
+		def, ok := w.NPCDefinitionOf(npc.ID)
+		if !ok {
+			t.Fatalf("NPC %d has no definition", npc.ID)
+		}
+		if absInt(tileX-def.Spawn.X) > def.Spawn.Radius || absInt(tileY-def.Spawn.Y) > def.Spawn.Radius {
+			t.Fatalf("NPC %q spawned at %v, outside its spawn area", def.ID, tile)
+		}
+	}
+
+	// Spawning again replaces the earlier NPCs.
+	if err := w.SpawnNPCs(defs[1:]); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(npcEntities(w)); got != 1 {
+		t.Fatalf("%d NPCs after respawning one definition, want 1", got)
+	}
+}
+
+func TestDespawnedNPCsRespawn(t *testing.T) {
+	tests := []struct {
+		name    string
+		delay   float64
+		steps   int
+		wantNPC bool
+	}{
+		{name: "before the delay", delay: 1, steps: 10, wantNPC: false},
+		{name: "after the delay", delay: 1, steps: 21, wantNPC: true},
+		{name: "no delay", delay: 0, steps: 1, wantNPC: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewWorld(openMap(8))
+			def := NPCDefinition{ID: "a", Spawn: NPCSpawnArea{X: 4, Y: 4}, Behaviour: NPCBehaviour{Type: NPCIdle}, RespawnSeconds: tt.delay}
+			if err := w.SpawnNPCs([]NPCDefinition{def}); err != nil {
+				t.Fatal(err)
+			}
+			id := npcEntities(w)[0].ID
+			if !w.DespawnNPC(id) {
+				t.Fatal("DespawnNPC reported an unknown NPC")
+			}
+			if w.DespawnNPC(id) {
+				t.Fatal("despawned an NPC twice")
+			}
+
+			for i := 0; i < tt.steps; i += 1 {
+				w.Step(0.05)
+			}
+			npcs := npcEntities(w)
+			if got := len(npcs) == 1; got != tt.wantNPC {
+				t.Fatalf("NPC present = %v, want %v", got, tt.wantNPC)
+			}
+			if tt.wantNPC {
+				if npcs[0].ID == id {
+					t.Fatal("respawned NPC reused the old entity id")
+				}
+				if got, _ := w.NPCDefinitionOf(npcs[0].ID); got.ID != def.ID {
+					t.Fatalf("respawned NPC has definition %q, want %q", got.ID, def.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestNPCBehaviours(t *testing.T) {
+	tests := []struct {
+		name      string
+		behaviour NPCBehaviour
+		// check is called with the NPC's tile after every Step.
+		check func(t *testing.T, tile tilePoint)
+		// wantVisited lists tiles the NPC must have stood on.
+		wantVisited []tilePoint
+		wantMoved   bool
+	}{
+		{
+			name:      "idle",
+			behaviour: NPCBehaviour{Type: NPCIdle},
+			check: func(t *testing.T, tile tilePoint) {
+				if tile != (tilePoint{X: 8, Y: 8}) {
+					t.Fatalf("idle NPC moved to %v", tile)
+				}
+			},
+		},
+		{
+			name:      "wander",
+			behaviour: NPCBehaviour{Type: NPCWander, Radius: 3, PauseMin: 0.1, PauseMax: 0.2},
+			check: func(t *testing.T, tile tilePoint) {
+				if absInt(tile.X-8) > 3 || absInt(tile.Y-8) > 3 {
+					t.Fatalf("wandering NPC left its radius at %v", tile)
+				}
+			},
+			wantMoved: true,
+		},
+		{
+			name: "patrol",
+			behaviour: NPCBehaviour{Type: NPCPatrol, Route: []MapPoint{
+				{X: 8, Y: 8}, {X: 12, Y: 8}, {X: 12, Y: 12}, {X: 2, Y: 2},
+			}},
+			check:       func(t *testing.T, tile tilePoint) {},
+			wantVisited: []tilePoint{{X: 12, Y: 8}, {X: 12, Y: 12}, {X: 2, Y: 2}},
+			wantMoved:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewWorld(openMap(16))
+			def := NPCDefinition{ID: "a", Spawn: NPCSpawnArea{X: 8, Y: 8}, Behaviour: tt.behaviour}
+			if err := w.SpawnNPCs([]NPCDefinition{def}); err != nil {
+				t.Fatal(err)
+			}
+
+			visited := make(map[tilePoint]bool)
+			for i := 0; i < 600; i += 1 {
+				w.Step(0.05)
+				npc := npcEntities(w)[0]
+				tileX, tileY := w.toTileCoords(npc.X, npc.Y)
+				tile := tilePoint{X: tileX, Y: tileY}
+				tt.check(t, tile)
+				visited[tile] = true
+			}
+
+			for _, tile := range tt.wantVisited {
+				if !visited[tile] {
+					t.Fatalf("NPC never reached %v", tile)
+				}
+			}
+			if moved := len(visited) > 1; moved != tt.wantMoved {
+				t.Fatalf("NPC moved = %v, want %v", moved, tt.wantMoved)
+			}
+		})
+	}
+}
+
+func TestNPCsFollowTheSeed(t *testing.T) {
+	run := func(seed int64) []Entity {
+		w := NewWorld(openMap(24))
+		w.SetSeed(seed)
+		if err := w.SpawnNPCs(replayTestNPCs()); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 100; i += 1 {
+			w.Step(0.05)
+		}
+
+		return npcEntities(w)
+	}
+
+	if !reflect.DeepEqual(run(7), run(7)) {
+		t.Fatal("the same seed moved NPCs differently")
+	}
+	if reflect.DeepEqual(run(7), run(8)) {
+		t.Fatal("different seeds moved NPCs the same way")
+	}
+}
+
+func TestLoadNPCDefinitions(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "npcs.json")
+	if err := os.WriteFile(valid, []byte(`{"npcs": [{"id": "chicken", "count": 2, "spawn": {"x": 1, "y": 2, "radius": 3}, "behaviour": {"type": "wander", "radius": 4}}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	corrupt := filepath.Join(dir, "corrupt.json")
+	if err := os.WriteFile(corrupt, []byte(`{"npcs": [`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		want    []NPCDefinition
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			path: valid,
+			want: []NPCDefinition{{
+				ID:        "chicken",
+				Count:     2,
+				Spawn:     NPCSpawnArea{X: 1, Y: 2, Radius: 3},
+				Behaviour: NPCBehaviour{Type: NPCWander, Radius: 4},
+			}},
+		},
+		{name: "corrupt", path: corrupt, wantErr: true},
+		{name: "missing", path: filepath.Join(dir, "missing.json"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defs, err := LoadNPCDefinitions(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadNPCDefinitions() error = %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(defs, tt.want) {
+				t.Fatalf("LoadNPCDefinitions() = %+v, want %+v", defs, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,248 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+)
+
+type ObjectState string
+
+const (
+	ObjectAvailable ObjectState = "available"
+	ObjectDepleted  ObjectState = "depleted"
+)
+
+// MapObject is a static interactable placed by the map, such as a tree or a
+// rock. Actions lists what players may do with it, any action when empty.
+// A successful action depletes it for RespawnTicks ticks; zero means it never
+// depletes.
+type MapObject struct {
+	Name         string   `json:"name,omitempty"`
+	Type         string   `json:"type"`
+	X            int      `json:"x"`
+	Y            int      `json:"y"`
+	Actions      []string `json:"actions,omitempty"`
+	RespawnTicks int      `json:"respawnTicks,omitempty"`
+}
+
+func (o MapObject) allows(action string) bool {
+	if len(o.Actions) == 0 {
+		return true
+	}
+	for _, allowed := range o.Actions {
+		if allowed == action {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ObjectUpdate reports an interaction with an object or its respawn. Actor
+// and Action are empty for respawns.
+type ObjectUpdate struct {
+	ID     EntityID
+	Type   string
+	State  ObjectState
+	Actor  string
+	Action string
+}
+
+type worldObject struct {
+	def       MapObject
+	respawnIn int
+}
+
+// interaction is a player walking up to an object to act on it.
+type interaction struct {
+	target EntityID
+	action string
+}
+
+func validateObjects(data MapData, grid tileGrid) error {
+	for i, object := range data.Objects {
+		if object.Type == "" {
+			return fmt.Errorf("map object %d has no type", i)
+		}
+		if object.X < 0 || object.Y < 0 || object.X >= data.Width || object.Y >= data.Height {
+			return fmt.Errorf("map object %d (%s) is outside the map", i, object.Type)
+		}
+		if object.RespawnTicks < 0 {
+			return fmt.Errorf("map object %d (%s) has a negative respawn", i, object.Type)
+		}
+
+		reachable := false
+		for _, direction := range allDirections {
+			x, y := object.X+direction.X, object.Y+direction.Y
+			if x >= 0 && y >= 0 && x < data.Width && y < data.Height && grid.walkable[y*data.Width+x] {
+				reachable = true
+				break
+			}
+		}
+		if !reachable {
+			return fmt.Errorf("map object %d (%s) has no walkable neighbour", i, object.Type)
+		}
+	}
+
+	return nil
+}
+
+func (w *World) placeObjects(objects []MapObject) {
+	for _, def := range objects {
+		entity := &Entity{
+			Kind:  EntityObject,
+			Type:  def.Type,
+			State: ObjectAvailable,
+			X:     w.tileCenter(def.X),
+			Y:     w.tileCenter(def.Y),
+		}
+		w.addEntityLocked(entity)
+		w.objects[entity.ID] = &worldObject{def: def}
+	}
+}
+
+// DrainObjectUpdates returns the object updates since the last call.
+func (w *World) DrainObjectUpdates() []ObjectUpdate {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	updates := w.objectUpdates
+	w.objectUpdates = nil
+
+	return updates
+}
+
+// startInteraction paths the player next to the target object and remembers
+// the action so resolveInteractions can perform it on arrival.
+func (w *World) startInteraction(playerID string, target EntityID, action string) bool {
+	player, ok := w.players[playerID]
+	if !ok {
+		return false
+	}
+	object, ok := w.objects[target]
+	if !ok || !object.def.allows(action) {
+		return false
+	}
+
+	startX, startY := w.toTileCoords(player.X, player.Y)
+	start := tilePoint{X: startX, Y: startY}
+	goal := tilePoint{X: object.def.X, Y: object.def.Y}
+
+	if w.isAdjacent(start, goal) {
+		w.setEntityPath(player, start)
+	} else if !w.pathNextTo(player, start, goal) {
+		return false
+	}
+
+	w.interactions[player.ID] = interaction{target: target, action: action}
+	return true
+}
+
+// pathNextTo tries the tiles around goal nearest-first until one is reachable.
+func (w *World) pathNextTo(entity *Entity, start, goal tilePoint) bool {
+	directions := straightDirections
+	if w.diagonal {
+		directions = allDirections
+	}
+
+	candidates := make([]tilePoint, 0, len(directions))
+	for _, direction := range directions {
+		tile := tilePoint{X: goal.X + direction.X, Y: goal.Y + direction.Y}
+		if w.isWalkable(tile.X, tile.Y) {
+			candidates = append(candidates, tile)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return w.heuristic(start, candidates[i]) < w.heuristic(start, candidates[j])
+	})
+
+	for _, tile := range candidates {
+		if w.setEntityPath(entity, tile) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (w *World) isAdjacent(a, b tilePoint) bool {
+	dx := absInt(a.X - b.X)
+	dy := absInt(a.Y - b.Y)
+	if w.diagonal {
+		return dx <= 1 && dy <= 1
+	}
+
+	return dx+dy <= 1
+}
+
+// resolveInteractions performs the pending interactions of players that have
+// stopped moving. Players are visited in entity id order so two players
+// racing for the same object resolve the same way on replay.
+func (w *World) resolveInteractions() {
+	if len(w.interactions) == 0 {
+		return
+	}
+
+	for _, entity := range w.order {
+		pending, ok := w.interactions[entity.ID]
+		if !ok || isMoving(entity) {
+			continue
+		}
+		delete(w.interactions, entity.ID)
+
+		object, ok := w.objects[pending.target]
+		if !ok {
+			continue
+		}
+		target := w.entities[pending.target]
+		tileX, tileY := w.toTileCoords(entity.X, entity.Y)
+		if target.State != ObjectAvailable || !w.isAdjacent(tilePoint{X: tileX, Y: tileY}, tilePoint{X: object.def.X, Y: object.def.Y}) {
+			continue
+		}
+
+		if object.def.RespawnTicks > 0 {
+			target.State = ObjectDepleted
+			object.respawnIn = object.def.RespawnTicks
+			w.dirty = true
+		}
+		w.objectUpdates = append(w.objectUpdates, ObjectUpdate{
+			ID:     target.ID,
+			Type:   target.Type,
+			State:  target.State,
+			Actor:  entity.PlayerID,
+			Action: pending.action,
+		})
+		w.emit(ObjectInteracted{
+			EventMeta: w.meta(),
+			PlayerID:  entity.PlayerID,
+			Object:    target.ID,
+			Type:      target.Type,
+			Action:    pending.action,
+			State:     target.State,
+		})
+	}
+}
+
+// respawnObjects counts down one tick on every depleted object.
+func (w *World) respawnObjects() {
+	for _, entity := range w.order {
+		object, ok := w.objects[entity.ID]
+		if !ok || entity.State != ObjectDepleted {
+			continue
+		}
+
+		object.respawnIn -= 1
+		if object.respawnIn > 0 {
+			continue
+		}
+
+		entity.State = ObjectAvailable
+		w.dirty = true
+		w.objectUpdates = append(w.objectUpdates, ObjectUpdate{
+			ID:    entity.ID,
+			Type:  entity.Type,
+			State: entity.State,
+		})
+		w.emit(ObjectRespawned{EventMeta: w.meta(), Object: entity.ID, Type: entity.Type})
+	}
+}
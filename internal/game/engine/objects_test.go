@@ -0,0 +1,181 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateObjects(t *testing.T) {
+	rows := []string{
+		"..###",
+		"..###",
+		"..###",
+	}
+
+	tests := []struct {
+		name    string
+		objects []MapObject
+		wantErr bool
+	}{
+		{name: "valid", objects: []MapObject{{Type: "tree", X: 0, Y: 0}, {Type: "rock", X: 2, Y: 1, RespawnTicks: 5}}},
+		{name: "on a wall next to ground", objects: []MapObject{{Type: "ore", X: 2, Y: 1}}},
+		{name: "no type", objects: []MapObject{{X: 0, Y: 0}}, wantErr: true},
+		{name: "outside the map", objects: []MapObject{{Type: "tree", X: 5, Y: 0}}, wantErr: true},
+		{name: "negative respawn", objects: []MapObject{{Type: "tree", X: 0, Y: 0, RespawnTicks: -1}}, wantErr: true},
+		{name: "walled in", objects: []MapObject{{Type: "tree", X: 4, Y: 1}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := testMap(rows, false)
+			data.Objects = tt.objects
+			if err := validateMapData(data); (err != nil) != tt.wantErr {
+				t.Fatalf("validateMapData() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// objectWorld is a corridor with players at its west end and one tree at its
+// east end.
+func objectWorld(tree MapObject, players ...string) (*World, EntityID) {
+	data := testMap([]string{".........."}, false)
+	data.Objects = []MapObject{tree}
+	w := NewWorld(data)
+	for i, id := range players {
+		w.AddPlayer(id, &Position{X: w.tileCenter(i), Y: w.tileCenter(0)})
+	}
+	for _, entity := range w.SnapshotEntities() {
+		if entity.Kind == EntityObject {
+			return w, entity.ID
+		}
+	}
+
+	return w, 0
+}
+
+func objectState(w *World, id EntityID) ObjectState {
+	entity, _ := w.Entity(id)
+	return entity.State
+}
+
+func TestInteract(t *testing.T) {
+	tests := []struct {
+		name        string
+		tree        MapObject
+		action      string
+		wantApplied bool
+		want        []ObjectUpdate
+		wantState   ObjectState
+	}{
+		{
+			name:        "depletes",
+			tree:        MapObject{Type: "tree", X: 9, Y: 0, Actions: []string{"chop"}, RespawnTicks: 1000},
+			action:      "chop",
+			wantApplied: true,
+			want:        []ObjectUpdate{{Type: "tree", State: ObjectDepleted, Actor: "alice", Action: "chop"}},
+			wantState:   ObjectDepleted,
+		},
+		{
+			name:        "never depletes",
+			tree:        MapObject{Type: "tree", X: 9, Y: 0, Actions: []string{"chop"}},
+			action:      "chop",
+			wantApplied: true,
+			want:        []ObjectUpdate{{Type: "tree", State: ObjectAvailable, Actor: "alice", Action: "chop"}},
+			wantState:   ObjectAvailable,
+		},
+		{
+			name:        "any action",
+			tree:        MapObject{Type: "tree", X: 9, Y: 0},
+			action:      "hug",
+			wantApplied: true,
+			want:        []ObjectUpdate{{Type: "tree", State: ObjectAvailable, Actor: "alice", Action: "hug"}},
+			wantState:   ObjectAvailable,
+		},
+		{
+			name:      "action not allowed",
+			tree:      MapObject{Type: "tree", X: 9, Y: 0, Actions: []string{"chop"}},
+			action:    "mine",
+			wantState: ObjectAvailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, tree := objectWorld(tt.tree, "alice")
+			w.EnqueueCommand(Command{PlayerID: "alice", Type: CommandInteract, Target: tree, Action: tt.action})
+
+			var updates []ObjectUpdate
+			for i := 0; i < 100; i += 1 {
+				w.Step(0.05)
+				updates = append(updates, w.DrainObjectUpdates()...)
+			}
+			for i := range updates {
+				updates[i].ID = 0
+			}
+			if !reflect.DeepEqual(updates, tt.want) {
+				t.Fatalf("updates = %+v, want %+v", updates, tt.want)
+			}
+			if got := objectState(w, tree); got != tt.wantState {
+				t.Fatalf("tree is %s, want %s", got, tt.wantState)
+			}
+			if tt.wantApplied {
+				player := w.SnapshotPlayers()[0]
+				if player.X != w.tileCenter(8) {
+					t.Fatalf("player stopped at x=%d, want next to the tree", player.X)
+				}
+			}
+		})
+	}
+}
+
+func TestDepletedObjectsRespawn(t *testing.T) {
+	w, tree := objectWorld(MapObject{Type: "tree", X: 1, Y: 0, RespawnTicks: 3}, "alice")
+	w.EnqueueCommand(Command{PlayerID: "alice", Type: CommandInteract, Target: tree, Action: "chop"})
+	w.Step(0.05)
+	if got := objectState(w, tree); got != ObjectDepleted {
+		t.Fatalf("tree is %s right after chopping it, want depleted", got)
+	}
+	w.DrainObjectUpdates()
+
+	for tick := 1; tick <= 3; tick += 1 {
+		w.Step(0.05)
+		want := ObjectDepleted
+		if tick == 3 {
+			want = ObjectAvailable
+		}
+		if got := objectState(w, tree); got != want {
+			t.Fatalf("tree is %s %d ticks after chopping, want %s", got, tick, want)
+		}
+	}
+
+	updates := w.DrainObjectUpdates()
+	if len(updates) != 1 || updates[0].State != ObjectAvailable || updates[0].Actor != "" {
+		t.Fatalf("updates = %+v, want one respawn", updates)
+	}
+}
+
+func TestInteractionRaceGoesToTheFirstPlayer(t *testing.T) {
+	data := testMap([]string{"..."}, false)
+	data.Objects = []MapObject{{Type: "tree", X: 1, Y: 0, RespawnTicks: 10}}
+	w := NewWorld(data)
+	w.AddPlayer("alice", &Position{X: w.tileCenter(0), Y: w.tileCenter(0)})
+	w.AddPlayer("bob", &Position{X: w.tileCenter(2), Y: w.tileCenter(0)})
+	var tree EntityID
+	for _, entity := range w.SnapshotEntities() {
+		if entity.Kind == EntityObject {
+			tree = entity.ID
+		}
+	}
+
+	// Both stand next to the tree. Bob's command is queued first, but alice
+	// joined first, so her interaction resolves first.
+	w.EnqueueCommand(Command{PlayerID: "bob", Type: CommandInteract, Target: tree, Action: "chop"})
+	w.EnqueueCommand(Command{PlayerID: "alice", Type: CommandInteract, Target: tree, Action: "chop"})
+	w.Step(0.05)
+
+	updates := w.DrainObjectUpdates()
+	if len(updates) != 1 || updates[0].Actor != "alice" {
+		t.Fatalf("updates = %+v, want alice to get the tree", updates)
+	}
+}
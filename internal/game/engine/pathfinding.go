@@ -0,0 +1,283 @@
+package engine
+
+// DefaultMaxPathNodes bounds a single search so one unreachable click
+// cannot stall the tick while the world lock is held.
+const DefaultMaxPathNodes = 10000
+
+// Step costs are scaled by ten so a diagonal (~sqrt(2)) stays integral. They
+// are further multiplied by the destination tile's cost weight.
+const (
+	straightStepCost = 10
+	diagonalStepCost = 14
+)
+
+var straightDirections = []tilePoint{
+	{X: 1, Y: 0},
+	{X: -1, Y: 0},
+	{X: 0, Y: 1},
+	{X: 0, Y: -1},
+}
+
+var allDirections = []tilePoint{
+	{X: 1, Y: 0},
+	{X: -1, Y: 0},
+	{X: 0, Y: 1},
+	{X: 0, Y: -1},
+	{X: 1, Y: 1},
+	{X: 1, Y: -1},
+	{X: -1, Y: 1},
+	{X: -1, Y: -1},
+}
+
+// pathSearch holds scratch buffers reused across findPath calls. Entries are
+// stamped with a generation so the buffers never need clearing between
+// searches. occupied records whether the last search skipped a tile only
+// because another entity held it. Access is guarded by World.mu.
+type pathSearch struct {
+	generation uint32
+	seen       []uint32
+	closed     []uint32
+	gScore     []int
+	cameFrom   []int32
+	open       pathHeap
+	occupied   bool
+}
+
+type pathNode struct {
+	index int32
+	f     int
+	h     int
+}
+
+// pathHeap is a binary min-heap ordered by f, breaking ties on h so nodes
+// closer to the goal are expanded first.
+type pathHeap []pathNode
+
+func (h pathHeap) less(i, j int) bool {
+	if h[i].f != h[j].f {
+		return h[i].f < h[j].f
+	}
+
+	return h[i].h < h[j].h
+}
+
+func (h *pathHeap) push(node pathNode) {
+	*h = append(*h, node)
+	nodes := *h
+	i := len(nodes) - 1
+	for i > 0 {
+		parent := (i - 1) / 2
+		if !nodes.less(i, parent) {
+			break
+		}
+		nodes[i], nodes[parent] = nodes[parent], nodes[i]
+		i = parent
+	}
+}
+
+func (h *pathHeap) pop() pathNode {
+	nodes := *h
+	top := nodes[0]
+	last := len(nodes) - 1
+	nodes[0] = nodes[last]
+	nodes = nodes[:last]
+
+	i := 0
+	for {
+		left := 2*i + 1
+		if left >= len(nodes) {
+			break
+		}
+		smallest := left
+		if right := left + 1; right < len(nodes) && nodes.less(right, left) {
+			smallest = right
+		}
+		if !nodes.less(smallest, i) {
+			break
+		}
+		nodes[i], nodes[smallest] = nodes[smallest], nodes[i]
+		i = smallest
+	}
+
+	*h = nodes
+	return top
+}
+
+func (s *pathSearch) reset(size int) {
+	if len(s.seen) != size {
+		s.seen = make([]uint32, size)
+		s.closed = make([]uint32, size)
+		s.gScore = make([]int, size)
+		s.cameFrom = make([]int32, size)
+		s.generation = 0
+	}
+
+	s.generation += 1
+	if s.generation == 0 {
+		for i := range s.seen {
+			s.seen[i] = 0
+			s.closed[i] = 0
+		}
+		s.generation = 1
+	}
+
+	s.open = s.open[:0]
+	s.occupied = false
+}
+
+func (w *World) tileIndex(x, y int) int32 {
+	return int32(y*w.mapWidth + x)
+}
+
+func (w *World) tileAt(index int32) tilePoint {
+	return tilePoint{X: int(index) % w.mapWidth, Y: int(index) / w.mapWidth}
+}
+
+// findPath plans a route for self. Unless self is nil, tiles another entity
+// holds where collision applies are avoided, except the goal itself. Long
+// routes on large maps go through the path hierarchy.
+func (w *World) findPath(start, goal tilePoint, self *Entity) []tilePoint {
+	if !w.isWalkable(goal.X, goal.Y) {
+		return nil
+	}
+	if start.X < 0 || start.Y < 0 || start.X >= w.mapWidth || start.Y >= w.mapHeight {
+		return nil
+	}
+	if w.useHierarchy(start, goal) {
+		return w.findHierarchicalPath(start, goal, self)
+	}
+
+	return w.searchPath(start, goal, self, nil, w.maxPathNodes)
+}
+
+// searchPath runs A* from start to goal, staying inside bounds unless it is
+// nil and expanding at most limit tiles when limit is positive.
+func (w *World) searchPath(start, goal tilePoint, self *Entity, bounds *tileRect, limit int) []tilePoint {
+	s := &w.search
+	s.reset(w.mapWidth * w.mapHeight)
+
+	startIndex := w.tileIndex(start.X, start.Y)
+	goalIndex := w.tileIndex(goal.X, goal.Y)
+
+	s.seen[startIndex] = s.generation
+	s.gScore[startIndex] = 0
+	s.cameFrom[startIndex] = -1
+	startH := w.heuristic(start, goal)
+	s.open.push(pathNode{index: startIndex, f: startH, h: startH})
+
+	expanded := 0
+	for len(s.open) > 0 {
+		current := s.open.pop()
+		if s.closed[current.index] == s.generation {
+			continue
+		}
+		if current.index == goalIndex {
+			return w.reconstructPath(goalIndex)
+		}
+
+		s.closed[current.index] = s.generation
+		expanded += 1
+		if limit > 0 && expanded > limit {
+			return nil
+		}
+
+		point := w.tileAt(current.index)
+		currentG := s.gScore[current.index]
+		directions := straightDirections
+		if w.diagonal {
+			directions = allDirections
+		}
+
+		for _, direction := range directions {
+			neighbor := tilePoint{X: point.X + direction.X, Y: point.Y + direction.Y}
+			if bounds != nil && !bounds.contains(neighbor) {
+				continue
+			}
+			if !w.canStep(point, neighbor) {
+				continue
+			}
+
+			neighborIndex := w.tileIndex(neighbor.X, neighbor.Y)
+			if s.closed[neighborIndex] == s.generation {
+				continue
+			}
+			if self != nil && neighborIndex != goalIndex && w.blockedFor(neighborIndex, self) {
+				s.occupied = true
+				continue
+			}
+
+			tentativeG := currentG + stepCost(point, neighbor)*w.tiles.cost[neighborIndex]
+			if s.seen[neighborIndex] == s.generation && tentativeG >= s.gScore[neighborIndex] {
+				continue
+			}
+
+			s.seen[neighborIndex] = s.generation
+			s.gScore[neighborIndex] = tentativeG
+			s.cameFrom[neighborIndex] = current.index
+			h := w.heuristic(neighbor, goal)
+			s.open.push(pathNode{index: neighborIndex, f: tentativeG + h, h: h})
+		}
+	}
+
+	return nil
+}
+
+// canStep reports whether an entity may move directly between two adjacent
+// tiles. Diagonal steps are only allowed when the map enables them and both
+// orthogonal tiles they pass are walkable, so routes never cut wall corners.
+func (w *World) canStep(from, to tilePoint) bool {
+	if !w.isWalkable(to.X, to.Y) {
+		return false
+	}
+
+	dx := to.X - from.X
+	dy := to.Y - from.Y
+	if dx == 0 || dy == 0 {
+		return true
+	}
+	if !w.diagonal {
+		return false
+	}
+
+	return w.isWalkable(from.X+dx, from.Y) && w.isWalkable(from.X, from.Y+dy)
+}
+
+func stepCost(from, to tilePoint) int {
+	if from.X != to.X && from.Y != to.Y {
+		return diagonalStepCost
+	}
+
+	return straightStepCost
+}
+
+// heuristic is Manhattan distance for four-way maps and octile distance when
+// diagonal movement is enabled, scaled by the cheapest tile on the map so it
+// never overestimates.
+func (w *World) heuristic(a, b tilePoint) int {
+	dx := absInt(a.X - b.X)
+	dy := absInt(a.Y - b.Y)
+	if !w.diagonal {
+		return straightStepCost * (dx + dy) * w.tiles.minCost
+	}
+
+	if dx < dy {
+		dx, dy = dy, dx
+	}
+
+	return (straightStepCost*dx + (diagonalStepCost-straightStepCost)*dy) * w.tiles.minCost
+}
+
+func (w *World) reconstructPath(goalIndex int32) []tilePoint {
+	length := 0
+	for index := goalIndex; index >= 0; index = w.search.cameFrom[index] {
+		length += 1
+	}
+
+	path := make([]tilePoint, length)
+	for index := goalIndex; index >= 0; index = w.search.cameFrom[index] {
+		length -= 1
+		path[length] = w.tileAt(index)
+	}
+
+	return path
+}
@@ -0,0 +1,390 @@
+package engine
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// testMap builds a map from rows of '.' (open ground) and '#' (wall).
+func testMap(rows []string, diagonal bool) MapData {
+	tiles := make([][]int, len(rows))
+	for y, row := range rows {
+		tiles[y] = make([]int, len(row))
+		for x, cell := range row {
+			if cell == '#' {
+				tiles[y][x] = 2
+			}
+		}
+	}
+
+	return MapData{Width: len(rows[0]), Height: len(rows), Tiles: tiles, Diagonal: diagonal}
+}
+
+// noiseMap is a bordered map with walls scattered over density of its
+// interior.
+func noiseMap(size int, density float64, seed int64, diagonal bool) MapData {
+	rng := rand.New(rand.NewSource(seed))
+	rows := make([]string, size)
+	for y := range rows {
+		row := make([]byte, size)
+		for x := range row {
+			if x == 0 || y == 0 || x == size-1 || y == size-1 || rng.Float64() < density {
+				row[x] = '#'
+			} else {
+				row[x] = '.'
+			}
+		}
+		rows[y] = string(row)
+	}
+
+	return testMap(rows, diagonal)
+}
+
+// referenceCost runs Dijkstra over the whole grid and returns the cost of
+// the cheapest route, or -1 when there is none.
+func referenceCost(w *World, start, goal tilePoint) int {
+	costs := make([]int, w.mapWidth*w.mapHeight)
+	for i := range costs {
+		costs[i] = -1
+	}
+	directions := straightDirections
+	if w.diagonal {
+		directions = allDirections
+	}
+
+	startIndex := w.tileIndex(start.X, start.Y)
+	costs[startIndex] = 0
+	open := pathHeap{{index: startIndex}}
+	for len(open) > 0 {
+		current := open.pop()
+		if current.f > costs[current.index] {
+			continue
+		}
+		point := w.tileAt(current.index)
+		for _, direction := range directions {
+			next := tilePoint{X: point.X + direction.X, Y: point.Y + direction.Y}
+			if !w.canStep(point, next) {
+				continue
+			}
+			index := w.tileIndex(next.X, next.Y)
+			cost := current.f + stepCost(point, next)
+			if costs[index] >= 0 && cost >= costs[index] {
+				continue
+			}
+			costs[index] = cost
+			open.push(pathNode{index: index, f: cost})
+		}
+	}
+
+	return costs[w.tileIndex(goal.X, goal.Y)]
+}
+
+// checkPath fails the test unless path runs from start to goal in legal
+// single steps, and returns its cost.
+func checkPath(t *testing.T, w *World, path []tilePoint, start, goal tilePoint) int {
+	t.Helper()
+
+	if path[0] != start || path[len(path)-1] != goal {
+		t.Fatalf("path runs from %v to %v, want %v to %v", path[0], path[len(path)-1], start, goal)
+	}
+	cost := 0
+	for i := 1; i < len(path); i += 1 {
+		from, to := path[i-1], path[i]
+		if absInt(to.X-from.X) > 1 || absInt(to.Y-from.Y) > 1 || !w.canStep(from, to) {
+			t.Fatalf("illegal step %v -> %v", from, to)
+		}
+		cost += stepCost(from, to)
+	}
+
+	return cost
+}
+
+func TestPathHeapOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		nodes []pathNode
+		want  []int32
+	}{
+		{
+			name:  "lowest f first",
+			nodes: []pathNode{{index: 1, f: 30}, {index: 2, f: 10}, {index: 3, f: 20}},
+			want:  []int32{2, 3, 1},
+		},
+		{
+			name:  "ties broken on h",
+			nodes: []pathNode{{index: 1, f: 10, h: 8}, {index: 2, f: 10, h: 2}, {index: 3, f: 10, h: 5}},
+			want:  []int32{2, 3, 1},
+		},
+		{
+			name: "f before h",
+			nodes: []pathNode{
+				{index: 1, f: 20, h: 0},
+				{index: 2, f: 10, h: 9},
+				{index: 3, f: 15, h: 1},
+				{index: 4, f: 10, h: 3},
+			},
+			want: []int32{4, 2, 3, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var heap pathHeap
+			for _, node := range tt.nodes {
+				heap.push(node)
+			}
+			for i, want := range tt.want {
+				if got := heap.pop().index; got != want {
+					t.Fatalf("pop %d = node %d, want %d", i, got, want)
+				}
+			}
+			if len(heap) != 0 {
+				t.Fatalf("%d nodes left after popping all", len(heap))
+			}
+		})
+	}
+}
+
+func TestFindPath(t *testing.T) {
+	tests := []struct {
+		name     string
+		rows     []string
+		diagonal bool
+		start    tilePoint
+		goal     tilePoint
+		wantCost int
+	}{
+		{
+			name:     "straight corridor",
+			rows:     []string{"......"},
+			start:    tilePoint{X: 0, Y: 0},
+			goal:     tilePoint{X: 5, Y: 0},
+			wantCost: 5 * straightStepCost,
+		},
+		{
+			name:     "start is goal",
+			rows:     []string{"..."},
+			start:    tilePoint{X: 1, Y: 0},
+			goal:     tilePoint{X: 1, Y: 0},
+			wantCost: 0,
+		},
+		{
+			name: "around a wall",
+			rows: []string{
+				".#.",
+				".#.",
+				"...",
+			},
+			start:    tilePoint{X: 0, Y: 0},
+			goal:     tilePoint{X: 2, Y: 0},
+			wantCost: 6 * straightStepCost,
+		},
+		{
+			name: "diagonal shortcut",
+			rows: []string{
+				"...",
+				"...",
+				"...",
+			},
+			diagonal: true,
+			start:    tilePoint{X: 0, Y: 0},
+			goal:     tilePoint{X: 2, Y: 2},
+			wantCost: 2 * diagonalStepCost,
+		},
+		{
+			name: "unreachable",
+			rows: []string{
+				".#.",
+				".#.",
+			},
+			start:    tilePoint{X: 0, Y: 0},
+			goal:     tilePoint{X: 2, Y: 1},
+			wantCost: -1,
+		},
+		{
+			name:     "goal is a wall",
+			rows:     []string{"..#"},
+			start:    tilePoint{X: 0, Y: 0},
+			goal:     tilePoint{X: 2, Y: 0},
+			wantCost: -1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewWorld(testMap(tt.rows, tt.diagonal))
+			path := w.findPath(tt.start, tt.goal, nil)
+			if tt.wantCost < 0 {
+				if path != nil {
+					t.Fatalf("found path %v, want none", path)
+				}
+				return
+			}
+			if path == nil {
+				t.Fatal("found no path")
+			}
+			if cost := checkPath(t, w, path, tt.start, tt.goal); cost != tt.wantCost {
+				t.Fatalf("path %v costs %d, want %d", path, cost, tt.wantCost)
+			}
+		})
+	}
+}
+
+func TestFindPathOptimal(t *testing.T) {
+	tests := []struct {
+		name     string
+		density  float64
+		diagonal bool
+	}{
+		{name: "open", density: 0.05},
+		{name: "scattered walls", density: 0.25},
+		{name: "dense walls", density: 0.4},
+		{name: "open, diagonal", density: 0.05, diagonal: true},
+		{name: "scattered walls, diagonal", density: 0.25, diagonal: true},
+		{name: "dense walls, diagonal", density: 0.4, diagonal: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewWorld(noiseMap(48, tt.density, 1, tt.diagonal))
+			rng := rand.New(rand.NewSource(2))
+			for i := 0; i < 50; i += 1 {
+				start := tilePoint{X: rng.Intn(w.mapWidth), Y: rng.Intn(w.mapHeight)}
+				goal := tilePoint{X: rng.Intn(w.mapWidth), Y: rng.Intn(w.mapHeight)}
+				if !w.isWalkable(start.X, start.Y) || !w.isWalkable(goal.X, goal.Y) {
+					continue
+				}
+
+				want := referenceCost(w, start, goal)
+				path := w.findPath(start, goal, nil)
+				if want < 0 {
+					if path != nil {
+						t.Fatalf("found path %v -> %v, want none", start, goal)
+					}
+					continue
+				}
+				if path == nil {
+					t.Fatalf("found no path %v -> %v", start, goal)
+				}
+				if cost := checkPath(t, w, path, start, goal); cost != want {
+					t.Fatalf("path %v -> %v costs %d, want %d", start, goal, cost, want)
+				}
+			}
+		})
+	}
+}
+
+func TestFindPathNodeBudget(t *testing.T) {
+	rows := make([]string, 32)
+	for y := range rows {
+		rows[y] = "................................"
+	}
+	start := tilePoint{X: 0, Y: 0}
+	goal := tilePoint{X: 31, Y: 31}
+
+	tests := []struct {
+		name      string
+		limit     int
+		wantFound bool
+	}{
+		{name: "unlimited", limit: 0, wantFound: true},
+		{name: "generous", limit: 32 * 32, wantFound: true},
+		{name: "exhausted", limit: 10, wantFound: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewWorld(testMap(rows, false))
+			w.SetMaxPathNodes(tt.limit)
+			if found := w.findPath(start, goal, nil) != nil; found != tt.wantFound {
+				t.Fatalf("found = %v, want %v", found, tt.wantFound)
+			}
+		})
+	}
+}
+
+func TestCanStepCornerCutting(t *testing.T) {
+	rows := []string{
+		".#.",
+		"...",
+		"..#",
+	}
+
+	tests := []struct {
+		name     string
+		diagonal bool
+		from     tilePoint
+		to       tilePoint
+		want     bool
+	}{
+		{name: "straight", diagonal: false, from: tilePoint{X: 0, Y: 1}, to: tilePoint{X: 1, Y: 1}, want: true},
+		{name: "into a wall", diagonal: true, from: tilePoint{X: 1, Y: 1}, to: tilePoint{X: 1, Y: 0}, want: false},
+		{name: "open diagonal", diagonal: true, from: tilePoint{X: 0, Y: 1}, to: tilePoint{X: 1, Y: 2}, want: true},
+		{name: "diagonal disabled", diagonal: false, from: tilePoint{X: 0, Y: 1}, to: tilePoint{X: 1, Y: 2}, want: false},
+		{name: "cuts a corner", diagonal: true, from: tilePoint{X: 0, Y: 0}, to: tilePoint{X: 1, Y: 1}, want: false},
+		{name: "cuts the other corner", diagonal: true, from: tilePoint{X: 2, Y: 1}, to: tilePoint{X: 1, Y: 2}, want: false},
+		{name: "cuts a corner backwards", diagonal: true, from: tilePoint{X: 1, Y: 1}, to: tilePoint{X: 2, Y: 0}, want: false},
+		{name: "off the map", diagonal: true, from: tilePoint{X: 0, Y: 0}, to: tilePoint{X: -1, Y: 0}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewWorld(testMap(rows, tt.diagonal))
+			if got := w.canStep(tt.from, tt.to); got != tt.want {
+				t.Fatalf("canStep(%v, %v) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindPathNeverCutsCorners(t *testing.T) {
+	tests := []struct {
+		name  string
+		rows  []string
+		start tilePoint
+		goal  tilePoint
+		want  int
+	}{
+		{
+			name: "around a pillar",
+			rows: []string{
+				"...",
+				".#.",
+				"...",
+			},
+			start: tilePoint{X: 0, Y: 1},
+			goal:  tilePoint{X: 1, Y: 0},
+			want:  2,
+		},
+		{
+			name: "through a diagonal gap",
+			rows: []string{
+				".#",
+				"#.",
+			},
+			start: tilePoint{X: 0, Y: 0},
+			goal:  tilePoint{X: 1, Y: 1},
+			want:  -1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewWorld(testMap(tt.rows, true))
+			path := w.findPath(tt.start, tt.goal, nil)
+			if tt.want < 0 {
+				if path != nil {
+					t.Fatalf("found path %v, want none", path)
+				}
+				return
+			}
+			if path == nil {
+				t.Fatal("found no path")
+			}
+			checkPath(t, w, path, tt.start, tt.goal)
+			if steps := len(path) - 1; steps != tt.want {
+				t.Fatalf("path %v takes %d steps, want %d", path, steps, tt.want)
+			}
+		})
+	}
+}
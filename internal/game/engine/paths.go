@@ -0,0 +1,149 @@
+package engine
+
+// PlannedPath is the route an entity started following on StartTick, for
+// clients to animate locally. Waypoints are scaled positions beginning where
+// the entity stood, reduced to the tiles where it turns, and Speed is in
+// scaled units per second. A cancelled path has no waypoints and means the
+// entity stopped where it is.
+type PlannedPath struct {
+	Entity    EntityID
+	Kind      EntityKind
+	PlayerID  string
+	Waypoints []Position
+	StartTick int64
+	Speed     float64
+	Cancelled bool
+}
+
+// Tick returns how many Steps the world has run.
+func (w *World) Tick() int64 {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	return w.tick
+}
+
+// DrainPathUpdates returns the paths that were planned, changed or cancelled
+// since the last call, at most one per entity, in the order they changed.
+func (w *World) DrainPathUpdates() []PlannedPath {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	updates := w.pathUpdates
+	w.pathUpdates = nil
+	clear(w.pathUpdateIndex)
+
+	return updates
+}
+
+// CurrentPaths returns the rest of the path of every listed entity that is
+// moving, for clients that start seeing an entity after its path was sent.
+func (w *World) CurrentPaths(ids []EntityID) []PlannedPath {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	var paths []PlannedPath
+	for _, id := range ids {
+		entity, ok := w.entities[id]
+		if !ok || !isMoving(entity) {
+			continue
+		}
+		paths = append(paths, w.plannedPath(entity))
+	}
+
+	return paths
+}
+
+// notePath queues the entity's current path for DrainPathUpdates, replacing
+// an update queued earlier in the same tick. stepEntities queues it again
+// whenever the entity's speed stops matching the one announced here.
+func (w *World) notePath(entity *Entity) {
+	update := w.plannedPath(entity)
+	entity.Movement.announced = update.Speed
+	if index, ok := w.pathUpdateIndex[entity.ID]; ok {
+		w.pathUpdates[index] = update
+		return
+	}
+
+	w.pathUpdateIndex[entity.ID] = len(w.pathUpdates)
+	w.pathUpdates = append(w.pathUpdates, update)
+}
+
+// cancelPath stops an entity mid-route and reports it when it was moving.
+func (w *World) cancelPath(entity *Entity) {
+	movement := entity.Movement
+	moving := movement.Path != nil
+	movement.Path = nil
+	movement.PathIndex = 0
+	if moving {
+		w.notePath(entity)
+		w.emit(PathCancelled{EventMeta: w.meta(), Entity: entity.ID, Kind: entity.Kind, PlayerID: entity.PlayerID})
+	}
+}
+
+// completePath ends the path of an entity that reached its last tile.
+func (w *World) completePath(entity *Entity) {
+	movement := entity.Movement
+	goal := movement.Path[len(movement.Path)-1]
+	movement.Path = nil
+	movement.PathIndex = 0
+	w.emit(PathCompleted{
+		EventMeta: w.meta(),
+		Entity:    entity.ID,
+		Kind:      entity.Kind,
+		PlayerID:  entity.PlayerID,
+		Goal:      MapPoint{X: goal.X, Y: goal.Y},
+	})
+}
+
+// plannedPath describes the rest of the entity's path. Paths planned inside
+// Step start on the tick being stepped and those planned between Steps on
+// the next one, which is w.tick+1 in both cases.
+func (w *World) plannedPath(entity *Entity) PlannedPath {
+	movement := entity.Movement
+	planned := PlannedPath{
+		Entity:    entity.ID,
+		Kind:      entity.Kind,
+		PlayerID:  entity.PlayerID,
+		StartTick: w.tick + 1,
+		Speed:     w.speedOf(entity),
+		Cancelled: movement.Path == nil,
+	}
+	if planned.Cancelled {
+		return planned
+	}
+
+	planned.Waypoints = []Position{{X: entity.X, Y: entity.Y}}
+	path := movement.Path[movement.PathIndex:]
+	previous, hasPrevious := tilePoint{}, false
+	if movement.PathIndex > 0 && w.isAtTileCenter(entity) {
+		previous, hasPrevious = movement.Path[movement.PathIndex-1], true
+	}
+
+	for i, tile := range path {
+		last := i == len(path)-1
+		if hasPrevious && !last && direction(previous, tile) == direction(tile, path[i+1]) {
+			previous = tile
+			continue
+		}
+		planned.Waypoints = append(planned.Waypoints, Position{X: w.tileCenter(tile.X), Y: w.tileCenter(tile.Y)})
+		previous, hasPrevious = tile, true
+	}
+
+	return planned
+}
+
+func direction(from, to tilePoint) tilePoint {
+	return tilePoint{X: sign(to.X - from.X), Y: sign(to.Y - from.Y)}
+}
+
+func sign(value int) int {
+	switch {
+	case value > 0:
+		return 1
+	case value < 0:
+		return -1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,162 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSetPlayerTargetPlansWaypoints(t *testing.T) {
+	w := NewWorld(testMap([]string{
+		".....",
+		"####.",
+		".....",
+	}, false))
+	w.AddPlayer("alice", &Position{X: w.tileCenter(0), Y: w.tileCenter(0)})
+	w.Step(0.05)
+
+	path, ok := w.SetPlayerTarget("alice", w.tileCenter(0), w.tileCenter(2))
+	if !ok {
+		t.Fatal("SetPlayerTarget() failed")
+	}
+
+	// Only the start, the turns and the goal are kept.
+	want := []Position{
+		{X: w.tileCenter(0), Y: w.tileCenter(0)},
+		{X: w.tileCenter(4), Y: w.tileCenter(0)},
+		{X: w.tileCenter(4), Y: w.tileCenter(2)},
+		{X: w.tileCenter(0), Y: w.tileCenter(2)},
+	}
+	if !reflect.DeepEqual(path.Waypoints, want) {
+		t.Fatalf("waypoints = %v, want %v", path.Waypoints, want)
+	}
+	if path.StartTick != 2 || path.Cancelled || path.PlayerID != "alice" {
+		t.Fatalf("path = %+v, want alice's path starting on tick 2", path)
+	}
+	if path.Speed != DefaultMoveSpeed*PositionScale {
+		t.Fatalf("speed = %v, want %v", path.Speed, DefaultMoveSpeed*PositionScale)
+	}
+}
+
+func TestDrainPathUpdates(t *testing.T) {
+	tests := []struct {
+		name string
+		// act runs between the first path being planned and the drain.
+		act         func(w *World)
+		wantUpdates int
+		wantLast    func(t *testing.T, w *World, path PlannedPath)
+	}{
+		{
+			name:        "planned",
+			act:         func(w *World) {},
+			wantUpdates: 1,
+			wantLast: func(t *testing.T, w *World, path PlannedPath) {
+				if path.Cancelled || len(path.Waypoints) != 2 {
+					t.Fatalf("path = %+v, want a straight walk", path)
+				}
+			},
+		},
+		{
+			name: "replanned in the same tick",
+			act: func(w *World) {
+				w.SetPlayerTarget("alice", w.tileCenter(3), w.tileCenter(0))
+			},
+			wantUpdates: 1,
+			wantLast: func(t *testing.T, w *World, path PlannedPath) {
+				if last := path.Waypoints[len(path.Waypoints)-1]; last.X != w.tileCenter(3) {
+					t.Fatalf("path ends at x=%d, want the second goal", last.X)
+				}
+			},
+		},
+		{
+			name: "cancelled",
+			act: func(w *World) {
+				w.SetPlayerTarget("alice", w.tileCenter(0), w.tileCenter(0))
+			},
+			wantUpdates: 1,
+			wantLast: func(t *testing.T, w *World, path PlannedPath) {
+				if !path.Cancelled || path.Waypoints != nil {
+					t.Fatalf("path = %+v, want it cancelled", path)
+				}
+			},
+		},
+		{
+			name: "speed changed",
+			act: func(w *World) {
+				w.DrainPathUpdates()
+				w.Step(0.05)
+				w.EnqueueCommand(Command{PlayerID: "alice", Type: CommandRun, Run: true})
+				w.Step(0.05)
+			},
+			wantUpdates: 1,
+			wantLast: func(t *testing.T, w *World, path PlannedPath) {
+				if path.Speed != DefaultMoveSpeed*RunSpeedMultiplier*PositionScale {
+					t.Fatalf("speed = %v, want the running speed", path.Speed)
+				}
+			},
+		},
+		{
+			name: "arrived",
+			act: func(w *World) {
+				w.DrainPathUpdates()
+				for i := 0; i < 100; i += 1 {
+					w.Step(0.05)
+				}
+			},
+			wantUpdates: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewWorld(corridor(10))
+			w.AddPlayer("alice", &Position{X: w.tileCenter(0), Y: w.tileCenter(0)})
+			w.SetPlayerTarget("alice", w.tileCenter(9), w.tileCenter(0))
+			tt.act(w)
+
+			updates := w.DrainPathUpdates()
+			if len(updates) != tt.wantUpdates {
+				t.Fatalf("%d path updates, want %d: %+v", len(updates), tt.wantUpdates, updates)
+			}
+			if tt.wantLast != nil {
+				tt.wantLast(t, w, updates[len(updates)-1])
+			}
+			if again := w.DrainPathUpdates(); len(again) != 0 {
+				t.Fatalf("drained %d updates twice", len(again))
+			}
+		})
+	}
+}
+
+func TestCurrentPaths(t *testing.T) {
+	w := NewWorld(corridor(10))
+	w.AddPlayer("alice", &Position{X: w.tileCenter(0), Y: w.tileCenter(0)})
+	w.AddPlayer("bob", &Position{X: w.tileCenter(9), Y: w.tileCenter(0)})
+	w.SetPlayerTarget("alice", w.tileCenter(5), w.tileCenter(0))
+	for i := 0; i < 3; i += 1 {
+		w.Step(0.05)
+	}
+
+	var ids []EntityID
+	for _, player := range w.SnapshotPlayers() {
+		ids = append(ids, player.EntityID)
+	}
+	paths := w.CurrentPaths(append(ids, 999))
+	if len(paths) != 1 || paths[0].PlayerID != "alice" {
+		t.Fatalf("CurrentPaths() = %+v, want only alice's path", paths)
+	}
+
+	// Alice is between tiles, so the path goes through the next tile's
+	// center before the goal.
+	alice := w.SnapshotPlayers()[0]
+	want := []Position{
+		{X: alice.X, Y: alice.Y},
+		{X: w.tileCenter(1), Y: w.tileCenter(0)},
+		{X: w.tileCenter(5), Y: w.tileCenter(0)},
+	}
+	if !reflect.DeepEqual(paths[0].Waypoints, want) {
+		t.Fatalf("waypoints = %v, want the rest of the walk %v", paths[0].Waypoints, want)
+	}
+	if paths[0].StartTick != w.Tick()+1 {
+		t.Fatalf("start tick = %d, want %d", paths[0].StartTick, w.Tick()+1)
+	}
+}
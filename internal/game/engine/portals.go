@@ -0,0 +1,86 @@
+package engine
+
+import "fmt"
+
+// Portal sends a player who walks onto its tile to a spawn point on another
+// map, the destination's default spawn when Spawn is empty.
+type Portal struct {
+	Name  string `json:"name,omitempty"`
+	X     int    `json:"x"`
+	Y     int    `json:"y"`
+	Map   string `json:"map"`
+	Spawn string `json:"spawn,omitempty"`
+}
+
+// PortalTransit is a player who stepped on a portal and is waiting to be
+// moved to its destination by the WorldManager.
+type PortalTransit struct {
+	PlayerID string
+	Map      string
+	Spawn    string
+}
+
+func validatePortals(data MapData, grid tileGrid) error {
+	seen := make(map[int]struct{}, len(data.Portals))
+	for i, portal := range data.Portals {
+		if portal.Map == "" {
+			return fmt.Errorf("portal %d has no destination map", i)
+		}
+		if portal.X < 0 || portal.Y < 0 || portal.X >= data.Width || portal.Y >= data.Height {
+			return fmt.Errorf("portal %d is outside the map", i)
+		}
+
+		index := portal.Y*data.Width + portal.X
+		if !grid.walkable[index] {
+			return fmt.Errorf("portal %d is not walkable", i)
+		}
+		if _, ok := seen[index]; ok {
+			return fmt.Errorf("portal %d shares a tile with another portal", i)
+		}
+		seen[index] = struct{}{}
+	}
+
+	return nil
+}
+
+// AddPlayerAtSpawn spawns a player on the named spawn point, falling back to
+// the default spawn when the map does not declare it.
+func (w *World) AddPlayerAtSpawn(id, spawn string) {
+	w.mu.Lock()
+	defer w.unlockAndNotify()
+
+	tile := w.spawnTile(spawn, 0)
+	w.addPlayerLocked(id, w.tileCenter(tile.X), w.tileCenter(tile.Y))
+}
+
+// DrainPortalTransits returns the players that entered a portal since the
+// last call.
+func (w *World) DrainPortalTransits() []PortalTransit {
+	w.mu.Lock()
+	defer w.unlockAndNotify()
+
+	transits := w.transits
+	w.transits = nil
+
+	return transits
+}
+
+// enterPortal stops a player that just arrived on a portal tile and queues
+// its transit.
+func (w *World) enterPortal(entity *Entity) {
+	tileX, tileY := w.toTileCoords(entity.X, entity.Y)
+	portal, ok := w.portals[w.tileIndex(tileX, tileY)]
+	if !ok {
+		return
+	}
+
+	w.cancelPath(entity)
+	entity.Movement.HasTarget = false
+	delete(w.interactions, entity.ID)
+
+	w.transits = append(w.transits, PortalTransit{
+		PlayerID: entity.PlayerID,
+		Map:      portal.Map,
+		Spawn:    portal.Spawn,
+	})
+}
@@ -0,0 +1,351 @@
+package engine
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"sort"
+)
+
+// RecordingVersion is bumped whenever the recording layout changes:
+//
+//	2: world seed and NPC definitions in the header, NPC despawn events
+//	3: map reload events
+//	4: run energy in checksums
+//	5: the snapshot a restored world started from in the header
+//	6: tile change events and the path hierarchy threshold in the header
+const RecordingVersion = 6
+
+type RecordedEventType string
+
+const (
+	RecordedJoin      RecordedEventType = "join"
+	RecordedLeave     RecordedEventType = "leave"
+	RecordedDespawn   RecordedEventType = "despawn"
+	RecordedMapReload RecordedEventType = "mapReload"
+	RecordedTile      RecordedEventType = "tile"
+)
+
+// RecordingHeader is the first line of a recording. Players lists who was
+// already in the world when recording started; NPCs are respawned on replay
+// from their definitions and the world seed. Restored is the snapshot the
+// world was restored from before its first Step, if any, and is restored
+// again on replay after the NPCs spawn; Seed is then the snapshot's.
+type RecordingHeader struct {
+	Version      int     `json:"version"`
+	Map          MapData `json:"map"`
+	MaxPathNodes int     `json:"maxPathNodes"`
+	// HierarchicalMinTiles is zero when the world planned every route on
+	// the full grid.
+	HierarchicalMinTiles int              `json:"hierarchicalMinTiles,omitempty"`
+	Seed                 int64            `json:"seed,omitempty"`
+	NPCs                 []NPCDefinition  `json:"npcs,omitempty"`
+	Players              []RecordedPlayer `json:"players,omitempty"`
+	Restored             *MapSnapshot     `json:"restored,omitempty"`
+}
+
+type RecordedPlayer struct {
+	ID string `json:"id"`
+	X  int    `json:"x"`
+	Y  int    `json:"y"`
+}
+
+// RecordedEvent is a join, leave, NPC despawn, map reload or tile change that
+// happened between two ticks. Joins carry the spawn position so replays do
+// not depend on spawn rules; reloads carry the whole new map.
+type RecordedEvent struct {
+	Type     RecordedEventType `json:"type"`
+	PlayerID string            `json:"playerId,omitempty"`
+	Entity   EntityID          `json:"entity,omitempty"`
+	X        int               `json:"x,omitempty"`
+	Y        int               `json:"y,omitempty"`
+	Tile     int               `json:"tile,omitempty"`
+	Map      *MapData          `json:"map,omitempty"`
+}
+
+// RecordedFrame is everything that fed into one Step, plus the checksum of
+// the world right after it.
+type RecordedFrame struct {
+	Tick     int64           `json:"t"`
+	Delta    float64         `json:"dt"`
+	Events   []RecordedEvent `json:"e,omitempty"`
+	Commands []Command       `json:"c,omitempty"`
+	Checksum uint64          `json:"sum"`
+}
+
+// Recorder writes a gzip-compressed stream of JSON lines: a header followed by
+// one frame per tick. It is driven by the World it is attached to.
+type Recorder struct {
+	file    io.Closer
+	gz      *gzip.Writer
+	buf     *bufio.Writer
+	enc     *json.Encoder
+	tick    int64
+	pending []RecordedEvent
+	err     error
+}
+
+func NewRecorder(w io.Writer) *Recorder {
+	gz := gzip.NewWriter(w)
+	buf := bufio.NewWriter(gz)
+
+	recorder := &Recorder{
+		gz:  gz,
+		buf: buf,
+		enc: json.NewEncoder(buf),
+	}
+	if closer, ok := w.(io.Closer); ok {
+		recorder.file = closer
+	}
+
+	return recorder
+}
+
+// Err returns the first write error, after which the recorder stops writing.
+func (r *Recorder) Err() error {
+	return r.err
+}
+
+// Close flushes the stream and closes the underlying writer if it is a
+// Closer. Call World.StopRecording first.
+func (r *Recorder) Close() error {
+	if err := r.buf.Flush(); err != nil && r.err == nil {
+		r.err = err
+	}
+	if err := r.gz.Close(); err != nil && r.err == nil {
+		r.err = err
+	}
+	if r.file != nil {
+		if err := r.file.Close(); err != nil && r.err == nil {
+			r.err = err
+		}
+	}
+
+	return r.err
+}
+
+func (r *Recorder) write(value any) {
+	if r.err != nil {
+		return
+	}
+	r.err = r.enc.Encode(value)
+}
+
+func (r *Recorder) recordEvent(event RecordedEvent) {
+	r.pending = append(r.pending, event)
+}
+
+func (r *Recorder) recordFrame(delta float64, commands []Command, checksum uint64) {
+	r.tick += 1
+	r.write(RecordedFrame{
+		Tick:     r.tick,
+		Delta:    delta,
+		Events:   r.pending,
+		Commands: commands,
+		Checksum: checksum,
+	})
+	r.pending = r.pending[:0]
+}
+
+// StartRecording attaches a recorder and writes its header from mapData and
+// the players currently in the world. NPCs are recorded by definition and a
+// restored snapshot only until the first Step, so recording should start
+// before it.
+func (w *World) StartRecording(recorder *Recorder, mapData MapData) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.recorder = recorder
+
+	header := RecordingHeader{
+		Version:              RecordingVersion,
+		Map:                  mapData,
+		MaxPathNodes:         w.maxPathNodes,
+		HierarchicalMinTiles: w.hierarchyMinTiles,
+		Seed:                 w.seed,
+		NPCs:                 w.npcDefs,
+		Restored:             w.restored,
+	}
+	for _, id := range w.sortedPlayerIDs() {
+		player := w.players[id]
+		header.Players = append(header.Players, RecordedPlayer{ID: id, X: player.X, Y: player.Y})
+	}
+	recorder.write(header)
+}
+
+// StopRecording detaches the current recorder so it can be closed safely.
+func (w *World) StopRecording() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.recorder = nil
+}
+
+func (w *World) sortedPlayerIDs() []string {
+	ids := make([]string, 0, len(w.players))
+	for id := range w.players {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+// Checksum hashes the simulation-relevant state of every entity. Players are
+// keyed by user id so recordings stay comparable even though entity ids are
+// reassigned on replay.
+func (w *World) Checksum() uint64 {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	return w.checksum()
+}
+
+func (w *World) checksum() uint64 {
+	hash := fnv.New64a()
+	var scratch [8]byte
+
+	writeInt := func(value int) {
+		binary.LittleEndian.PutUint64(scratch[:], uint64(int64(value)))
+		hash.Write(scratch[:])
+	}
+
+	for _, id := range w.sortedPlayerIDs() {
+		hash.Write([]byte(id))
+		writeEntity(w.players[id], writeInt)
+	}
+	for _, entity := range w.order {
+		if entity.Kind == EntityPlayer {
+			continue
+		}
+		hash.Write([]byte(entity.Kind))
+		hash.Write([]byte(entity.State))
+		writeInt(int(entity.ID))
+		writeEntity(entity, writeInt)
+	}
+
+	return hash.Sum64()
+}
+
+func writeEntity(entity *Entity, writeInt func(int)) {
+	writeInt(entity.X)
+	writeInt(entity.Y)
+	if movement := entity.Movement; movement != nil {
+		writeInt(movement.PathIndex)
+		writeInt(len(movement.Path))
+		writeInt(movement.RunEnergy)
+	}
+}
+
+// ReplayMismatch is a tick whose replayed checksum differs from the recording.
+type ReplayMismatch struct {
+	Tick     int64
+	Recorded uint64
+	Replayed uint64
+}
+
+type ReplayResult struct {
+	Ticks      int64
+	Players    []Player
+	Mismatches []ReplayMismatch
+}
+
+// Replay rebuilds a World from a recording and steps it tick by tick,
+// comparing checksums against the recorded ones. A recording cut off
+// mid-stream is an error, returned with the result of the ticks replayed
+// before it.
+func Replay(r io.Reader) (ReplayResult, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return ReplayResult{}, err
+	}
+	defer gz.Close()
+
+	dec := json.NewDecoder(gz)
+
+	var header RecordingHeader
+	if err := dec.Decode(&header); err != nil {
+		return ReplayResult{}, fmt.Errorf("recording header: %w", err)
+	}
+	if header.Version != RecordingVersion {
+		return ReplayResult{}, fmt.Errorf("unsupported recording version %d", header.Version)
+	}
+	if err := validateMapData(header.Map); err != nil {
+		return ReplayResult{}, err
+	}
+
+	world := NewWorld(header.Map)
+	world.maxPathNodes = header.MaxPathNodes
+	world.hierarchyMinTiles = header.HierarchicalMinTiles
+	world.SetSeed(header.Seed)
+	if err := world.SpawnNPCs(header.NPCs); err != nil {
+		return ReplayResult{}, err
+	}
+	if header.Restored != nil {
+		if err := world.Restore(*header.Restored); err != nil {
+			return ReplayResult{}, fmt.Errorf("recording snapshot: %w", err)
+		}
+	}
+	for _, player := range header.Players {
+		world.addPlayerAt(player.ID, player.X, player.Y)
+	}
+
+	var result ReplayResult
+	for {
+		var frame RecordedFrame
+		if err := dec.Decode(&frame); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return result, fmt.Errorf("recording frame %d: %w", result.Ticks+1, err)
+		}
+
+		for _, event := range frame.Events {
+			switch event.Type {
+			case RecordedJoin:
+				world.addPlayerAt(event.PlayerID, event.X, event.Y)
+			case RecordedLeave:
+				world.RemovePlayer(event.PlayerID)
+			case RecordedDespawn:
+				world.DespawnNPC(event.Entity)
+			case RecordedMapReload:
+				if event.Map == nil {
+					return result, fmt.Errorf("recording frame %d: map reload without a map", frame.Tick)
+				}
+				if err := world.ReloadMap(*event.Map); err != nil {
+					return result, fmt.Errorf("recording frame %d: %w", frame.Tick, err)
+				}
+			case RecordedTile:
+				if err := world.SetTile(event.X, event.Y, event.Tile); err != nil {
+					return result, fmt.Errorf("recording frame %d: %w", frame.Tick, err)
+				}
+			}
+		}
+		for _, cmd := range frame.Commands {
+			world.EnqueueCommand(cmd)
+		}
+
+		world.Step(frame.Delta)
+		result.Ticks = frame.Tick
+
+		if sum := world.Checksum(); sum != frame.Checksum {
+			result.Mismatches = append(result.Mismatches, ReplayMismatch{
+				Tick:     frame.Tick,
+				Recorded: frame.Checksum,
+				Replayed: sum,
+			})
+		}
+	}
+
+	result.Players = world.SnapshotPlayers()
+	sort.Slice(result.Players, func(i, j int) bool {
+		return result.Players[i].ID < result.Players[j].ID
+	})
+
+	return result, nil
+}
@@ -0,0 +1,315 @@
+package engine
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func replayTestNPCs() []NPCDefinition {
+	return []NPCDefinition{
+		{
+			ID:             "wanderer",
+			Count:          4,
+			Spawn:          NPCSpawnArea{X: 12, Y: 12, Radius: 4},
+			Behaviour:      NPCBehaviour{Type: NPCWander, Radius: 6, PauseMin: 0.1, PauseMax: 0.4},
+			RespawnSeconds: 0.5,
+		},
+		{
+			ID:        "guard",
+			Spawn:     NPCSpawnArea{X: 3, Y: 3},
+			Behaviour: NPCBehaviour{Type: NPCPatrol, Route: []MapPoint{{X: 3, Y: 3}, {X: 20, Y: 3}, {X: 20, Y: 20}}},
+		},
+	}
+}
+
+// replayTestMap is a noisy map that keeps the tiles replayTestNPCs spawn on
+// and patrol through open.
+func replayTestMap() MapData {
+	data := noiseMap(24, 0.1, 5, true)
+	for _, def := range replayTestNPCs() {
+		data.Tiles[def.Spawn.Y][def.Spawn.X] = 0
+		for _, point := range def.Behaviour.Route {
+			data.Tiles[point.Y][point.X] = 0
+		}
+	}
+
+	return data
+}
+
+func moveTo(w *World, playerID string, tileX, tileY int) Command {
+	return Command{PlayerID: playerID, Type: CommandMoveTo, X: w.tileCenter(tileX), Y: w.tileCenter(tileY)}
+}
+
+// record steps w for ticks Steps with a recorder attached, calling tick
+// before each one, and returns the recording.
+func record(t *testing.T, w *World, data MapData, ticks int, tick func(w *World, tick int)) *bytes.Buffer {
+	t.Helper()
+
+	var recording bytes.Buffer
+	recorder := NewRecorder(&recording)
+	w.StartRecording(recorder, data)
+	for i := 0; i < ticks; i += 1 {
+		tick(w, i)
+		w.Step(0.05)
+	}
+	w.StopRecording()
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return &recording
+}
+
+func TestReplayRoundTrip(t *testing.T) {
+	const ticks = 200
+	data := replayTestMap()
+
+	tests := []struct {
+		name string
+		// setup runs before recording starts, tick before each recorded
+		// Step.
+		setup func(t *testing.T, w *World)
+		tick  func(w *World, tick int)
+	}{
+		{
+			name: "players moving",
+			setup: func(t *testing.T, w *World) {
+				w.AddPlayer("alice", nil)
+				w.AddPlayer("bob", nil)
+			},
+			tick: func(w *World, tick int) {
+				switch tick {
+				case 0:
+					w.EnqueueCommand(moveTo(w, "alice", 20, 20))
+					w.EnqueueCommand(moveTo(w, "bob", 2, 2))
+				case 40:
+					w.EnqueueCommand(Command{PlayerID: "alice", Type: CommandRun, Run: true})
+				case 90:
+					w.EnqueueCommand(moveTo(w, "bob", 20, 2))
+				case 150:
+					w.EnqueueCommand(Command{PlayerID: "alice", Type: CommandRun, Run: false})
+				}
+			},
+		},
+		{
+			name: "players joining and leaving",
+			tick: func(w *World, tick int) {
+				switch tick {
+				case 10:
+					w.AddPlayer("carol", nil)
+					w.EnqueueCommand(moveTo(w, "carol", 18, 5))
+				case 50:
+					w.AddPlayer("dave", &Position{X: w.tileCenter(5), Y: w.tileCenter(18)})
+				case 120:
+					w.RemovePlayer("carol")
+				}
+			},
+		},
+		{
+			name: "npcs",
+			setup: func(t *testing.T, w *World) {
+				if err := w.SpawnNPCs(replayTestNPCs()); err != nil {
+					t.Fatal(err)
+				}
+			},
+			tick: func(w *World, tick int) {
+				if tick == 30 {
+					for _, entity := range w.SnapshotEntities() {
+						if entity.Kind == EntityNPC {
+							w.DespawnNPC(entity.ID)
+							return
+						}
+					}
+				}
+			},
+		},
+		{
+			name: "map reload",
+			setup: func(t *testing.T, w *World) {
+				w.AddPlayer("erin", nil)
+			},
+			tick: func(w *World, tick int) {
+				switch tick {
+				case 0:
+					w.EnqueueCommand(moveTo(w, "erin", 20, 20))
+				case 60:
+					w.ReloadMap(noiseMap(24, 0.15, 6, false))
+				case 80:
+					w.EnqueueCommand(moveTo(w, "erin", 2, 2))
+				}
+			},
+		},
+		{
+			name: "map changes",
+			setup: func(t *testing.T, w *World) {
+				w.AddPlayer("erin", nil)
+			},
+			tick: func(w *World, tick int) {
+				switch tick {
+				case 0:
+					w.EnqueueCommand(moveTo(w, "erin", 20, 20))
+				case 20:
+					// Wall off the first open tile across the middle row.
+					for x := 1; x < 23; x += 1 {
+						if w.isWalkable(x, 12) && w.SetTile(x, 12, TileWall) == nil {
+							break
+						}
+					}
+				case 40:
+					w.SetTile(1, 1, TileDirt)
+				}
+			},
+		},
+		{
+			name: "restored snapshot",
+			setup: func(t *testing.T, w *World) {
+				previous := NewWorld(data)
+				previous.SetSeed(11)
+				if err := previous.SpawnNPCs(replayTestNPCs()); err != nil {
+					t.Fatal(err)
+				}
+				for i := 0; i < 100; i += 1 {
+					previous.Step(0.05)
+				}
+				raw, err := json.Marshal(previous.Snapshot())
+				if err != nil {
+					t.Fatal(err)
+				}
+				var snapshot MapSnapshot
+				if err := json.Unmarshal(raw, &snapshot); err != nil {
+					t.Fatal(err)
+				}
+
+				if err := w.SpawnNPCs(replayTestNPCs()); err != nil {
+					t.Fatal(err)
+				}
+				if err := w.Restore(snapshot); err != nil {
+					t.Fatal(err)
+				}
+				w.AddPlayer("frank", nil)
+			},
+			tick: func(w *World, tick int) {
+				if tick == 0 {
+					w.EnqueueCommand(moveTo(w, "frank", 12, 12))
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewWorld(data)
+			w.SetSeed(42)
+			if tt.setup != nil {
+				tt.setup(t, w)
+			}
+
+			recording := record(t, w, data, ticks, tt.tick)
+			result, err := Replay(recording)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result.Mismatches) > 0 {
+				t.Fatalf("%d of %d ticks mismatched, first at tick %d", len(result.Mismatches), ticks, result.Mismatches[0].Tick)
+			}
+			if result.Ticks != ticks {
+				t.Fatalf("replayed %d ticks, want %d", result.Ticks, ticks)
+			}
+
+			live := w.SnapshotPlayers()
+			sort.Slice(live, func(i, j int) bool { return live[i].ID < live[j].ID })
+			if !reflect.DeepEqual(result.Players, live) {
+				t.Fatalf("replayed players %+v, want %+v", result.Players, live)
+			}
+		})
+	}
+}
+
+func TestReplayRejectsTruncatedRecordings(t *testing.T) {
+	data := noiseMap(24, 0.1, 5, true)
+	w := NewWorld(data)
+	w.AddPlayer("alice", nil)
+	recording := record(t, w, data, 50, func(w *World, tick int) {
+		if tick == 0 {
+			w.EnqueueCommand(moveTo(w, "alice", 20, 20))
+		}
+	}).Bytes()
+
+	// Cut the recording mid-frame by dropping the last few uncompressed
+	// bytes and compressing the rest again.
+	gz, err := gzip.NewReader(bytes.NewReader(recording))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var raw bytes.Buffer
+	if _, err := raw.ReadFrom(gz); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		recording []byte
+	}{
+		{name: "stream cut off", recording: recording[:len(recording)/2]},
+		{name: "frame cut off", recording: compress(t, raw.Bytes()[:raw.Len()-10])},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Replay(bytes.NewReader(tt.recording)); err == nil {
+				t.Fatal("replayed a truncated recording without an error")
+			}
+		})
+	}
+}
+
+func compress(t *testing.T, data []byte) []byte {
+	t.Helper()
+
+	var out bytes.Buffer
+	gz := gzip.NewWriter(&out)
+	if _, err := gz.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return out.Bytes()
+}
+
+func TestReplayRejectsOtherVersions(t *testing.T) {
+	tests := []struct {
+		name    string
+		version int
+	}{
+		{name: "older", version: RecordingVersion - 1},
+		{name: "newer", version: RecordingVersion + 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var recording bytes.Buffer
+			gz := gzip.NewWriter(&recording)
+			buf := bufio.NewWriter(gz)
+			if err := json.NewEncoder(buf).Encode(RecordingHeader{Version: tt.version, Map: testMap([]string{"..."}, false)}); err != nil {
+				t.Fatal(err)
+			}
+			if err := buf.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			if err := gz.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := Replay(&recording); err == nil {
+				t.Fatal("replayed a recording of another version")
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Well-known boolean region properties. Regions may carry any other
+// properties; these only name the rules game systems are expected to share.
+const (
+	RegionSafe       = "safe"
+	RegionPvP        = "pvp"
+	RegionNoTeleport = "noTeleport"
+)
+
+// Region is a named area of the map carrying rules and UI data in
+// Properties. Like markers, rectangles cover X..X+Width-1 and Y..Y+Height-1
+// and polygons use Polygon, leaving Width and Height at zero.
+type Region struct {
+	Name       string         `json:"name"`
+	X          int            `json:"x,omitempty"`
+	Y          int            `json:"y,omitempty"`
+	Width      int            `json:"width,omitempty"`
+	Height     int            `json:"height,omitempty"`
+	Polygon    []MapPoint     `json:"polygon,omitempty"`
+	Properties map[string]any `json:"properties,omitempty"`
+}
+
+// Contains reports whether the tile at (x, y) lies inside the region.
+func (r Region) Contains(x, y int) bool {
+	return MapMarker{X: r.X, Y: r.Y, Width: r.Width, Height: r.Height, Polygon: r.Polygon}.Contains(x, y)
+}
+
+// Flag reports whether the boolean property name is set.
+func (r Region) Flag(name string) bool {
+	value, _ := r.Properties[name].(bool)
+	return value
+}
+
+// Flags returns the names of the boolean properties that are set, sorted.
+func (r Region) Flags() []string {
+	var flags []string
+	for name, value := range r.Properties {
+		if set, ok := value.(bool); ok && set {
+			flags = append(flags, name)
+		}
+	}
+	sort.Strings(flags)
+
+	return flags
+}
+
+// RegionEvent reports a player entering or leaving a region.
+type RegionEvent struct {
+	PlayerID string
+	Entity   EntityID
+	Region   Region
+	Entered  bool
+}
+
+func validateRegions(data MapData) error {
+	seen := make(map[string]struct{}, len(data.Regions))
+	for i, region := range data.Regions {
+		if region.Name == "" {
+			return fmt.Errorf("region %d has no name", i)
+		}
+		if _, ok := seen[region.Name]; ok {
+			return fmt.Errorf("duplicate region %q", region.Name)
+		}
+		seen[region.Name] = struct{}{}
+
+		if len(region.Polygon) == 0 && (region.Width <= 0 || region.Height <= 0) {
+			return fmt.Errorf("region %q has no area", region.Name)
+		}
+		if len(region.Polygon) > 0 && len(region.Polygon) < 3 {
+			return fmt.Errorf("region %q polygon needs at least 3 points", region.Name)
+		}
+	}
+
+	return nil
+}
+
+// regionTiles lists, for every tile covered by a region, the indices of the
+// regions covering it in map order.
+func regionTiles(data MapData) map[int32][]int {
+	tiles := make(map[int32][]int)
+	for i, region := range data.Regions {
+		for y := 0; y < data.Height; y += 1 {
+			for x := 0; x < data.Width; x += 1 {
+				if region.Contains(x, y) {
+					index := int32(y*data.Width + x)
+					tiles[index] = append(tiles[index], i)
+				}
+			}
+		}
+	}
+
+	return tiles
+}
+
+// DrainRegionEvents returns the region events since the last call, for
+// forwarding to clients. Game systems should subscribe to RegionEntered and
+// RegionLeft instead.
+func (w *World) DrainRegionEvents() []RegionEvent {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	events := w.regionEvents
+	w.regionEvents = nil
+
+	return events
+}
+
+// RegionsAt returns the regions covering the tile, in map order.
+func (w *World) RegionsAt(tileX, tileY int) []Region {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if !w.inBounds(tileX, tileY) {
+		return nil
+	}
+
+	var regions []Region
+	for _, i := range w.regionTiles[w.tileIndex(tileX, tileY)] {
+		regions = append(regions, w.regions[i])
+	}
+
+	return regions
+}
+
+// PlayerRegions returns the regions a player was in at the end of the last
+// Step.
+func (w *World) PlayerRegions(id string) []Region {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	player, ok := w.players[id]
+	if !ok {
+		return nil
+	}
+
+	var regions []Region
+	for _, name := range w.regionsOf[player.ID] {
+		if region, ok := w.regionNamed(name); ok {
+			regions = append(regions, region)
+		}
+	}
+
+	return regions
+}
+
+func (w *World) regionNamed(name string) (Region, bool) {
+	for _, region := range w.regions {
+		if region.Name == name {
+			return region, true
+		}
+	}
+
+	return Region{}, false
+}
+
+// updateRegions compares the regions each player stands in against the ones
+// it was in after the previous Step and queues an event for every change,
+// leaves before enters. Players are visited in entity id order.
+func (w *World) updateRegions() {
+	for _, entity := range w.order {
+		if entity.Kind != EntityPlayer {
+			continue
+		}
+
+		var covering []int
+		tileX, tileY := w.toTileCoords(entity.X, entity.Y)
+		if w.inBounds(tileX, tileY) {
+			covering = w.regionTiles[w.tileIndex(tileX, tileY)]
+		}
+		current := make([]string, 0, len(covering))
+		for _, i := range covering {
+			current = append(current, w.regions[i].Name)
+		}
+
+		previous := w.regionsOf[entity.ID]
+		for _, name := range previous {
+			if !containsString(current, name) {
+				w.queueRegionLeft(entity, name)
+			}
+		}
+		for _, i := range covering {
+			if !containsString(previous, w.regions[i].Name) {
+				w.queueRegionEvent(RegionEvent{PlayerID: entity.PlayerID, Entity: entity.ID, Region: w.regions[i], Entered: true})
+			}
+		}
+
+		if len(current) == 0 {
+			delete(w.regionsOf, entity.ID)
+			continue
+		}
+		w.regionsOf[entity.ID] = current
+	}
+}
+
+// leaveRegions reports a player leaving the map as leaving every region it
+// was in, whether it disconnected or went through a portal.
+func (w *World) leaveRegions(entity *Entity) {
+	for _, name := range w.regionsOf[entity.ID] {
+		w.queueRegionLeft(entity, name)
+	}
+	delete(w.regionsOf, entity.ID)
+}
+
+func (w *World) queueRegionLeft(entity *Entity, name string) {
+	// The region may be gone after a reload, so report what is left of it:
+	// its name.
+	region, ok := w.regionNamed(name)
+	if !ok {
+		region = Region{Name: name}
+	}
+	w.queueRegionEvent(RegionEvent{PlayerID: entity.PlayerID, Entity: entity.ID, Region: region})
+}
+
+func (w *World) queueRegionEvent(event RegionEvent) {
+	w.regionEvents = append(w.regionEvents, event)
+	if event.Entered {
+		w.emit(RegionEntered{EventMeta: w.meta(), PlayerID: event.PlayerID, Entity: event.Entity, Region: event.Region})
+		return
+	}
+	w.emit(RegionLeft{EventMeta: w.meta(), PlayerID: event.PlayerID, Entity: event.Entity, Region: event.Region})
+}
+
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,181 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateRegions(t *testing.T) {
+	square := []MapPoint{{X: 0, Y: 0}, {X: 2, Y: 0}, {X: 2, Y: 2}}
+
+	tests := []struct {
+		name    string
+		regions []Region
+		wantErr bool
+	}{
+		{name: "valid", regions: []Region{{Name: "town", Width: 2, Height: 2}, {Name: "field", Polygon: square}}},
+		{name: "no name", regions: []Region{{Width: 1, Height: 1}}, wantErr: true},
+		{name: "duplicate name", regions: []Region{{Name: "town", Width: 1, Height: 1}, {Name: "town", Width: 1, Height: 1}}, wantErr: true},
+		{name: "no area", regions: []Region{{Name: "town", Width: 2}}, wantErr: true},
+		{name: "short polygon", regions: []Region{{Name: "town", Polygon: square[:2]}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := openMap(4)
+			data.Regions = tt.regions
+			if err := validateMapData(data); (err != nil) != tt.wantErr {
+				t.Fatalf("validateMapData() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRegionFlags(t *testing.T) {
+	region := Region{Name: "town", Properties: map[string]any{
+		RegionSafe:       true,
+		RegionPvP:        false,
+		RegionNoTeleport: true,
+		"music":          "town.ogg",
+	}}
+
+	if !region.Flag(RegionSafe) || region.Flag(RegionPvP) || region.Flag("music") {
+		t.Fatal("Flag() does not match the boolean properties")
+	}
+	if got, want := region.Flags(), []string{RegionNoTeleport, RegionSafe}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Flags() = %v, want %v", got, want)
+	}
+}
+
+// regionWorld is a corridor with a "town" over tiles 2-5 and a "square"
+// over tiles 4-5 inside it.
+func regionWorld() *World {
+	data := corridor(8)
+	data.Regions = []Region{
+		{Name: "town", X: 2, Width: 4, Height: 1},
+		{Name: "square", X: 4, Width: 2, Height: 1},
+	}
+
+	return NewWorld(data)
+}
+
+type regionChange struct {
+	name    string
+	entered bool
+}
+
+func regionChanges(events []RegionEvent) []regionChange {
+	var changes []regionChange
+	for _, event := range events {
+		changes = append(changes, regionChange{name: event.Region.Name, entered: event.Entered})
+	}
+
+	return changes
+}
+
+// listenRegions collects the region events published on the world's bus.
+func listenRegions(w *World) *[]regionChange {
+	var heard []regionChange
+	Subscribe(w.Events(), func(event RegionEntered) {
+		heard = append(heard, regionChange{name: event.Region.Name, entered: true})
+	})
+	Subscribe(w.Events(), func(event RegionLeft) {
+		heard = append(heard, regionChange{name: event.Region.Name})
+	})
+
+	return &heard
+}
+
+func TestRegionEvents(t *testing.T) {
+	w := regionWorld()
+	heard := listenRegions(w)
+	w.AddPlayer("alice", &Position{X: w.tileCenter(0), Y: w.tileCenter(0)})
+	w.SetPlayerTarget("alice", w.tileCenter(7), w.tileCenter(0))
+	for i := 0; i < 100; i += 1 {
+		w.Step(0.05)
+	}
+
+	want := []regionChange{{"town", true}, {"square", true}, {"town", false}, {"square", false}}
+	events := w.DrainRegionEvents()
+	if got := regionChanges(events); !reflect.DeepEqual(got, want) {
+		t.Fatalf("region events = %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(*heard, want) {
+		t.Fatalf("subscribers heard %v, want %v", *heard, want)
+	}
+	for _, event := range events {
+		if event.PlayerID != "alice" {
+			t.Fatalf("event for %q, want alice", event.PlayerID)
+		}
+	}
+}
+
+func TestPlayerRegions(t *testing.T) {
+	w := regionWorld()
+	w.AddPlayer("alice", &Position{X: w.tileCenter(4), Y: w.tileCenter(0)})
+	if got := w.PlayerRegions("alice"); got != nil {
+		t.Fatalf("regions before the first Step = %v, want none", got)
+	}
+	w.Step(0.05)
+
+	var names []string
+	for _, region := range w.PlayerRegions("alice") {
+		names = append(names, region.Name)
+	}
+	if want := []string{"town", "square"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("PlayerRegions() = %v, want %v", names, want)
+	}
+	if got := w.RegionsAt(0, 0); got != nil {
+		t.Fatalf("RegionsAt(0, 0) = %v, want none", got)
+	}
+	if got := w.RegionsAt(2, 0); len(got) != 1 || got[0].Name != "town" {
+		t.Fatalf("RegionsAt(2, 0) = %v, want town", got)
+	}
+}
+
+func TestRemovedPlayersLeaveTheirRegions(t *testing.T) {
+	w := regionWorld()
+	w.AddPlayer("alice", &Position{X: w.tileCenter(4), Y: w.tileCenter(0)})
+	w.Step(0.05)
+	w.DrainRegionEvents()
+	heard := listenRegions(w)
+
+	w.RemovePlayer("alice")
+
+	want := []regionChange{{"town", false}, {"square", false}}
+	if got := regionChanges(w.DrainRegionEvents()); !reflect.DeepEqual(got, want) {
+		t.Fatalf("region events = %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(*heard, want) {
+		t.Fatalf("subscribers heard %v, want %v", *heard, want)
+	}
+
+	// Joining again starts from no regions.
+	w.AddPlayer("alice", &Position{X: w.tileCenter(4), Y: w.tileCenter(0)})
+	w.Step(0.05)
+	want = []regionChange{{"town", true}, {"square", true}}
+	if got := regionChanges(w.DrainRegionEvents()); !reflect.DeepEqual(got, want) {
+		t.Fatalf("region events after rejoining = %v, want %v", got, want)
+	}
+}
+
+func TestPortalsLeaveTheirRegions(t *testing.T) {
+	town := corridor(5)
+	town.Regions = []Region{{Name: "gate", X: 3, Width: 2, Height: 1}}
+	town.Portals = []Portal{{X: 4, Y: 0, Map: "mine"}}
+
+	m := NewWorldManager()
+	townWorld, _ := m.AddMap("town", town)
+	m.AddMap("mine", openMap(4))
+	m.AddPlayer("alice", "town", &Position{X: townWorld.tileCenter(0), Y: townWorld.tileCenter(0)})
+	townWorld.SetPlayerTarget("alice", townWorld.tileCenter(4), townWorld.tileCenter(0))
+
+	for i := 0; i < 100; i += 1 {
+		m.Step(0.05)
+	}
+
+	want := []regionChange{{"gate", true}, {"gate", false}}
+	if got := regionChanges(townWorld.DrainRegionEvents()); !reflect.DeepEqual(got, want) {
+		t.Fatalf("region events = %v, want %v", got, want)
+	}
+}
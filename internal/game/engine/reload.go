@@ -0,0 +1,78 @@
+package engine
+
+// ReloadMap validates data and schedules it to replace the world's map at the
+// start of the next Step, so a tick never sees half of each map. A second
+// call before that Step replaces the pending map.
+func (w *World) ReloadMap(data MapData) error {
+	if err := validateMapData(data); err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.pendingMap = &data
+	return nil
+}
+
+// DrainMapReload reports whether the map was swapped since the last call.
+func (w *World) DrainMapReload() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	reloaded := w.mapReloaded
+	w.mapReloaded = false
+
+	return reloaded
+}
+
+// applyMapReload swaps in the pending map. Objects are rebuilt from the new
+// map, every path and interaction is dropped, and entities left outside the
+// map or on a tile that is no longer walkable move to the nearest free one.
+func (w *World) applyMapReload() {
+	data := *w.pendingMap
+	w.pendingMap = nil
+	if w.recorder != nil {
+		w.recorder.recordEvent(RecordedEvent{Type: RecordedMapReload, Map: &data})
+	}
+
+	for _, entity := range append([]*Entity(nil), w.order...) {
+		if _, ok := w.objects[entity.ID]; ok {
+			w.removeEntityLocked(entity)
+		}
+	}
+	w.interactions = make(map[EntityID]interaction)
+	w.transits = nil
+	w.tileUpdates = nil
+
+	w.loadMap(data)
+
+	for _, entity := range w.order {
+		entity.tile = -1
+		if movement := entity.Movement; movement != nil {
+			w.cancelPath(entity)
+			movement.HasTarget = false
+			movement.blocked = 0
+		}
+
+		tileX, tileY := w.toTileCoords(entity.X, entity.Y)
+		if !w.isWalkable(tileX, tileY) {
+			tile := w.nearestFreeTile(tilePoint{X: tileX, Y: tileY}, entity.ID)
+			entity.X = w.tileCenter(tile.X)
+			entity.Y = w.tileCenter(tile.Y)
+			tileX, tileY = tile.X, tile.Y
+		}
+		if movement := entity.Movement; movement != nil {
+			movement.TargetX = entity.X
+			movement.TargetY = entity.Y
+		}
+
+		w.occupy(entity, w.tileIndex(tileX, tileY))
+		w.chunks.move(entity, w.chunkOf(entity))
+	}
+	w.placeObjects(data.Objects)
+
+	w.mapReloaded = true
+	w.dirty = true
+	w.emit(MapReloaded{EventMeta: w.meta()})
+}
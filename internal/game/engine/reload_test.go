@@ -0,0 +1,191 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReloadMapAppliesAtTheNextStep(t *testing.T) {
+	w := NewWorld(testMap([]string{"....."}, false))
+	if err := w.ReloadMap(MapData{Width: 2, Height: 2}); err == nil {
+		t.Fatal("scheduled an invalid map")
+	}
+
+	if err := w.ReloadMap(testMap([]string{"....#"}, false)); err != nil {
+		t.Fatal(err)
+	}
+	if !w.isWalkable(4, 0) {
+		t.Fatal("the map changed before the next Step")
+	}
+	if w.DrainMapReload() {
+		t.Fatal("reported a reload before the next Step")
+	}
+
+	w.Step(0.05)
+	if w.isWalkable(4, 0) {
+		t.Fatal("the map did not change on the next Step")
+	}
+	if !w.DrainMapReload() {
+		t.Fatal("did not report the reload")
+	}
+	if w.DrainMapReload() {
+		t.Fatal("reported the reload twice")
+	}
+}
+
+func TestReloadMapMovesEntitiesOffWalls(t *testing.T) {
+	data := testMap([]string{"......."}, false)
+	data.Collision = true
+	w := NewWorld(data)
+	w.AddPlayer("alice", &Position{X: w.tileCenter(3), Y: w.tileCenter(0)})
+	w.AddPlayer("bob", &Position{X: w.tileCenter(2), Y: w.tileCenter(0)})
+	w.AddPlayer("carol", &Position{X: w.tileCenter(0), Y: w.tileCenter(0)})
+	w.SetPlayerTarget("carol", w.tileCenter(6), w.tileCenter(0))
+
+	w.DrainPathUpdates()
+
+	reloaded := testMap([]string{"...#..."}, false)
+	reloaded.Collision = true
+	if err := w.ReloadMap(reloaded); err != nil {
+		t.Fatal(err)
+	}
+	w.Step(0.05)
+
+	updates := w.DrainPathUpdates()
+	if len(updates) != 1 || updates[0].PlayerID != "carol" || !updates[0].Cancelled {
+		t.Fatalf("path updates = %+v, want carol's path cancelled", updates)
+	}
+
+	alice := playerTile(w, "alice")
+	if !w.isWalkable(alice.X, alice.Y) {
+		t.Fatalf("alice was left on the wall at %v", alice)
+	}
+	if alice == playerTile(w, "bob") || alice == playerTile(w, "carol") {
+		t.Fatalf("alice moved onto an occupied tile %v", alice)
+	}
+	if playerTile(w, "bob") != (tilePoint{X: 2, Y: 0}) {
+		t.Fatal("bob moved although his tile stayed open")
+	}
+	for _, player := range w.SnapshotPlayers() {
+		if player.Path != nil {
+			t.Fatalf("%s kept a path planned on the old map", player.ID)
+		}
+	}
+}
+
+func TestReloadMapRebuildsObjects(t *testing.T) {
+	w, tree := objectWorld(MapObject{Type: "tree", X: 9, Y: 0}, "alice")
+
+	reloaded := testMap([]string{".........."}, false)
+	reloaded.Objects = []MapObject{{Type: "rock", X: 5, Y: 0}, {Type: "ore", X: 6, Y: 0}}
+	if err := w.ReloadMap(reloaded); err != nil {
+		t.Fatal(err)
+	}
+	w.Step(0.05)
+
+	if _, ok := w.Entity(tree); ok {
+		t.Fatal("the old map's tree survived the reload")
+	}
+	var types []string
+	for _, entity := range w.SnapshotEntities() {
+		if entity.Kind == EntityObject {
+			types = append(types, entity.Type)
+		}
+	}
+	if len(types) != 2 || types[0] != "rock" || types[1] != "ore" {
+		t.Fatalf("objects after the reload = %v, want rock and ore", types)
+	}
+}
+
+func TestWorldManagerReloadMap(t *testing.T) {
+	town := openMap(4)
+	town.Portals = []Portal{{X: 3, Y: 0, Map: "mine", Spawn: "entrance"}}
+	mine := openMap(4)
+	mine.Spawns = []SpawnPoint{{Name: "entrance", X: 1, Y: 1}}
+
+	m := NewWorldManager()
+	m.AddMap("town", town)
+	m.AddMap("mine", mine)
+
+	tests := []struct {
+		name    string
+		mapName string
+		data    MapData
+		wantErr bool
+	}{
+		{name: "unknown map", mapName: "cave", data: openMap(4), wantErr: true},
+		{name: "invalid map", mapName: "mine", data: MapData{Width: 2, Height: 2}, wantErr: true},
+		{name: "drops a portal's spawn", mapName: "mine", data: openMap(4), wantErr: true},
+		{name: "keeps the links", mapName: "mine", data: mine},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := m.ReloadMap(tt.mapName, tt.data); (err != nil) != tt.wantErr {
+				t.Fatalf("ReloadMap() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSetTile(t *testing.T) {
+	data := testMap([]string{"......."}, false)
+	data.Spawns = []SpawnPoint{{Name: SpawnDefault, X: 6, Y: 0}}
+	w := NewWorld(data)
+	w.AddPlayer("alice", &Position{X: w.tileCenter(3), Y: w.tileCenter(0)})
+
+	tests := []struct {
+		name string
+		x, y int
+		tile int
+	}{
+		{name: "outside the map", x: 7, y: 0, tile: TileWall},
+		{name: "unknown tile type", x: 1, y: 0, tile: 9},
+		{name: "wall on a spawn point", x: 6, y: 0, tile: TileWall},
+	}
+	for _, tt := range tests {
+		if err := w.SetTile(tt.x, tt.y, tt.tile); err == nil {
+			t.Fatalf("%s: SetTile() accepted the change", tt.name)
+		}
+	}
+	if updates := w.DrainTileUpdates(); len(updates) != 0 {
+		t.Fatalf("rejected changes reported as %+v", updates)
+	}
+
+	if err := w.SetTile(3, 0, TileWall); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.SetTile(1, 0, TileDirt); err != nil {
+		t.Fatal(err)
+	}
+	// Setting a tile to what it already is changes nothing.
+	if err := w.SetTile(1, 0, TileDirt); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []TileUpdate{{X: 3, Y: 0, Tile: TileWall}, {X: 1, Y: 0, Tile: TileDirt}}
+	if updates := w.DrainTileUpdates(); !reflect.DeepEqual(updates, want) {
+		t.Fatalf("tile updates %+v, want %+v", updates, want)
+	}
+	if w.DrainMapReload() {
+		t.Fatal("a tile change was reported as a map reload")
+	}
+	if alice := playerTile(w, "alice"); !w.isWalkable(alice.X, alice.Y) {
+		t.Fatalf("alice was left on the wall at %v", alice)
+	}
+
+	// A reload replaces the whole map, so pending tile changes are dropped.
+	if err := w.SetTile(5, 0, TileDirt); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.ReloadMap(data); err != nil {
+		t.Fatal(err)
+	}
+	w.Step(0.05)
+	if updates := w.DrainTileUpdates(); len(updates) != 0 {
+		t.Fatalf("tile updates %+v survived the reload", updates)
+	}
+	if !w.DrainMapReload() {
+		t.Fatal("did not report the reload")
+	}
+}
@@ -0,0 +1,189 @@
+package engine
+
+import "sync"
+
+// sightCache remembers line-of-sight and field-of-view results for the
+// current tick. It has its own lock so cached queries can run under the
+// world's read lock, and is emptied whenever the tick or the map changes.
+type sightCache struct {
+	mu     sync.Mutex
+	tick   int64
+	lines  map[[2]int32]bool
+	fields map[fieldKey][]MapPoint
+}
+
+type fieldKey struct {
+	origin int32
+	radius int
+}
+
+// HasLineOfSight reports whether the tile at to can be seen from the tile at
+// from. Only the tiles in between are checked, so a wall can be seen but not
+// seen through, and sight never passes between two diagonal blockers.
+func (w *World) HasLineOfSight(fromX, fromY, toX, toY int) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	return w.lineOfSight(tilePoint{X: fromX, Y: fromY}, tilePoint{X: toX, Y: toY})
+}
+
+// VisibleTiles returns the tiles within radius of the origin tile that have
+// line of sight to it, in row order.
+func (w *World) VisibleTiles(originX, originY, radius int) []MapPoint {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	return w.fieldOfView(tilePoint{X: originX, Y: originY}, radius)
+}
+
+// HasLineOfSightCached is HasLineOfSight for callers repeating the same
+// queries within a tick, such as NPCs checking aggro every Step.
+func (w *World) HasLineOfSightCached(fromX, fromY, toX, toY int) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	return w.lineOfSightCached(tilePoint{X: fromX, Y: fromY}, tilePoint{X: toX, Y: toY})
+}
+
+// VisibleTilesCached is VisibleTiles backed by the per-tick cache.
+func (w *World) VisibleTilesCached(originX, originY, radius int) []MapPoint {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	return w.fieldOfViewCached(tilePoint{X: originX, Y: originY}, radius)
+}
+
+// lineOfSight walks a Bresenham line between the two tiles. The line is
+// always traced from the lower tile index so sight is symmetric.
+func (w *World) lineOfSight(from, to tilePoint) bool {
+	if !w.inBounds(from.X, from.Y) || !w.inBounds(to.X, to.Y) {
+		return false
+	}
+	if w.tileIndex(to.X, to.Y) < w.tileIndex(from.X, from.Y) {
+		from, to = to, from
+	}
+
+	dx := absInt(to.X - from.X)
+	dy := -absInt(to.Y - from.Y)
+	stepX, stepY := sign(to.X-from.X), sign(to.Y-from.Y)
+	err := dx + dy
+
+	x, y := from.X, from.Y
+	for x != to.X || y != to.Y {
+		previousX, previousY := x, y
+		doubled := 2 * err
+		if doubled >= dy {
+			err += dy
+			x += stepX
+		}
+		if doubled <= dx {
+			err += dx
+			y += stepY
+		}
+
+		if x != previousX && y != previousY && w.blocksSight(x, previousY) && w.blocksSight(previousX, y) {
+			return false
+		}
+		if (x != to.X || y != to.Y) && w.blocksSight(x, y) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (w *World) fieldOfView(origin tilePoint, radius int) []MapPoint {
+	if !w.inBounds(origin.X, origin.Y) || radius < 0 {
+		return nil
+	}
+
+	var visible []MapPoint
+	for y := origin.Y - radius; y <= origin.Y+radius; y += 1 {
+		for x := origin.X - radius; x <= origin.X+radius; x += 1 {
+			dx, dy := x-origin.X, y-origin.Y
+			if dx*dx+dy*dy > radius*radius || !w.inBounds(x, y) {
+				continue
+			}
+			if w.lineOfSight(origin, tilePoint{X: x, Y: y}) {
+				visible = append(visible, MapPoint{X: x, Y: y})
+			}
+		}
+	}
+
+	return visible
+}
+
+func (w *World) lineOfSightCached(from, to tilePoint) bool {
+	if !w.inBounds(from.X, from.Y) || !w.inBounds(to.X, to.Y) {
+		return false
+	}
+
+	key := [2]int32{w.tileIndex(from.X, from.Y), w.tileIndex(to.X, to.Y)}
+	if key[1] < key[0] {
+		key[0], key[1] = key[1], key[0]
+	}
+
+	cache := &w.sight
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	w.refreshSightCache()
+	if visible, ok := cache.lines[key]; ok {
+		return visible
+	}
+
+	visible := w.lineOfSight(from, to)
+	cache.lines[key] = visible
+	return visible
+}
+
+func (w *World) fieldOfViewCached(origin tilePoint, radius int) []MapPoint {
+	if !w.inBounds(origin.X, origin.Y) || radius < 0 {
+		return nil
+	}
+
+	key := fieldKey{origin: w.tileIndex(origin.X, origin.Y), radius: radius}
+
+	cache := &w.sight
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	w.refreshSightCache()
+	visible, ok := cache.fields[key]
+	if !ok {
+		visible = w.fieldOfView(origin, radius)
+		cache.fields[key] = visible
+	}
+
+	return append([]MapPoint(nil), visible...)
+}
+
+// refreshSightCache empties the cache when the tick has moved on. Callers
+// must hold w.sight.mu.
+func (w *World) refreshSightCache() {
+	cache := &w.sight
+	if cache.lines != nil && cache.tick == w.tick {
+		return
+	}
+
+	cache.tick = w.tick
+	cache.lines = make(map[[2]int32]bool)
+	cache.fields = make(map[fieldKey][]MapPoint)
+}
+
+// resetSightCache drops every cached result, for when the map changes.
+func (w *World) resetSightCache() {
+	w.sight.mu.Lock()
+	defer w.sight.mu.Unlock()
+
+	w.sight.lines = nil
+	w.sight.fields = nil
+}
+
+func (w *World) blocksSight(x, y int) bool {
+	if !w.inBounds(x, y) {
+		return true
+	}
+
+	return w.tiles.blocksSight[w.tileIndex(x, y)]
+}
@@ -0,0 +1,177 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+)
+
+const (
+	testTileWater     = 3
+	testTileTallGrass = 4
+)
+
+// sightMap is testMap with water ('~'), which stops walkers but not sight,
+// and tall grass ('"'), which hides what is behind it but can be walked.
+func sightMap(rows []string) MapData {
+	data := testMap(rows, false)
+	data.TileTypes = append(DefaultTileTypes(),
+		TileType{ID: testTileWater, Name: "water"},
+		TileType{ID: testTileTallGrass, Name: "tall grass", Walkable: true, BlocksSight: true},
+	)
+	for y, row := range rows {
+		for x, cell := range row {
+			switch cell {
+			case '~':
+				data.Tiles[y][x] = testTileWater
+			case '"':
+				data.Tiles[y][x] = testTileTallGrass
+			}
+		}
+	}
+
+	return data
+}
+
+func TestHasLineOfSight(t *testing.T) {
+	w := NewWorld(sightMap([]string{
+		".....#....",
+		"..#..#.~~.",
+		".#.......\"",
+		"..........",
+	}))
+
+	tests := []struct {
+		name     string
+		from, to tilePoint
+		want     bool
+	}{
+		{name: "same tile", from: tilePoint{X: 0, Y: 0}, to: tilePoint{X: 0, Y: 0}, want: true},
+		{name: "open row", from: tilePoint{X: 0, Y: 3}, to: tilePoint{X: 9, Y: 3}, want: true},
+		{name: "through a wall", from: tilePoint{X: 4, Y: 0}, to: tilePoint{X: 6, Y: 0}},
+		{name: "onto a wall", from: tilePoint{X: 4, Y: 0}, to: tilePoint{X: 5, Y: 0}, want: true},
+		{name: "between diagonal walls", from: tilePoint{X: 1, Y: 1}, to: tilePoint{X: 2, Y: 2}},
+		{name: "over water", from: tilePoint{X: 6, Y: 1}, to: tilePoint{X: 9, Y: 1}, want: true},
+		{name: "into tall grass", from: tilePoint{X: 6, Y: 2}, to: tilePoint{X: 9, Y: 2}, want: true},
+		{name: "through tall grass", from: tilePoint{X: 9, Y: 1}, to: tilePoint{X: 9, Y: 3}},
+		{name: "off the map", from: tilePoint{X: 0, Y: 0}, to: tilePoint{X: 10, Y: 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := w.HasLineOfSight(tt.from.X, tt.from.Y, tt.to.X, tt.to.Y); got != tt.want {
+				t.Fatalf("HasLineOfSight(%v, %v) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+			if got := w.HasLineOfSight(tt.to.X, tt.to.Y, tt.from.X, tt.from.Y); got != tt.want {
+				t.Fatalf("HasLineOfSight(%v, %v) = %v, want %v", tt.to, tt.from, got, tt.want)
+			}
+			if got := w.HasLineOfSightCached(tt.from.X, tt.from.Y, tt.to.X, tt.to.Y); got != tt.want {
+				t.Fatalf("HasLineOfSightCached(%v, %v) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVisibleTiles(t *testing.T) {
+	w := NewWorld(sightMap([]string{
+		".....",
+		".....",
+		"..#..",
+		".....",
+		".....",
+	}))
+
+	tests := []struct {
+		name   string
+		origin tilePoint
+		radius int
+		want   []string
+	}{
+		{name: "radius zero", origin: tilePoint{X: 2, Y: 4}, radius: 0, want: []string{
+			".....",
+			".....",
+			".....",
+			".....",
+			"..o..",
+		}},
+		{name: "circle", origin: tilePoint{X: 2, Y: 4}, radius: 2, want: []string{
+			".....",
+			".....",
+			"..o..",
+			".ooo.",
+			"ooooo",
+		}},
+		{name: "behind the wall", origin: tilePoint{X: 2, Y: 4}, radius: 4, want: []string{
+			".....",
+			"oo.oo",
+			"ooooo",
+			"ooooo",
+			"ooooo",
+		}},
+		{name: "negative radius", origin: tilePoint{X: 2, Y: 4}, radius: -1, want: []string{
+			".....",
+			".....",
+			".....",
+			".....",
+			".....",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var want []MapPoint
+			for y, row := range tt.want {
+				for x, cell := range row {
+					if cell == 'o' {
+						want = append(want, MapPoint{X: x, Y: y})
+					}
+				}
+			}
+
+			if got := w.VisibleTiles(tt.origin.X, tt.origin.Y, tt.radius); !reflect.DeepEqual(got, want) {
+				t.Fatalf("VisibleTiles() = %v, want %v", got, want)
+			}
+			if got := w.VisibleTilesCached(tt.origin.X, tt.origin.Y, tt.radius); !reflect.DeepEqual(got, want) {
+				t.Fatalf("VisibleTilesCached() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestSightCacheMatchesUncachedQueries(t *testing.T) {
+	w := NewWorld(noiseMap(16, 0.25, 3, false))
+	for fromY := 0; fromY < 16; fromY += 3 {
+		for fromX := 0; fromX < 16; fromX += 3 {
+			for toY := 0; toY < 16; toY += 1 {
+				for toX := 0; toX < 16; toX += 1 {
+					want := w.HasLineOfSight(fromX, fromY, toX, toY)
+					for i := 0; i < 2; i += 1 {
+						if got := w.HasLineOfSightCached(fromX, fromY, toX, toY); got != want {
+							t.Fatalf("cached sight from (%d, %d) to (%d, %d) = %v, want %v", fromX, fromY, toX, toY, got, want)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	visible := w.VisibleTilesCached(8, 8, 5)
+	visible[0] = MapPoint{X: -1, Y: -1}
+	if got := w.VisibleTilesCached(8, 8, 5); !reflect.DeepEqual(got, w.VisibleTiles(8, 8, 5)) {
+		t.Fatal("changing a cached result changed the cache")
+	}
+}
+
+func TestSightCacheFollowsMapReloads(t *testing.T) {
+	w := NewWorld(sightMap([]string{"....."}))
+	if !w.HasLineOfSightCached(0, 0, 4, 0) {
+		t.Fatal("no sight along an open row")
+	}
+
+	if err := w.ReloadMap(sightMap([]string{"..#.."})); err != nil {
+		t.Fatal(err)
+	}
+	w.Step(0.05)
+	if w.HasLineOfSightCached(0, 0, 4, 0) {
+		t.Fatal("the cache kept sight through a wall added by a reload")
+	}
+}
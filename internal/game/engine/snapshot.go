@@ -0,0 +1,402 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// SnapshotVersion is the WorldSnapshot layout this build writes and restores.
+// Bump it whenever a field changes meaning.
+const SnapshotVersion = 1
+
+// WorldSnapshot is a copy of every hosted World, taken to survive restarts.
+type WorldSnapshot struct {
+	Version int           `json:"version"`
+	SavedAt time.Time     `json:"savedAt"`
+	Maps    []MapSnapshot `json:"maps"`
+}
+
+// MapSnapshot is the state of one World. The map itself is not included:
+// a restored world keeps the map it was loaded with.
+type MapSnapshot struct {
+	Name         string            `json:"name"`
+	Tick         int64             `json:"tick"`
+	Seed         int64             `json:"seed"`
+	NextEntityID EntityID          `json:"nextEntityId"`
+	Entities     []EntitySnapshot  `json:"entities"`
+	Respawns     []RespawnSnapshot `json:"respawns,omitempty"`
+}
+
+// EntitySnapshot is an entity in map order. RespawnIn is only set for
+// depleted objects and Behaviour only for wandering and patrolling NPCs.
+type EntitySnapshot struct {
+	ID        EntityID           `json:"id"`
+	Kind      EntityKind         `json:"kind"`
+	PlayerID  string             `json:"playerId,omitempty"`
+	Type      string             `json:"type,omitempty"`
+	State     ObjectState        `json:"state,omitempty"`
+	X         int                `json:"x"`
+	Y         int                `json:"y"`
+	Movement  *MovementSnapshot  `json:"movement,omitempty"`
+	Behaviour *BehaviourSnapshot `json:"behaviour,omitempty"`
+	RespawnIn int                `json:"respawnIn,omitempty"`
+}
+
+type MovementSnapshot struct {
+	TargetX   int        `json:"targetX"`
+	TargetY   int        `json:"targetY"`
+	HasTarget bool       `json:"hasTarget,omitempty"`
+	Path      []MapPoint `json:"path,omitempty"`
+	PathIndex int        `json:"pathIndex,omitempty"`
+	Speed     float64    `json:"speed,omitempty"`
+	Running   bool       `json:"running,omitempty"`
+	RunEnergy int        `json:"runEnergy"`
+}
+
+// BehaviourSnapshot is the progress of an NPC behaviour: the wander home
+// tile, or the next patrol point, and the pause left before moving again.
+type BehaviourSnapshot struct {
+	Home *MapPoint `json:"home,omitempty"`
+	Next int       `json:"next,omitempty"`
+	Wait float64   `json:"wait,omitempty"`
+}
+
+// RespawnSnapshot is a despawned NPC waiting to come back.
+type RespawnSnapshot struct {
+	NPC       string  `json:"npc"`
+	Remaining float64 `json:"remaining"`
+}
+
+// Snapshot copies the world's state under its lock. Encoding the copy is
+// left to the caller, off the tick goroutine.
+func (w *World) Snapshot() MapSnapshot {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	snapshot := MapSnapshot{
+		Name:         w.name,
+		Tick:         w.tick,
+		Seed:         w.seed,
+		NextEntityID: w.nextEntityID,
+		Entities:     make([]EntitySnapshot, 0, len(w.order)),
+	}
+	for _, entity := range w.order {
+		snapshot.Entities = append(snapshot.Entities, w.entitySnapshot(entity))
+	}
+	for _, respawn := range w.respawns {
+		snapshot.Respawns = append(snapshot.Respawns, RespawnSnapshot{NPC: respawn.def.ID, Remaining: respawn.remaining})
+	}
+
+	return snapshot
+}
+
+func (w *World) entitySnapshot(entity *Entity) EntitySnapshot {
+	snapshot := EntitySnapshot{
+		ID:       entity.ID,
+		Kind:     entity.Kind,
+		PlayerID: entity.PlayerID,
+		Type:     entity.Type,
+		State:    entity.State,
+		X:        entity.X,
+		Y:        entity.Y,
+	}
+	if object, ok := w.objects[entity.ID]; ok && entity.State == ObjectDepleted {
+		snapshot.RespawnIn = object.respawnIn
+	}
+
+	if movement := entity.Movement; movement != nil {
+		copied := &MovementSnapshot{
+			TargetX:   movement.TargetX,
+			TargetY:   movement.TargetY,
+			HasTarget: movement.HasTarget,
+			PathIndex: movement.PathIndex,
+			Speed:     movement.Speed,
+			Running:   movement.Running,
+			RunEnergy: movement.RunEnergy,
+		}
+		for _, tile := range movement.Path {
+			copied.Path = append(copied.Path, MapPoint{X: tile.X, Y: tile.Y})
+		}
+		snapshot.Movement = copied
+	}
+
+	switch behaviour := entity.Behaviour.(type) {
+	case *wanderBehaviour:
+		snapshot.Behaviour = &BehaviourSnapshot{Home: &MapPoint{X: behaviour.home.X, Y: behaviour.home.Y}, Wait: behaviour.wait}
+	case *patrolBehaviour:
+		snapshot.Behaviour = &BehaviourSnapshot{Next: behaviour.next, Wait: behaviour.wait}
+	}
+
+	return snapshot
+}
+
+// Restore replaces the world's NPCs and objects with the ones in snapshot.
+// It must run before any player joins, after SpawnNPCs, because NPCs are
+// rebuilt from the definitions the world was given: NPCs whose definition is
+// gone and objects the map no longer places are dropped, and NPCs or objects
+// missing from the snapshot are spawned fresh. Players and entities added
+// through AddEntity are not restored. The random source is reseeded from the
+// snapshot's seed and tick, so spawns after a restore differ from the ones
+// the snapshotted world would have made.
+func (w *World) Restore(snapshot MapSnapshot) error {
+	w.mu.Lock()
+	defer w.unlockAndNotify()
+
+	if len(w.players) > 0 {
+		return fmt.Errorf("cannot restore a world with players")
+	}
+	if w.recorder != nil {
+		return fmt.Errorf("cannot restore a world while recording")
+	}
+
+	for _, entity := range append([]*Entity(nil), w.order...) {
+		w.removeEntityLocked(entity)
+	}
+	w.interactions = make(map[EntityID]interaction)
+	w.respawns = nil
+	w.tick = snapshot.Tick
+	w.seed = snapshot.Seed
+	w.rng = rand.New(rand.NewSource(snapshot.Seed ^ snapshot.Tick))
+
+	defs := make(map[string]*NPCDefinition, len(w.npcDefs))
+	for i := range w.npcDefs {
+		defs[w.npcDefs[i].ID] = &w.npcDefs[i]
+	}
+	// Objects are matched to the map by type and tile, each map object at
+	// most once.
+	type objectKey struct {
+		kind string
+		x, y int
+	}
+	unplaced := make(map[objectKey][]MapObject, len(w.source.Objects))
+	for _, def := range w.source.Objects {
+		key := objectKey{kind: def.Type, x: def.X, y: def.Y}
+		unplaced[key] = append(unplaced[key], def)
+	}
+	alive := make(map[string]int, len(defs))
+
+	entities := append([]EntitySnapshot(nil), snapshot.Entities...)
+	sort.Slice(entities, func(i, j int) bool { return entities[i].ID < entities[j].ID })
+	lastID := EntityID(0)
+	for _, saved := range entities {
+		if saved.ID <= lastID {
+			continue
+		}
+
+		switch saved.Kind {
+		case EntityObject:
+			tileX, tileY := w.toTileCoords(saved.X, saved.Y)
+			key := objectKey{kind: saved.Type, x: tileX, y: tileY}
+			if len(unplaced[key]) == 0 {
+				continue
+			}
+			def := unplaced[key][0]
+			unplaced[key] = unplaced[key][1:]
+			w.restoreObject(saved, def)
+		case EntityNPC:
+			def, ok := defs[saved.Type]
+			if !ok || alive[def.ID] >= def.count() {
+				continue
+			}
+			alive[def.ID] += 1
+			w.restoreNPC(saved, def)
+		default:
+			continue
+		}
+		lastID = saved.ID
+	}
+	if snapshot.NextEntityID > w.nextEntityID {
+		w.nextEntityID = snapshot.NextEntityID
+	}
+	if lastID > w.nextEntityID {
+		w.nextEntityID = lastID
+	}
+
+	for _, saved := range snapshot.Respawns {
+		def, ok := defs[saved.NPC]
+		if !ok || alive[def.ID] >= def.count() {
+			continue
+		}
+		alive[def.ID] += 1
+		w.respawns = append(w.respawns, npcRespawn{def: def, remaining: saved.Remaining})
+	}
+
+	for _, def := range w.source.Objects {
+		key := objectKey{kind: def.Type, x: def.X, y: def.Y}
+		if len(unplaced[key]) > 0 {
+			w.placeObjects(unplaced[key][:1])
+			unplaced[key] = unplaced[key][1:]
+		}
+	}
+	for i := range w.npcDefs {
+		def := &w.npcDefs[i]
+		for n := alive[def.ID]; n < def.count(); n += 1 {
+			w.spawnNPC(def)
+		}
+	}
+
+	w.restored = &snapshot
+	w.dirty = true
+	return nil
+}
+
+func (w *World) restoreObject(saved EntitySnapshot, def MapObject) {
+	entity := &Entity{
+		ID:    saved.ID,
+		Kind:  EntityObject,
+		Type:  def.Type,
+		State: ObjectAvailable,
+		X:     w.tileCenter(def.X),
+		Y:     w.tileCenter(def.Y),
+	}
+	object := &worldObject{def: def}
+	if saved.State == ObjectDepleted && def.RespawnTicks > 0 && saved.RespawnIn > 0 {
+		entity.State = ObjectDepleted
+		object.respawnIn = min(saved.RespawnIn, def.RespawnTicks)
+	}
+
+	w.insertEntityLocked(entity)
+	w.objects[entity.ID] = object
+}
+
+// restoreNPC rebuilds an NPC from its definition, keeping the saved position,
+// path and behaviour progress when they still fit the map.
+func (w *World) restoreNPC(saved EntitySnapshot, def *NPCDefinition) {
+	tile := tilePoint{}
+	tile.X, tile.Y = w.toTileCoords(saved.X, saved.Y)
+	relocated := !w.isWalkable(tile.X, tile.Y) || w.tileHasOtherEntity(tile, 0)
+	if relocated {
+		tile = w.nearestFreeTile(tile, 0)
+	}
+
+	entity := &Entity{
+		ID:   saved.ID,
+		Kind: EntityNPC,
+		Type: def.ID,
+		X:    saved.X,
+		Y:    saved.Y,
+	}
+	if relocated {
+		entity.X = w.tileCenter(tile.X)
+		entity.Y = w.tileCenter(tile.Y)
+	}
+
+	config := def.Behaviour
+	progress := BehaviourSnapshot{}
+	if saved.Behaviour != nil {
+		progress = *saved.Behaviour
+	}
+	switch config.Type {
+	case NPCWander:
+		home := tile
+		if progress.Home != nil && w.isWalkable(progress.Home.X, progress.Home.Y) {
+			home = tilePoint{X: progress.Home.X, Y: progress.Home.Y}
+		}
+		entity.Behaviour = &wanderBehaviour{home: home, radius: config.Radius, pauseMin: config.PauseMin, pauseMax: config.PauseMax, wait: progress.Wait}
+	case NPCPatrol:
+		route := make([]tilePoint, 0, len(config.Route))
+		for _, point := range config.Route {
+			route = append(route, tilePoint{X: point.X, Y: point.Y})
+		}
+		next := 0
+		if progress.Next > 0 && progress.Next < len(route) {
+			next = progress.Next
+		}
+		entity.Behaviour = &patrolBehaviour{route: route, next: next, pauseMin: config.PauseMin, pauseMax: config.PauseMax, wait: progress.Wait}
+	}
+
+	if entity.Behaviour != nil {
+		movement := &Movement{TargetX: entity.X, TargetY: entity.Y, Speed: def.Speed}
+		if saved.Movement != nil {
+			movement.Running = saved.Movement.Running
+			movement.RunEnergy = min(max(saved.Movement.RunEnergy, 0), MaxRunEnergy)
+		}
+		if saved.Movement != nil && !relocated && w.restorablePath(saved.Movement) {
+			movement.TargetX = saved.Movement.TargetX
+			movement.TargetY = saved.Movement.TargetY
+			movement.HasTarget = saved.Movement.HasTarget
+			movement.PathIndex = saved.Movement.PathIndex
+			for _, point := range saved.Movement.Path {
+				movement.Path = append(movement.Path, tilePoint{X: point.X, Y: point.Y})
+			}
+		}
+		entity.Movement = movement
+	}
+
+	w.insertEntityLocked(entity)
+	w.npcs[entity.ID] = def
+
+	// An NPC caught between tiles held the tile it was heading to, as
+	// commitNext reserves it. If that tile has been taken since, the NPC
+	// commits again on its next step and waits like any blocked mover.
+	if movement := entity.Movement; movement != nil && movement.HasTarget {
+		next := movement.Path[movement.PathIndex]
+		index := w.tileIndex(next.X, next.Y)
+		if w.blockedFor(index, entity) {
+			movement.HasTarget = false
+		} else {
+			w.occupy(entity, index)
+		}
+	}
+}
+
+func (w *World) restorablePath(movement *MovementSnapshot) bool {
+	if len(movement.Path) == 0 {
+		return false
+	}
+	if movement.PathIndex < 0 || movement.PathIndex >= len(movement.Path) {
+		return false
+	}
+	for _, point := range movement.Path {
+		if !w.isWalkable(point.X, point.Y) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Snapshot copies every hosted World, in map name order. Portal transfers
+// are held off while it runs so a player is never caught on two maps.
+func (m *WorldManager) Snapshot() WorldSnapshot {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	snapshot := WorldSnapshot{
+		Version: SnapshotVersion,
+		SavedAt: time.Now().UTC(),
+		Maps:    make([]MapSnapshot, 0, len(m.names)),
+	}
+	for _, name := range m.names {
+		snapshot.Maps = append(snapshot.Maps, m.worlds[name].Snapshot())
+	}
+
+	return snapshot
+}
+
+// Restore restores every hosted map found in snapshot. Maps the snapshot
+// does not cover keep their fresh state; the errors of maps that could not
+// be restored, including ones no longer hosted, are returned together after
+// the others have been restored.
+func (m *WorldManager) Restore(snapshot WorldSnapshot) error {
+	if snapshot.Version != SnapshotVersion {
+		return fmt.Errorf("unsupported snapshot version %d", snapshot.Version)
+	}
+
+	var errs []error
+	for _, saved := range snapshot.Maps {
+		world, ok := m.World(saved.Name)
+		if !ok {
+			errs = append(errs, fmt.Errorf("map %q: not hosted", saved.Name))
+			continue
+		}
+		if err := world.Restore(saved); err != nil {
+			errs = append(errs, fmt.Errorf("map %q: %w", saved.Name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,300 @@
+package engine
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// snapshotJSON snapshots w through JSON, the way the server stores it.
+func snapshotJSON(t *testing.T, w *World) MapSnapshot {
+	t.Helper()
+
+	raw, err := json.Marshal(w.Snapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var snapshot MapSnapshot
+	if err := json.Unmarshal(raw, &snapshot); err != nil {
+		t.Fatal(err)
+	}
+
+	return snapshot
+}
+
+func TestSnapshotRestoreRoundTrip(t *testing.T) {
+	data := replayTestMap()
+	data.Objects = []MapObject{{Type: "tree", X: 12, Y: 12, RespawnTicks: 50}}
+
+	previous := NewWorld(data)
+	previous.SetSeed(7)
+	if err := previous.SpawnNPCs(replayTestNPCs()); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i += 1 {
+		previous.Step(0.05)
+	}
+	saved := snapshotJSON(t, previous)
+
+	w := NewWorld(data)
+	w.SetSeed(99)
+	if err := w.SpawnNPCs(replayTestNPCs()); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Restore(saved); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := w.Tick(); got != previous.Tick() {
+		t.Fatalf("restored tick %d, want %d", got, previous.Tick())
+	}
+	restored := w.Snapshot()
+	if !reflect.DeepEqual(restored.Entities, saved.Entities) {
+		t.Fatalf("restored entities %+v, want %+v", restored.Entities, saved.Entities)
+	}
+	if w.Checksum() != previous.Checksum() {
+		t.Fatal("restored world checksum differs from the snapshotted one")
+	}
+}
+
+func TestRestoreKeepsDepletedObjects(t *testing.T) {
+	data := openMap(4)
+	data.Objects = []MapObject{{Type: "tree", X: 2, Y: 2, RespawnTicks: 10}}
+	w := NewWorld(data)
+	object := w.SnapshotEntities()[0]
+
+	saved := MapSnapshot{
+		NextEntityID: object.ID,
+		Entities: []EntitySnapshot{
+			{ID: object.ID, Kind: EntityObject, Type: "tree", State: ObjectDepleted, X: object.X, Y: object.Y, RespawnIn: 3},
+		},
+	}
+	if err := w.Restore(saved); err != nil {
+		t.Fatal(err)
+	}
+	if got := objectState(w, object.ID); got != ObjectDepleted {
+		t.Fatalf("restored state %q, want %q", got, ObjectDepleted)
+	}
+
+	for i := 0; i < 3; i += 1 {
+		w.Step(0.05)
+	}
+	if got := objectState(w, object.ID); got != ObjectAvailable {
+		t.Fatalf("state after the saved respawn time %q, want %q", got, ObjectAvailable)
+	}
+}
+
+func TestRestoreFitsTheCurrentDefinitions(t *testing.T) {
+	data := openMap(10)
+	data.Objects = []MapObject{{Type: "tree", X: 1, Y: 1}}
+	defs := []NPCDefinition{
+		{ID: "guard", Count: 2, Spawn: NPCSpawnArea{X: 5, Y: 5, Radius: 2}, Behaviour: NPCBehaviour{Type: NPCWander, Radius: 2}},
+	}
+	w := NewWorld(data)
+	if err := w.SpawnNPCs(defs); err != nil {
+		t.Fatal(err)
+	}
+
+	saved := MapSnapshot{
+		NextEntityID: 20,
+		Entities: []EntitySnapshot{
+			// A map object that was moved and an NPC whose definition is gone.
+			{ID: 10, Kind: EntityObject, Type: "tree", X: w.tileCenter(8), Y: w.tileCenter(8)},
+			{ID: 11, Kind: EntityNPC, Type: "ghost", X: w.tileCenter(3), Y: w.tileCenter(3)},
+			{ID: 12, Kind: EntityNPC, Type: "guard", X: w.tileCenter(4), Y: w.tileCenter(4)},
+			// Players are not restored.
+			{ID: 13, Kind: EntityPlayer, PlayerID: "alice", X: w.tileCenter(6), Y: w.tileCenter(6)},
+		},
+	}
+	if err := w.Restore(saved); err != nil {
+		t.Fatal(err)
+	}
+
+	kinds := make(map[EntityKind]int)
+	for _, entity := range w.SnapshotEntities() {
+		kinds[entity.Kind] += 1
+		switch {
+		case entity.Kind == EntityObject:
+			if tileX, tileY := w.toTileCoords(entity.X, entity.Y); tileX != 1 || tileY != 1 {
+				t.Fatalf("tree at tile (%d, %d), want the map's (1, 1)", tileX, tileY)
+			}
+		case entity.ID == 12:
+			if tileX, tileY := w.toTileCoords(entity.X, entity.Y); tileX != 4 || tileY != 4 {
+				t.Fatalf("restored guard at tile (%d, %d), want (4, 4)", tileX, tileY)
+			}
+		case entity.ID <= 20:
+			t.Fatalf("entity %d %+v should not have been restored", entity.ID, entity)
+		}
+	}
+	want := map[EntityKind]int{EntityObject: 1, EntityNPC: 2}
+	if !reflect.DeepEqual(kinds, want) {
+		t.Fatalf("restored %v, want %v", kinds, want)
+	}
+}
+
+func TestRestoreReservesNextTile(t *testing.T) {
+	patrol := NPCBehaviour{Type: NPCPatrol, Route: []MapPoint{{X: 0, Y: 0}, {X: 3, Y: 0}}}
+	defs := []NPCDefinition{{ID: "walker", Count: 2, Spawn: NPCSpawnArea{X: 1, Y: 0, Radius: 1}, Behaviour: patrol}}
+	path := []MapPoint{{X: 0, Y: 0}, {X: 1, Y: 0}, {X: 2, Y: 0}, {X: 3, Y: 0}}
+	data := testMap([]string{"....", "...."}, false)
+	data.Collision = true
+
+	tests := []struct {
+		name       string
+		blocker    *MapPoint
+		wantTarget bool
+	}{
+		{name: "free", wantTarget: true},
+		{name: "taken since", blocker: &MapPoint{X: 1, Y: 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewWorld(data)
+			if err := w.SpawnNPCs(defs); err != nil {
+				t.Fatal(err)
+			}
+
+			// The walker left tile 0 for tile 1 and is still on its way.
+			walker := EntitySnapshot{
+				ID:   2,
+				Kind: EntityNPC,
+				Type: "walker",
+				X:    w.tileCenter(0) + 1,
+				Y:    w.tileCenter(0),
+				Movement: &MovementSnapshot{
+					TargetX:   w.tileCenter(1),
+					TargetY:   w.tileCenter(0),
+					HasTarget: true,
+					Path:      path,
+					PathIndex: 1,
+				},
+				Behaviour: &BehaviourSnapshot{Next: 1},
+			}
+			// The other NPC is restored first, so it already holds its tile.
+			other := EntitySnapshot{ID: 1, Kind: EntityNPC, Type: "walker", X: w.tileCenter(0), Y: w.tileCenter(1)}
+			if tt.blocker != nil {
+				other.X = w.tileCenter(tt.blocker.X)
+				other.Y = w.tileCenter(tt.blocker.Y)
+			}
+
+			if err := w.Restore(MapSnapshot{NextEntityID: 2, Entities: []EntitySnapshot{walker, other}}); err != nil {
+				t.Fatal(err)
+			}
+
+			entity, ok := w.Entity(walker.ID)
+			if !ok {
+				t.Fatal("walker was not restored")
+			}
+			if entity.Movement.HasTarget != tt.wantTarget {
+				t.Fatalf("HasTarget = %v, want %v", entity.Movement.HasTarget, tt.wantTarget)
+			}
+			next := w.tileIndex(1, 0)
+			if got := w.entities[walker.ID].tile == next; got != tt.wantTarget {
+				t.Fatalf("walker holds its next tile = %v, want %v", got, tt.wantTarget)
+			}
+			if got := w.occupants[next]; got != 1 {
+				t.Fatalf("%d entities hold the next tile, want 1", got)
+			}
+
+			// Whoever holds the tile, the walker gets there without sharing it.
+			for i := 0; i < 40; i += 1 {
+				w.Step(0.05)
+				for index, count := range w.occupants {
+					if count > 1 {
+						t.Fatalf("tick %d: %d entities share tile %d", i, count, index)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestRestoreRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		prepare func(w *World)
+		wantErr string
+	}{
+		{
+			name:    "players",
+			prepare: func(w *World) { w.AddPlayer("alice", nil) },
+			wantErr: "players",
+		},
+		{
+			name:    "recording",
+			prepare: func(w *World) { w.StartRecording(NewRecorder(&strings.Builder{}), openMap(4)) },
+			wantErr: "recording",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewWorld(openMap(4))
+			tt.prepare(w)
+			err := w.Restore(MapSnapshot{})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Restore() error = %v, want one mentioning %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWorldManagerRestore(t *testing.T) {
+	newManager := func() *WorldManager {
+		m := NewWorldManager()
+		for _, name := range []string{"mine", "town"} {
+			if _, err := m.AddMap(name, openMap(4)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return m
+	}
+
+	saved := newManager()
+	for i := 0; i < 5; i += 1 {
+		saved.Step(0.05)
+	}
+	snapshot := saved.Snapshot()
+	if len(snapshot.Maps) != 2 || snapshot.Maps[0].Name != "mine" || snapshot.Maps[1].Name != "town" {
+		t.Fatalf("snapshot maps %+v, want mine and town", snapshot.Maps)
+	}
+
+	t.Run("restores every map", func(t *testing.T) {
+		m := newManager()
+		if err := m.Restore(snapshot); err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range m.Names() {
+			world, _ := m.World(name)
+			if world.Tick() != 5 {
+				t.Fatalf("map %s at tick %d, want 5", name, world.Tick())
+			}
+		}
+	})
+
+	t.Run("unhosted map", func(t *testing.T) {
+		m := newManager()
+		unhosted := snapshot
+		unhosted.Maps = append([]MapSnapshot{{Name: "cave"}}, snapshot.Maps...)
+		err := m.Restore(unhosted)
+		if err == nil || !strings.Contains(err.Error(), "cave") {
+			t.Fatalf("Restore() error = %v, want one naming the unhosted map", err)
+		}
+		// The hosted maps are restored regardless.
+		town, _ := m.World("town")
+		if town.Tick() != 5 {
+			t.Fatalf("town at tick %d, want 5", town.Tick())
+		}
+	})
+
+	t.Run("other version", func(t *testing.T) {
+		m := newManager()
+		other := snapshot
+		other.Version = SnapshotVersion + 1
+		if err := m.Restore(other); err == nil {
+			t.Fatal("Restore() accepted a snapshot of another version")
+		}
+	})
+}
@@ -0,0 +1,165 @@
+package engine
+
+import "fmt"
+
+const (
+	SpawnDefault = "default"
+	SpawnRespawn = "respawn"
+)
+
+// SpawnPoint is a named tile players can be placed on. Region optionally ties
+// the spawn to a named area so systems can pick region-local spawns.
+type SpawnPoint struct {
+	Name   string `json:"name"`
+	X      int    `json:"x"`
+	Y      int    `json:"y"`
+	Region string `json:"region,omitempty"`
+}
+
+func validateSpawns(data MapData, grid tileGrid) error {
+	seen := make(map[string]struct{}, len(data.Spawns))
+	for _, spawn := range data.Spawns {
+		if spawn.Name == "" {
+			return fmt.Errorf("spawn point without a name")
+		}
+		if _, ok := seen[spawn.Name]; ok {
+			return fmt.Errorf("duplicate spawn point %q", spawn.Name)
+		}
+		seen[spawn.Name] = struct{}{}
+
+		if spawn.X < 0 || spawn.Y < 0 || spawn.X >= data.Width || spawn.Y >= data.Height {
+			return fmt.Errorf("spawn point %q is outside the map", spawn.Name)
+		}
+		if !grid.walkable[spawn.Y*data.Width+spawn.X] {
+			return fmt.Errorf("spawn point %q is not walkable", spawn.Name)
+		}
+	}
+
+	return nil
+}
+
+// SpawnPoint returns the named spawn point declared by the map.
+func (w *World) SpawnPoint(name string) (SpawnPoint, bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	spawn, ok := w.spawns[name]
+	return spawn, ok
+}
+
+// SpawnPointsInRegion returns the spawn points tagged with region.
+func (w *World) SpawnPointsInRegion(region string) []SpawnPoint {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	var spawns []SpawnPoint
+	for _, spawn := range w.spawns {
+		if spawn.Region == region {
+			spawns = append(spawns, spawn)
+		}
+	}
+
+	return spawns
+}
+
+// MovePlayerToSpawn places a player on the named spawn point, falling back to
+// the default spawn when the map does not declare it, and cancels any path.
+func (w *World) MovePlayerToSpawn(id, name string) bool {
+	w.mu.Lock()
+	defer w.unlockAndNotify()
+
+	player, ok := w.players[id]
+	if !ok {
+		return false
+	}
+
+	tile := w.spawnTile(name, player.ID)
+	player.X = w.tileCenter(tile.X)
+	player.Y = w.tileCenter(tile.Y)
+	movement := player.Movement
+	movement.TargetX = player.X
+	movement.TargetY = player.Y
+	movement.HasTarget = false
+	movement.blocked = 0
+	w.cancelPath(player)
+	delete(w.interactions, player.ID)
+	w.occupy(player, w.tileIndex(tile.X, tile.Y))
+	w.chunks.move(player, w.chunkOf(player))
+	w.dirty = true
+
+	return true
+}
+
+// spawnTile resolves a spawn name to a free walkable tile for the given
+// entity, searching outward from the spawn when it is blocked or occupied.
+func (w *World) spawnTile(name string, self EntityID) tilePoint {
+	spawn, ok := w.spawns[name]
+	if !ok {
+		spawn, ok = w.spawns[SpawnDefault]
+	}
+	origin := tilePoint{X: w.mapWidth / 2, Y: w.mapHeight / 2}
+	if ok {
+		origin = tilePoint{X: spawn.X, Y: spawn.Y}
+	}
+
+	return w.nearestFreeTile(origin, self)
+}
+
+// nearestFreeTile runs a breadth-first search outward from origin, ignoring
+// walls, and returns the closest walkable tile no other entity stands on. If
+// every walkable tile is taken it returns the closest walkable one.
+func (w *World) nearestFreeTile(origin tilePoint, self EntityID) tilePoint {
+	if origin.X < 0 || origin.Y < 0 || origin.X >= w.mapWidth || origin.Y >= w.mapHeight {
+		origin = tilePoint{X: w.mapWidth / 2, Y: w.mapHeight / 2}
+	}
+
+	visited := make([]bool, w.mapWidth*w.mapHeight)
+	queue := []tilePoint{origin}
+	visited[w.tileIndex(origin.X, origin.Y)] = true
+	fallback := origin
+	hasFallback := false
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		if w.isWalkable(current.X, current.Y) {
+			if !w.tileHasOtherEntity(current, self) {
+				return current
+			}
+			if !hasFallback {
+				fallback = current
+				hasFallback = true
+			}
+		}
+
+		for _, direction := range straightDirections {
+			next := tilePoint{X: current.X + direction.X, Y: current.Y + direction.Y}
+			if next.X < 0 || next.Y < 0 || next.X >= w.mapWidth || next.Y >= w.mapHeight {
+				continue
+			}
+			index := w.tileIndex(next.X, next.Y)
+			if visited[index] {
+				continue
+			}
+			visited[index] = true
+			queue = append(queue, next)
+		}
+	}
+
+	return fallback
+}
+
+func (w *World) tileHasOtherEntity(tile tilePoint, self EntityID) bool {
+	for id, other := range w.chunks.chunks[w.chunks.coordFor(tile.X, tile.Y)] {
+		if id == self {
+			continue
+		}
+		otherX, otherY := w.toTileCoords(other.X, other.Y)
+		if otherX == tile.X && otherY == tile.Y {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,146 @@
+package engine
+
+import "testing"
+
+func TestValidateSpawns(t *testing.T) {
+	rows := []string{
+		"...",
+		".#.",
+		"...",
+	}
+
+	tests := []struct {
+		name    string
+		spawns  []SpawnPoint
+		wantErr bool
+	}{
+		{name: "none", spawns: nil},
+		{name: "valid", spawns: []SpawnPoint{{Name: SpawnDefault, X: 0, Y: 0}, {Name: SpawnRespawn, X: 2, Y: 2}}},
+		{name: "unnamed", spawns: []SpawnPoint{{X: 0, Y: 0}}, wantErr: true},
+		{name: "duplicate", spawns: []SpawnPoint{{Name: "a", X: 0, Y: 0}, {Name: "a", X: 2, Y: 2}}, wantErr: true},
+		{name: "outside the map", spawns: []SpawnPoint{{Name: "a", X: 3, Y: 0}}, wantErr: true},
+		{name: "negative", spawns: []SpawnPoint{{Name: "a", X: 0, Y: -1}}, wantErr: true},
+		{name: "on a wall", spawns: []SpawnPoint{{Name: "a", X: 1, Y: 1}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := testMap(rows, false)
+			data.Spawns = tt.spawns
+			if err := validateMapData(data); (err != nil) != tt.wantErr {
+				t.Fatalf("validateMapData() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSpawnTile(t *testing.T) {
+	rows := []string{
+		".....",
+		".....",
+		".....",
+		".....",
+		".....",
+	}
+
+	tests := []struct {
+		name   string
+		spawns []SpawnPoint
+		others []tilePoint
+		spawn  string
+		want   tilePoint
+	}{
+		{
+			name:   "named spawn",
+			spawns: []SpawnPoint{{Name: SpawnDefault, X: 0, Y: 0}, {Name: "gate", X: 4, Y: 3}},
+			spawn:  "gate",
+			want:   tilePoint{X: 4, Y: 3},
+		},
+		{
+			name:   "unknown spawn falls back to the default",
+			spawns: []SpawnPoint{{Name: SpawnDefault, X: 1, Y: 0}},
+			spawn:  "gate",
+			want:   tilePoint{X: 1, Y: 0},
+		},
+		{
+			name:  "no spawns uses the map center",
+			spawn: SpawnDefault,
+			want:  tilePoint{X: 2, Y: 2},
+		},
+		{
+			name:   "occupied spawn moves to the nearest free tile",
+			spawns: []SpawnPoint{{Name: SpawnDefault, X: 0, Y: 0}},
+			others: []tilePoint{{X: 0, Y: 0}},
+			spawn:  SpawnDefault,
+			want:   tilePoint{X: 1, Y: 0},
+		},
+		{
+			name:   "crowded spawn searches further out",
+			spawns: []SpawnPoint{{Name: SpawnDefault, X: 0, Y: 0}},
+			others: []tilePoint{{X: 0, Y: 0}, {X: 1, Y: 0}, {X: 0, Y: 1}},
+			spawn:  SpawnDefault,
+			want:   tilePoint{X: 2, Y: 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := testMap(rows, false)
+			data.Spawns = tt.spawns
+			w := NewWorld(data)
+			for i, other := range tt.others {
+				w.addPlayerAt(string(rune('a'+i)), w.tileCenter(other.X), w.tileCenter(other.Y))
+			}
+
+			if got := w.spawnTile(tt.spawn, 0); got != tt.want {
+				t.Fatalf("spawnTile(%q) = %v, want %v", tt.spawn, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNearestFreeTileSkipsWalls(t *testing.T) {
+	rows := []string{
+		"#####",
+		"#####",
+		"##..#",
+	}
+	w := NewWorld(testMap(rows, false))
+
+	if got, want := w.nearestFreeTile(tilePoint{X: 0, Y: 0}, 0), (tilePoint{X: 2, Y: 2}); got != want {
+		t.Fatalf("nearestFreeTile = %v, want %v", got, want)
+	}
+}
+
+func TestMovePlayerToSpawn(t *testing.T) {
+	data := testMap([]string{
+		"......",
+		"......",
+	}, false)
+	data.Spawns = []SpawnPoint{
+		{Name: SpawnDefault, X: 0, Y: 0},
+		{Name: SpawnRespawn, X: 5, Y: 1, Region: "town"},
+	}
+	w := NewWorld(data)
+	w.AddPlayer("alice", nil)
+	w.SetPlayerTarget("alice", w.tileCenter(5), w.tileCenter(0))
+	w.Step(0.05)
+
+	if !w.MovePlayerToSpawn("alice", SpawnRespawn) {
+		t.Fatal("MovePlayerToSpawn reported an unknown player")
+	}
+	player := w.SnapshotPlayers()[0]
+	if player.X != w.tileCenter(5) || player.Y != w.tileCenter(1) {
+		t.Fatalf("player at (%d, %d), want the respawn tile", player.X, player.Y)
+	}
+	if player.HasTarget || player.Path != nil {
+		t.Fatal("player kept its path after moving to a spawn")
+	}
+	if w.MovePlayerToSpawn("bob", SpawnRespawn) {
+		t.Fatal("moved an unknown player")
+	}
+
+	if spawns := w.SpawnPointsInRegion("town"); len(spawns) != 1 || spawns[0].Name != SpawnRespawn {
+		t.Fatalf("SpawnPointsInRegion(town) = %+v, want the respawn point", spawns)
+	}
+}
@@ -0,0 +1,104 @@
+package engine
+
+// DefaultMoveSpeed is the walking speed, in unscaled units per second, of
+// entities whose Movement does not set one.
+const DefaultMoveSpeed = 140.0
+
+// Running multiplies speed by RunSpeedMultiplier and drains run energy every
+// tick the entity moves. Energy regenerates on every tick the entity walks or
+// stands still, up to MaxRunEnergy, and running stops when it runs out.
+// Energy is kept in hundredths of a percent so it stays an integer.
+const (
+	RunSpeedMultiplier    = 2.0
+	MaxRunEnergy          = 10000
+	runEnergyDrainPerTick = 60
+	runEnergyRegenPerTick = 15
+)
+
+// SpeedModifier adjusts an entity's speed each tick, for things such as
+// carried weight or status effects. Modifiers run with the world lock held,
+// in the order they were added, after running has been applied.
+type SpeedModifier interface {
+	ModifySpeed(e *Entity, speed float64) float64
+}
+
+// SpeedModifierFunc adapts a function to SpeedModifier.
+type SpeedModifierFunc func(e *Entity, speed float64) float64
+
+func (f SpeedModifierFunc) ModifySpeed(e *Entity, speed float64) float64 {
+	return f(e, speed)
+}
+
+// AddSpeedModifier registers a modifier applied to every moving entity.
+// Modifiers are not recorded, so replays only match recordings made with
+// none that change speed.
+func (w *World) AddSpeedModifier(modifier SpeedModifier) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.speedModifiers = append(w.speedModifiers, modifier)
+}
+
+// setRunning toggles running for a player. It reports false when the player
+// is unknown or has no energy to start running.
+func (w *World) setRunning(id string, running bool) bool {
+	player, ok := w.players[id]
+	if !ok {
+		return false
+	}
+	movement := player.Movement
+	if running && movement.RunEnergy <= 0 {
+		return false
+	}
+
+	if movement.Running != running {
+		movement.Running = running
+		w.dirty = true
+	}
+
+	return true
+}
+
+// speedOf returns how far the entity may move this tick, in scaled units per
+// second.
+func (w *World) speedOf(entity *Entity) float64 {
+	movement := entity.Movement
+	speed := movement.Speed
+	if speed <= 0 {
+		speed = DefaultMoveSpeed
+	}
+	if movement.Running {
+		speed *= RunSpeedMultiplier
+	}
+	for _, modifier := range w.speedModifiers {
+		speed = modifier.ModifySpeed(entity, speed)
+	}
+	if speed < 0 {
+		speed = 0
+	}
+
+	return speed * PositionScale
+}
+
+// updateRunEnergy drains energy from an entity that ran this tick and
+// regenerates it otherwise. Only players are marked dirty, and only when the
+// whole percentage they see changes.
+func (w *World) updateRunEnergy(entity *Entity, moved bool) {
+	movement := entity.Movement
+	before := movement.RunEnergy
+
+	if movement.Running && moved {
+		movement.RunEnergy -= runEnergyDrainPerTick
+		if movement.RunEnergy <= 0 {
+			movement.RunEnergy = 0
+			movement.Running = false
+			w.dirty = true
+		}
+	} else if movement.RunEnergy < MaxRunEnergy {
+		movement.RunEnergy = min(movement.RunEnergy+runEnergyRegenPerTick, MaxRunEnergy)
+	}
+
+	if entity.Kind == EntityPlayer && before/100 != movement.RunEnergy/100 {
+		w.dirty = true
+	}
+}
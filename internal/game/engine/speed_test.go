@@ -0,0 +1,150 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+)
+
+// corridor is a one-row open map length tiles long.
+func corridor(length int) MapData {
+	return testMap([]string{strings.Repeat(".", length)}, false)
+}
+
+// walkedDistance steps a player running or not for ticks Steps down a long
+// corridor and returns how far it got.
+func walkedDistance(run bool, modifier SpeedModifier, ticks int) int {
+	w := NewWorld(corridor(40))
+	if modifier != nil {
+		w.AddSpeedModifier(modifier)
+	}
+	w.AddPlayer("alice", &Position{X: w.tileCenter(0), Y: w.tileCenter(0)})
+	if run {
+		w.EnqueueCommand(Command{PlayerID: "alice", Type: CommandRun, Run: true})
+	}
+	w.EnqueueCommand(moveTo(w, "alice", 39, 0))
+
+	for i := 0; i < ticks; i += 1 {
+		w.Step(0.05)
+	}
+
+	return w.SnapshotPlayers()[0].X - w.tileCenter(0)
+}
+
+func TestSpeed(t *testing.T) {
+	const ticks = 20
+	half := SpeedModifierFunc(func(e *Entity, speed float64) float64 { return speed / 2 })
+	walk := walkedDistance(false, nil, ticks)
+	if walk <= 0 {
+		t.Fatalf("walking player moved %d units", walk)
+	}
+
+	tests := []struct {
+		name     string
+		run      bool
+		modifier SpeedModifier
+		// compare is how the distance compares with walking: -1, 0 or 1.
+		compare int
+	}{
+		{name: "running", run: true, compare: 1},
+		{name: "slowed", modifier: half, compare: -1},
+		{name: "slowed while running", run: true, modifier: half, compare: 0},
+		{name: "sped up", modifier: SpeedModifierFunc(func(e *Entity, speed float64) float64 { return speed * 3 }), compare: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := walkedDistance(tt.run, tt.modifier, ticks)
+			compare := 0
+			if got > walk {
+				compare = 1
+			} else if got < walk {
+				compare = -1
+			}
+			if compare != tt.compare {
+				t.Fatalf("moved %d units against %d walking, compare = %d, want %d", got, walk, compare, tt.compare)
+			}
+		})
+	}
+
+	stopped := SpeedModifierFunc(func(e *Entity, speed float64) float64 { return -speed })
+	if got := walkedDistance(false, stopped, ticks); got != 0 {
+		t.Fatalf("negative speed moved %d units", got)
+	}
+}
+
+func TestRunEnergy(t *testing.T) {
+	w := NewWorld(corridor(100))
+	w.AddPlayer("alice", &Position{X: w.tileCenter(0), Y: w.tileCenter(0)})
+	w.EnqueueCommand(Command{PlayerID: "alice", Type: CommandRun, Run: true})
+	w.EnqueueCommand(moveTo(w, "alice", 99, 0))
+
+	alice := func() Player { return w.SnapshotPlayers()[0] }
+	w.Step(0.05)
+	if got := alice(); !got.Running || got.RunEnergy != MaxRunEnergy-runEnergyDrainPerTick {
+		t.Fatalf("after one running tick: running %v with %d energy", got.Running, got.RunEnergy)
+	}
+
+	ticks := 1
+	for alice().Running && ticks < 1000 {
+		w.Step(0.05)
+		ticks += 1
+	}
+	if want := (MaxRunEnergy + runEnergyDrainPerTick - 1) / runEnergyDrainPerTick; ticks != want {
+		t.Fatalf("ran for %d ticks, want %d", ticks, want)
+	}
+	if got := alice().RunEnergy; got != 0 {
+		t.Fatalf("stopped running with %d energy left", got)
+	}
+	if _, ok := w.EnqueueCommand(Command{PlayerID: "alice", Type: CommandRun, Run: true}); !ok {
+		t.Fatal("could not queue the run command")
+	}
+	w.Step(0.05)
+	if alice().Running {
+		t.Fatal("started running without energy")
+	}
+
+	// Walking and standing both regenerate energy, up to the maximum.
+	if got := alice().RunEnergy; got != runEnergyRegenPerTick {
+		t.Fatalf("energy after a walking tick = %d, want %d", got, runEnergyRegenPerTick)
+	}
+	for i := 0; i < MaxRunEnergy/runEnergyRegenPerTick+10; i += 1 {
+		w.Step(0.05)
+	}
+	if got := alice().RunEnergy; got != MaxRunEnergy {
+		t.Fatalf("energy after resting = %d, want %d", got, MaxRunEnergy)
+	}
+}
+
+func TestRunningStandingStillKeepsEnergy(t *testing.T) {
+	w := NewWorld(corridor(10))
+	w.AddPlayer("alice", &Position{X: w.tileCenter(0), Y: w.tileCenter(0)})
+	w.EnqueueCommand(Command{PlayerID: "alice", Type: CommandRun, Run: true})
+	for i := 0; i < 10; i += 1 {
+		w.Step(0.05)
+	}
+
+	player := w.SnapshotPlayers()[0]
+	if !player.Running || player.RunEnergy != MaxRunEnergy {
+		t.Fatalf("standing runner: running %v with %d energy, want full energy", player.Running, player.RunEnergy)
+	}
+}
+
+func TestNPCSpeed(t *testing.T) {
+	w := NewWorld(corridor(40))
+	def := NPCDefinition{
+		ID:        "runner",
+		Spawn:     NPCSpawnArea{X: 0, Y: 0},
+		Behaviour: NPCBehaviour{Type: NPCPatrol, Route: []MapPoint{{X: 39, Y: 0}}},
+		Speed:     DefaultMoveSpeed * 3,
+	}
+	if err := w.SpawnNPCs([]NPCDefinition{def}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i += 1 {
+		w.Step(0.05)
+	}
+
+	if got, walk := npcEntities(w)[0].X-w.tileCenter(0), walkedDistance(false, nil, 10); got <= walk {
+		t.Fatalf("NPC moved %d units, want more than the %d a walker covers", got, walk)
+	}
+}
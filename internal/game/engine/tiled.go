@@ -0,0 +1,560 @@
+package engine
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// TiledOptions controls how a Tiled map is converted into MapData. A cell is
+// blocked when the collision layer has a tile in it or when any of its tiles
+// sets the collision property to true.
+type TiledOptions struct {
+	CollisionLayer    string
+	CollisionProperty string
+}
+
+func DefaultTiledOptions() TiledOptions {
+	return TiledOptions{
+		CollisionLayer:    "collision",
+		CollisionProperty: "collides",
+	}
+}
+
+// tiledGIDMask strips the flip and rotation flags Tiled stores in the top
+// four bits of a GID, including the hexagonal 120° rotation bit.
+const tiledGIDMask = 0x0FFFFFFF
+
+type tiledMap struct {
+	Width       int             `json:"width"`
+	Height      int             `json:"height"`
+	TileWidth   int             `json:"tilewidth"`
+	TileHeight  int             `json:"tileheight"`
+	Orientation string          `json:"orientation"`
+	Infinite    bool            `json:"infinite"`
+	Layers      []tiledLayer    `json:"layers"`
+	Tilesets    []tiledTileset  `json:"tilesets"`
+	Properties  []tiledProperty `json:"properties"`
+}
+
+type tiledLayer struct {
+	Name        string          `json:"name"`
+	Type        string          `json:"type"`
+	Visible     *bool           `json:"visible"`
+	Width       int             `json:"width"`
+	Height      int             `json:"height"`
+	Data        json.RawMessage `json:"data"`
+	Encoding    string          `json:"encoding"`
+	Compression string          `json:"compression"`
+	Objects     []tiledObject   `json:"objects"`
+	Layers      []tiledLayer    `json:"layers"`
+	Properties  []tiledProperty `json:"properties"`
+}
+
+type tiledObject struct {
+	ID         int             `json:"id"`
+	Name       string          `json:"name"`
+	Type       string          `json:"type"`
+	Class      string          `json:"class"`
+	X          float64         `json:"x"`
+	Y          float64         `json:"y"`
+	Width      float64         `json:"width"`
+	Height     float64         `json:"height"`
+	Point      bool            `json:"point"`
+	Polygon    []tiledPoint    `json:"polygon"`
+	Properties []tiledProperty `json:"properties"`
+}
+
+type tiledPoint struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+type tiledTileset struct {
+	FirstGID   int             `json:"firstgid"`
+	Source     string          `json:"source"`
+	Name       string          `json:"name"`
+	TileCount  int             `json:"tilecount"`
+	Tiles      []tiledTile     `json:"tiles"`
+	Properties []tiledProperty `json:"properties"`
+}
+
+type tiledTile struct {
+	ID         int             `json:"id"`
+	Type       string          `json:"type"`
+	Class      string          `json:"class"`
+	Properties []tiledProperty `json:"properties"`
+}
+
+type tiledProperty struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Value any    `json:"value"`
+}
+
+// LoadTiledMap reads a map exported from Tiled in its JSON format (.tmj).
+// External tilesets are resolved relative to the map file.
+func LoadTiledMap(path string, opts TiledOptions) (MapData, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return MapData{}, err
+	}
+
+	return parseTiledMap(raw, filepath.Dir(path), opts)
+}
+
+// isTiledMap sniffs a JSON document for the fields Tiled always writes.
+func isTiledMap(raw []byte) bool {
+	var probe struct {
+		Layers json.RawMessage `json:"layers"`
+		Tiles  json.RawMessage `json:"tiles"`
+	}
+	if err := json.Unmarshal(raw, &probe); err != nil {
+		return false
+	}
+
+	return len(probe.Layers) > 0 && len(probe.Tiles) == 0
+}
+
+func parseTiledMap(raw []byte, baseDir string, opts TiledOptions) (MapData, error) {
+	var source tiledMap
+	if err := json.Unmarshal(raw, &source); err != nil {
+		return MapData{}, err
+	}
+
+	if source.Infinite {
+		return MapData{}, fmt.Errorf("tiled: infinite maps are not supported")
+	}
+	if source.Orientation != "" && source.Orientation != "orthogonal" {
+		return MapData{}, fmt.Errorf("tiled: unsupported orientation %q", source.Orientation)
+	}
+	if source.Width <= 0 || source.Height <= 0 || source.TileWidth <= 0 || source.TileHeight <= 0 {
+		return MapData{}, fmt.Errorf("tiled: invalid map size")
+	}
+
+	tiles, err := resolveTiledTiles(source.Tilesets, baseDir)
+	if err != nil {
+		return MapData{}, err
+	}
+
+	cells := source.Width * source.Height
+	top := make([]int, cells)
+	blocked := make([]bool, cells)
+	var markers []MapMarker
+
+	var walk func(layers []tiledLayer, parentVisible bool) error
+	walk = func(layers []tiledLayer, parentVisible bool) error {
+		for _, layer := range layers {
+			visible := parentVisible && (layer.Visible == nil || *layer.Visible)
+			isCollision := opts.CollisionLayer != "" && layer.Name == opts.CollisionLayer
+
+			switch layer.Type {
+			case "tilelayer":
+				gids, err := decodeTiledLayer(layer)
+				if err != nil {
+					return fmt.Errorf("tiled: layer %q: %w", layer.Name, err)
+				}
+				if len(gids) != cells {
+					return fmt.Errorf("tiled: layer %q has %d tiles, want %d", layer.Name, len(gids), cells)
+				}
+
+				for i, gid := range gids {
+					gid &= tiledGIDMask
+					if gid == 0 {
+						continue
+					}
+					if isCollision {
+						blocked[i] = true
+					}
+					if visible || isCollision {
+						top[i] = int(gid)
+					}
+				}
+			case "objectgroup":
+				for _, object := range layer.Objects {
+					markers = append(markers, convertTiledMarker(object, layer.Name, source.TileWidth, source.TileHeight))
+				}
+			case "group":
+				if err := walk(layer.Layers, visible); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	}
+	if err := walk(source.Layers, true); err != nil {
+		return MapData{}, err
+	}
+
+	types := []TileType{{ID: 0, Name: "empty", Walkable: false}}
+	registered := map[int]bool{}
+	data := MapData{
+		Width:      source.Width,
+		Height:     source.Height,
+		Tiles:      make([][]int, source.Height),
+		Markers:    markers,
+		Properties: tiledProperties(source.Properties),
+	}
+	if diagonal, ok := data.Properties["diagonal"].(bool); ok {
+		data.Diagonal = diagonal
+	}
+	if collision, ok := data.Properties["collision"].(bool); ok {
+		data.Collision = collision
+	}
+
+	// Blocking is decided per cell. A tile keeps its own id, walkable or not
+	// as its properties say, and a walkable tile drawn in a blocked cell gets
+	// a derived non-walkable id so the registry stays one entry per id.
+	for y := 0; y < source.Height; y += 1 {
+		row := make([]int, source.Width)
+		for x := 0; x < source.Width; x += 1 {
+			index := y*source.Width + x
+			gid := top[index]
+			if gid == 0 {
+				continue
+			}
+
+			tileType := tiledTileType(gid, tiles[gid], false, opts)
+			if blocked[index] && tileType.Walkable {
+				tileType = tiledTileType(gid, tiles[gid], true, opts)
+				tileType.ID = -gid
+			}
+			row[x] = tileType.ID
+			if !registered[tileType.ID] {
+				registered[tileType.ID] = true
+				types = append(types, tileType)
+			}
+		}
+		data.Tiles[y] = row
+	}
+
+	data.TileTypes = types
+	data.Spawns = tiledSpawns(markers)
+	data.Objects = tiledObjects(markers)
+	data.Portals = tiledPortals(markers)
+	data.Regions = tiledRegions(markers)
+
+	if err := validateMapData(data); err != nil {
+		return MapData{}, err
+	}
+
+	return data, nil
+}
+
+type tiledTileInfo struct {
+	name       string
+	properties map[string]any
+}
+
+func resolveTiledTiles(tilesets []tiledTileset, baseDir string) (map[int]tiledTileInfo, error) {
+	tiles := map[int]tiledTileInfo{}
+
+	for _, tileset := range tilesets {
+		if tileset.Source != "" {
+			external, err := loadTiledTileset(filepath.Join(baseDir, tileset.Source))
+			if err != nil {
+				return nil, err
+			}
+			external.FirstGID = tileset.FirstGID
+			tileset = external
+		}
+
+		for local := 0; local < tileset.TileCount; local += 1 {
+			tiles[tileset.FirstGID+local] = tiledTileInfo{
+				name:       fmt.Sprintf("%s:%d", tileset.Name, local),
+				properties: map[string]any{},
+			}
+		}
+
+		for _, tile := range tileset.Tiles {
+			info := tiledTileInfo{
+				name:       fmt.Sprintf("%s:%d", tileset.Name, tile.ID),
+				properties: tiledProperties(tile.Properties),
+			}
+			if tile.Class != "" {
+				info.name = tile.Class
+			} else if tile.Type != "" {
+				info.name = tile.Type
+			}
+			if name, ok := info.properties["name"].(string); ok && name != "" {
+				info.name = name
+			}
+			tiles[tileset.FirstGID+tile.ID] = info
+		}
+	}
+
+	return tiles, nil
+}
+
+func loadTiledTileset(path string) (tiledTileset, error) {
+	if strings.EqualFold(filepath.Ext(path), ".tsx") {
+		return tiledTileset{}, fmt.Errorf("tiled: XML tileset %s is not supported, export it as JSON", path)
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return tiledTileset{}, err
+	}
+
+	var tileset tiledTileset
+	if err := json.Unmarshal(raw, &tileset); err != nil {
+		return tiledTileset{}, fmt.Errorf("tiled: tileset %s: %w", path, err)
+	}
+
+	return tileset, nil
+}
+
+func tiledTileType(gid int, info tiledTileInfo, collision bool, opts TiledOptions) TileType {
+	if info.name == "" {
+		info.name = fmt.Sprintf("gid:%d", gid)
+	}
+
+	walkable := !collision
+	if opts.CollisionProperty != "" {
+		if collides, ok := info.properties[opts.CollisionProperty].(bool); ok && collides {
+			walkable = false
+		}
+	}
+	if value, ok := info.properties["walkable"].(bool); ok && !value {
+		walkable = false
+	}
+
+	tileType := TileType{
+		ID:          gid,
+		Name:        info.name,
+		Walkable:    walkable,
+		BlocksSight: !walkable,
+	}
+	switch value := info.properties["moveCost"].(type) {
+	case float64:
+		tileType.MoveCost = value
+	case int:
+		tileType.MoveCost = float64(value)
+	}
+	if value, ok := info.properties["blocksSight"].(bool); ok {
+		tileType.BlocksSight = value
+	}
+
+	return tileType
+}
+
+func decodeTiledLayer(layer tiledLayer) ([]uint32, error) {
+	if layer.Encoding == "" || layer.Encoding == "csv" {
+		var gids []uint32
+		if err := json.Unmarshal(layer.Data, &gids); err != nil {
+			return nil, err
+		}
+		return gids, nil
+	}
+
+	if layer.Encoding != "base64" {
+		return nil, fmt.Errorf("unsupported encoding %q", layer.Encoding)
+	}
+
+	var encoded string
+	if err := json.Unmarshal(layer.Data, &encoded); err != nil {
+		return nil, err
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, err
+	}
+
+	var reader io.Reader = bytes.NewReader(decoded)
+	switch layer.Compression {
+	case "":
+	case "zlib":
+		zr, err := zlib.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		reader = zr
+	case "gzip":
+		gr, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		defer gr.Close()
+		reader = gr
+	default:
+		return nil, fmt.Errorf("unsupported compression %q", layer.Compression)
+	}
+
+	payload, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload)%4 != 0 {
+		return nil, fmt.Errorf("truncated tile data")
+	}
+
+	gids := make([]uint32, len(payload)/4)
+	for i := range gids {
+		gids[i] = binary.LittleEndian.Uint32(payload[i*4:])
+	}
+
+	return gids, nil
+}
+
+func convertTiledMarker(object tiledObject, layer string, tileWidth, tileHeight int) MapMarker {
+	kind := object.Class
+	if kind == "" {
+		kind = object.Type
+	}
+
+	converted := MapMarker{
+		ID:         object.ID,
+		Name:       object.Name,
+		Type:       kind,
+		Layer:      layer,
+		X:          int(math.Floor(object.X / float64(tileWidth))),
+		Y:          int(math.Floor(object.Y / float64(tileHeight))),
+		Properties: tiledProperties(object.Properties),
+	}
+
+	switch {
+	case object.Point || (object.Width == 0 && object.Height == 0 && len(object.Polygon) == 0):
+		converted.Width = 1
+		converted.Height = 1
+	case len(object.Polygon) > 0:
+		converted.Polygon = make([]MapPoint, 0, len(object.Polygon))
+		for _, point := range object.Polygon {
+			converted.Polygon = append(converted.Polygon, MapPoint{
+				X: int(math.Round((object.X + point.X) / float64(tileWidth))),
+				Y: int(math.Round((object.Y + point.Y) / float64(tileHeight))),
+			})
+		}
+	default:
+		right := int(math.Ceil((object.X + object.Width) / float64(tileWidth)))
+		bottom := int(math.Ceil((object.Y + object.Height) / float64(tileHeight)))
+		converted.Width = max(right-converted.X, 1)
+		converted.Height = max(bottom-converted.Y, 1)
+	}
+
+	return converted
+}
+
+// tiledSpawns turns point markers of type "spawn" into spawn points. The
+// optional "region" property tags region-local spawns.
+func tiledSpawns(markers []MapMarker) []SpawnPoint {
+	var spawns []SpawnPoint
+	for _, marker := range markers {
+		if marker.Type != "spawn" || marker.Name == "" {
+			continue
+		}
+
+		spawn := SpawnPoint{Name: marker.Name, X: marker.X, Y: marker.Y}
+		if region, ok := marker.Properties["region"].(string); ok {
+			spawn.Region = region
+		}
+		spawns = append(spawns, spawn)
+	}
+
+	return spawns
+}
+
+// tiledObjects turns point markers of type "object" into interactable
+// objects. The "objectType" property names the kind of object; "actions"
+// (comma separated) and "respawnTicks" are optional.
+func tiledObjects(markers []MapMarker) []MapObject {
+	var objects []MapObject
+	for _, marker := range markers {
+		if marker.Type != "object" {
+			continue
+		}
+
+		object := MapObject{Name: marker.Name, X: marker.X, Y: marker.Y}
+		if objectType, ok := marker.Properties["objectType"].(string); ok {
+			object.Type = objectType
+		}
+		if actions, ok := marker.Properties["actions"].(string); ok {
+			for _, action := range strings.Split(actions, ",") {
+				if action = strings.TrimSpace(action); action != "" {
+					object.Actions = append(object.Actions, action)
+				}
+			}
+		}
+		if respawn, ok := marker.Properties["respawnTicks"].(int); ok {
+			object.RespawnTicks = respawn
+		}
+		objects = append(objects, object)
+	}
+
+	return objects
+}
+
+// tiledPortals turns point markers of type "portal" into portals using their
+// "map" and optional "spawn" properties.
+func tiledPortals(markers []MapMarker) []Portal {
+	var portals []Portal
+	for _, marker := range markers {
+		if marker.Type != "portal" {
+			continue
+		}
+
+		portal := Portal{Name: marker.Name, X: marker.X, Y: marker.Y}
+		if destination, ok := marker.Properties["map"].(string); ok {
+			portal.Map = destination
+		}
+		if spawn, ok := marker.Properties["spawn"].(string); ok {
+			portal.Spawn = spawn
+		}
+		portals = append(portals, portal)
+	}
+
+	return portals
+}
+
+// tiledRegions turns markers of type "region" into regions, keeping their
+// shape and properties.
+func tiledRegions(markers []MapMarker) []Region {
+	var regions []Region
+	for _, marker := range markers {
+		if marker.Type != "region" {
+			continue
+		}
+
+		regions = append(regions, Region{
+			Name:       marker.Name,
+			X:          marker.X,
+			Y:          marker.Y,
+			Width:      marker.Width,
+			Height:     marker.Height,
+			Polygon:    marker.Polygon,
+			Properties: marker.Properties,
+		})
+	}
+
+	return regions
+}
+
+func tiledProperties(properties []tiledProperty) map[string]any {
+	if len(properties) == 0 {
+		return nil
+	}
+
+	values := make(map[string]any, len(properties))
+	for _, property := range properties {
+		value := property.Value
+		if property.Type == "int" {
+			if number, ok := value.(float64); ok {
+				value = int(number)
+			}
+		}
+		values[property.Name] = value
+	}
+
+	return values
+}
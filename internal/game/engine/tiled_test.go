@@ -0,0 +1,288 @@
+package engine
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// tiledTerrain is a two-tile tileset: grass (gid 1) and mud (gid 2).
+var tiledTerrain = map[string]any{
+	"firstgid":  1,
+	"name":      "terrain",
+	"tilecount": 2,
+	"tiles": []any{
+		map[string]any{"id": 0, "type": "grass"},
+		map[string]any{"id": 1, "type": "mud", "properties": []any{
+			map[string]any{"name": "moveCost", "type": "float", "value": 2},
+		}},
+	},
+}
+
+// tiledJSON encodes a width x height Tiled map with the given layers and
+// the terrain tileset.
+func tiledJSON(t *testing.T, width, height int, layers ...map[string]any) []byte {
+	t.Helper()
+
+	raw, err := json.Marshal(map[string]any{
+		"width":       width,
+		"height":      height,
+		"tilewidth":   16,
+		"tileheight":  16,
+		"orientation": "orthogonal",
+		"layers":      layers,
+		"tilesets":    []any{tiledTerrain},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return raw
+}
+
+func tiledTileLayer(name string, gids ...uint32) map[string]any {
+	return map[string]any{"name": name, "type": "tilelayer", "data": gids}
+}
+
+func TestParseTiledMapCollision(t *testing.T) {
+	// Grass is drawn in all three cells but only the middle one lies under
+	// the collision layer, which paints it with grass too.
+	raw := tiledJSON(t, 3, 1,
+		tiledTileLayer("ground", 1, 1, 2),
+		tiledTileLayer("collision", 0, 1, 0),
+	)
+	data, err := parseTiledMap(raw, "", DefaultTiledOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := [][]int{{1, -1, 2}}; !reflect.DeepEqual(data.Tiles, want) {
+		t.Fatalf("tiles = %v, want %v", data.Tiles, want)
+	}
+	wantTypes := []TileType{
+		{ID: 0, Name: "empty"},
+		{ID: 1, Name: "grass", Walkable: true},
+		{ID: -1, Name: "grass", BlocksSight: true},
+		{ID: 2, Name: "mud", Walkable: true, MoveCost: 2},
+	}
+	if !reflect.DeepEqual(data.TileTypes, wantTypes) {
+		t.Fatalf("tile types = %+v, want %+v", data.TileTypes, wantTypes)
+	}
+
+	w := NewWorld(data)
+	for x, want := range []bool{true, false, true} {
+		if got := w.isWalkable(x, 0); got != want {
+			t.Fatalf("tile %d walkable = %v, want %v", x, got, want)
+		}
+	}
+}
+
+func TestParseTiledMapCollisionProperty(t *testing.T) {
+	raw, err := json.Marshal(map[string]any{
+		"width":      2,
+		"height":     1,
+		"tilewidth":  16,
+		"tileheight": 16,
+		"layers":     []any{tiledTileLayer("ground", 1, 2)},
+		"tilesets": []any{map[string]any{
+			"firstgid":  1,
+			"name":      "walls",
+			"tilecount": 2,
+			"tiles": []any{map[string]any{"id": 1, "properties": []any{
+				map[string]any{"name": "collides", "type": "bool", "value": true},
+			}}},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		property string
+		want     bool
+	}{
+		{name: "default property", property: "collides", want: false},
+		{name: "property disabled", property: "", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultTiledOptions()
+			opts.CollisionProperty = tt.property
+			data, err := parseTiledMap(raw, "", opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w := NewWorld(data)
+			if !w.isWalkable(0, 0) {
+				t.Fatal("plain tile blocked")
+			}
+			if got := w.isWalkable(1, 0); got != tt.want {
+				t.Fatalf("colliding tile walkable = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTiledMapEncodings(t *testing.T) {
+	// The last tile carries every flip flag, which the importer ignores.
+	gids := []uint32{1, 2, 0, 0xE0000001}
+	payload := make([]byte, len(gids)*4)
+	for i, gid := range gids {
+		binary.LittleEndian.PutUint32(payload[i*4:], gid)
+	}
+
+	var zlibbed bytes.Buffer
+	zw := zlib.NewWriter(&zlibbed)
+	zw.Write(payload)
+	zw.Close()
+	var gzipped bytes.Buffer
+	gw := gzip.NewWriter(&gzipped)
+	gw.Write(payload)
+	gw.Close()
+
+	tests := []struct {
+		name  string
+		layer map[string]any
+	}{
+		{name: "csv", layer: map[string]any{"data": gids}},
+		{name: "base64", layer: map[string]any{"encoding": "base64", "data": base64.StdEncoding.EncodeToString(payload)}},
+		{name: "zlib", layer: map[string]any{"encoding": "base64", "compression": "zlib", "data": base64.StdEncoding.EncodeToString(zlibbed.Bytes())}},
+		{name: "gzip", layer: map[string]any{"encoding": "base64", "compression": "gzip", "data": base64.StdEncoding.EncodeToString(gzipped.Bytes())}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.layer["name"] = "ground"
+			tt.layer["type"] = "tilelayer"
+			data, err := parseTiledMap(tiledJSON(t, 2, 2, tt.layer), "", DefaultTiledOptions())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := [][]int{{1, 2}, {0, 1}}; !reflect.DeepEqual(data.Tiles, want) {
+				t.Fatalf("tiles = %v, want %v", data.Tiles, want)
+			}
+		})
+	}
+}
+
+func TestParseTiledMapMarkers(t *testing.T) {
+	objects := map[string]any{
+		"name": "spawns",
+		"type": "objectgroup",
+		"objects": []any{
+			map[string]any{"id": 1, "name": "start", "type": "spawn", "x": 20, "y": 4, "point": true},
+			map[string]any{"id": 2, "name": "camp", "class": "zone", "x": 8, "y": 16, "width": 24, "height": 16},
+			map[string]any{"id": 3, "name": "pond", "class": "zone", "x": 0, "y": 0, "polygon": []any{
+				map[string]any{"x": 0, "y": 0},
+				map[string]any{"x": 48, "y": 0},
+				map[string]any{"x": 0, "y": 48},
+			}},
+		},
+	}
+	raw := tiledJSON(t, 3, 3, tiledTileLayer("ground", 1, 1, 1, 1, 1, 1, 1, 1, 1), objects)
+	data, err := parseTiledMap(raw, "", DefaultTiledOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []MapMarker{
+		{ID: 1, Name: "start", Type: "spawn", Layer: "spawns", X: 1, Y: 0, Width: 1, Height: 1},
+		{ID: 2, Name: "camp", Type: "zone", Layer: "spawns", X: 0, Y: 1, Width: 2, Height: 1},
+		{ID: 3, Name: "pond", Type: "zone", Layer: "spawns", Polygon: []MapPoint{{X: 0, Y: 0}, {X: 3, Y: 0}, {X: 0, Y: 3}}},
+	}
+	if !reflect.DeepEqual(data.Markers, want) {
+		t.Fatalf("markers = %+v, want %+v", data.Markers, want)
+	}
+
+	w := NewWorld(data)
+	if marker, ok := w.Marker("camp"); !ok || marker.ID != 2 {
+		t.Fatalf("Marker(camp) = %+v, %v", marker, ok)
+	}
+	if zones := w.MarkersOfType("zone"); len(zones) != 2 {
+		t.Fatalf("found %d zones, want 2", len(zones))
+	}
+	var names []string
+	for _, marker := range w.MarkersAt(0, 1) {
+		names = append(names, marker.Name)
+	}
+	if want := []string{"camp", "pond"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("markers at (0, 1) = %v, want %v", names, want)
+	}
+}
+
+func TestLoadTiledMapExternalTileset(t *testing.T) {
+	dir := t.TempDir()
+	tileset, err := json.Marshal(tiledTerrain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "terrain.tsj"), tileset, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := json.Marshal(map[string]any{
+		"width":      2,
+		"height":     1,
+		"tilewidth":  16,
+		"tileheight": 16,
+		"layers":     []any{tiledTileLayer("ground", 1, 2)},
+		"tilesets":   []any{map[string]any{"firstgid": 1, "source": "terrain.tsj"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "map.tmj")
+	if err := os.WriteFile(path, raw, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := LoadMapData(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data.TileTypes) != 3 || data.TileTypes[2].Name != "mud" || data.TileTypes[2].MoveCost != 2 {
+		t.Fatalf("tile types = %+v, want the external tileset's", data.TileTypes)
+	}
+}
+
+func TestParseTiledMapRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(source map[string]any)
+	}{
+		{name: "infinite", mutate: func(source map[string]any) { source["infinite"] = true }},
+		{name: "isometric", mutate: func(source map[string]any) { source["orientation"] = "isometric" }},
+		{name: "short layer", mutate: func(source map[string]any) {
+			source["layers"] = []any{tiledTileLayer("ground", 1)}
+		}},
+		{name: "unknown encoding", mutate: func(source map[string]any) {
+			source["layers"] = []any{map[string]any{"name": "ground", "type": "tilelayer", "encoding": "hex", "data": "00"}}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var source map[string]any
+			if err := json.Unmarshal(tiledJSON(t, 2, 1, tiledTileLayer("ground", 1, 1)), &source); err != nil {
+				t.Fatal(err)
+			}
+			tt.mutate(source)
+			raw, err := json.Marshal(source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := parseTiledMap(raw, "", DefaultTiledOptions()); err == nil {
+				t.Fatal("parseTiledMap() succeeded, want error")
+			}
+		})
+	}
+}
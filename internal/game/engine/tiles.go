@@ -0,0 +1,258 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+)
+
+// TileType describes how a tile id behaves in the simulation. MoveCost is a
+// multiplier on the base step cost; zero means the default of 1.
+type TileType struct {
+	ID          int     `json:"id"`
+	Name        string  `json:"name"`
+	Walkable    bool    `json:"walkable"`
+	MoveCost    float64 `json:"moveCost,omitempty"`
+	BlocksSight bool    `json:"blocksSight,omitempty"`
+}
+
+const (
+	TileGrass = 0
+	TileDirt  = 1
+	TileWall  = 2
+)
+
+// tileCostScale converts MoveCost multipliers into integer path weights.
+const tileCostScale = 100
+
+// DefaultTileTypes is the table used by maps that do not declare their own,
+// matching the ids the original maps were authored with.
+func DefaultTileTypes() []TileType {
+	return []TileType{
+		{ID: TileGrass, Name: "grass", Walkable: true, MoveCost: 1},
+		{ID: TileDirt, Name: "dirt", Walkable: true, MoveCost: 1},
+		{ID: TileWall, Name: "wall", Walkable: false, BlocksSight: true},
+	}
+}
+
+func (t TileType) costWeight() int {
+	if t.MoveCost == 0 {
+		return tileCostScale
+	}
+
+	return int(math.Round(t.MoveCost * tileCostScale))
+}
+
+func validateTileTypes(types []TileType) error {
+	seen := make(map[int]struct{}, len(types))
+	for _, tileType := range types {
+		if _, ok := seen[tileType.ID]; ok {
+			return fmt.Errorf("duplicate tile type %d", tileType.ID)
+		}
+		seen[tileType.ID] = struct{}{}
+
+		if tileType.MoveCost < 0 {
+			return fmt.Errorf("invalid move cost for tile type %d", tileType.ID)
+		}
+		if tileType.Walkable && tileType.costWeight() <= 0 {
+			return fmt.Errorf("move cost too small for tile type %d", tileType.ID)
+		}
+	}
+
+	return nil
+}
+
+// tileGrid is the flattened, per-index view of the map the pathfinder reads.
+// Tile ids with no definition are treated as blocked. collision marks the
+// tiles where entities may not share a tile.
+type tileGrid struct {
+	types       map[int]TileType
+	walkable    []bool
+	cost        []int
+	minCost     int
+	blocksSight []bool
+	collision   []bool
+}
+
+func newTileGrid(data MapData) tileGrid {
+	types := data.TileTypes
+	if len(types) == 0 {
+		types = DefaultTileTypes()
+	}
+
+	grid := tileGrid{
+		types:       make(map[int]TileType, len(types)),
+		walkable:    make([]bool, data.Width*data.Height),
+		cost:        make([]int, data.Width*data.Height),
+		blocksSight: make([]bool, data.Width*data.Height),
+		collision:   collisionTiles(data),
+	}
+	for _, tileType := range types {
+		grid.types[tileType.ID] = tileType
+	}
+
+	for y := 0; y < data.Height; y += 1 {
+		for x := 0; x < data.Width; x += 1 {
+			index := y*data.Width + x
+			tileType, ok := grid.types[data.Tiles[y][x]]
+			if !ok {
+				grid.blocksSight[index] = true
+				continue
+			}
+
+			grid.blocksSight[index] = tileType.BlocksSight
+			if !tileType.Walkable {
+				continue
+			}
+
+			weight := tileType.costWeight()
+			grid.walkable[index] = true
+			grid.cost[index] = weight
+			if grid.minCost == 0 || weight < grid.minCost {
+				grid.minCost = weight
+			}
+		}
+	}
+
+	if grid.minCost == 0 {
+		grid.minCost = tileCostScale
+	}
+
+	return grid
+}
+
+// TileUpdate is a tile SetTile changed, for clients to patch their copy of
+// the map with.
+type TileUpdate struct {
+	X    int
+	Y    int
+	Tile int
+}
+
+// SetTile changes the tile at (x, y) to the tile type tileID while the world
+// runs, for doors and similar changes. Entities on a tile that stops being
+// walkable move to the nearest free one, paths through it are re-planned, and
+// DrainTileUpdates reports the change.
+func (w *World) SetTile(x, y, tileID int) error {
+	w.mu.Lock()
+	defer w.unlockAndNotify()
+
+	if !w.inBounds(x, y) {
+		return fmt.Errorf("tile %d,%d is outside the map", x, y)
+	}
+	tileType, ok := w.tiles.types[tileID]
+	if !ok {
+		return fmt.Errorf("unknown tile type %d", tileID)
+	}
+	if !tileType.Walkable && w.holdsSpawnOrPortal(x, y) {
+		return fmt.Errorf("tile %d,%d holds a spawn point or portal and must stay walkable", x, y)
+	}
+	if w.mapData[y][x] == tileID {
+		return nil
+	}
+
+	if w.recorder != nil {
+		w.recorder.recordEvent(RecordedEvent{Type: RecordedTile, X: x, Y: y, Tile: tileID})
+	}
+	from := w.mapData[y][x]
+	w.setTile(x, y, tileType)
+	w.emit(TileChanged{EventMeta: w.meta(), X: x, Y: y, From: from, To: tileID})
+
+	return nil
+}
+
+func (w *World) setTile(x, y int, tileType TileType) {
+	// MapData hands the tiles out, so the changed row is copied rather than
+	// written in place.
+	tiles := append([][]int(nil), w.source.Tiles...)
+	tiles[y] = append([]int(nil), tiles[y]...)
+	tiles[y][x] = tileType.ID
+	w.source.Tiles = tiles
+	w.mapData = tiles
+
+	index := w.tileIndex(x, y)
+	w.tiles.walkable[index] = tileType.Walkable
+	w.tiles.blocksSight[index] = tileType.BlocksSight
+	w.tiles.cost[index] = 0
+	if tileType.Walkable {
+		weight := tileType.costWeight()
+		w.tiles.cost[index] = weight
+		w.tiles.minCost = min(w.tiles.minCost, weight)
+	}
+	w.resetSightCache()
+	w.hierarchy.invalidate(w, tilePoint{X: x, Y: y})
+
+	if !tileType.Walkable {
+		w.routeAround(tilePoint{X: x, Y: y})
+	}
+
+	w.tileUpdates = append(w.tileUpdates, TileUpdate{X: x, Y: y, Tile: tileType.ID})
+	w.dirty = true
+}
+
+// DrainTileUpdates returns the tiles changed by SetTile since the last call.
+// A map reload drops them, since clients fetch the whole map again.
+func (w *World) DrainTileUpdates() []TileUpdate {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	updates := w.tileUpdates
+	w.tileUpdates = nil
+
+	return updates
+}
+
+// routeAround moves players and NPCs off a tile that became unwalkable and
+// re-plans the paths still to cross it, cancelling the ones that no longer
+// reach their goal. Objects stay where the map put them.
+func (w *World) routeAround(tile tilePoint) {
+	for _, entity := range w.order {
+		if _, ok := w.objects[entity.ID]; ok {
+			continue
+		}
+		tileX, tileY := w.toTileCoords(entity.X, entity.Y)
+		if tileX == tile.X && tileY == tile.Y {
+			free := w.nearestFreeTile(tile, entity.ID)
+			entity.X = w.tileCenter(free.X)
+			entity.Y = w.tileCenter(free.Y)
+			if movement := entity.Movement; movement != nil {
+				movement.TargetX = entity.X
+				movement.TargetY = entity.Y
+				movement.HasTarget = false
+				movement.blocked = 0
+				w.cancelPath(entity)
+			}
+			w.occupy(entity, w.tileIndex(free.X, free.Y))
+			w.chunks.move(entity, w.chunkOf(entity))
+			continue
+		}
+
+		movement := entity.Movement
+		if movement == nil || movement.Path == nil || !pathCrosses(movement.Path[movement.PathIndex:], tile) {
+			continue
+		}
+		if !w.setEntityPath(entity, movement.Path[len(movement.Path)-1]) {
+			w.cancelPath(entity)
+		}
+	}
+}
+
+func (w *World) holdsSpawnOrPortal(x, y int) bool {
+	for _, spawn := range w.spawns {
+		if spawn.X == x && spawn.Y == y {
+			return true
+		}
+	}
+	_, ok := w.portals[w.tileIndex(x, y)]
+
+	return ok
+}
+
+func pathCrosses(path []tilePoint, tile tilePoint) bool {
+	for _, step := range path {
+		if step == tile {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,235 @@
+package engine
+
+import (
+	"math/rand"
+	"testing"
+)
+
+const tileMud = 3
+
+// mudMap builds a map from rows of '.' (grass), '#' (wall) and '~' (mud
+// costing mudCost).
+func mudMap(rows []string, mudCost float64, diagonal bool) MapData {
+	data := testMap(rows, diagonal)
+	for y, row := range rows {
+		for x, cell := range row {
+			if cell == '~' {
+				data.Tiles[y][x] = tileMud
+			}
+		}
+	}
+	data.TileTypes = append(DefaultTileTypes(), TileType{ID: tileMud, Name: "mud", Walkable: true, MoveCost: mudCost})
+
+	return data
+}
+
+// pathCost is what the pathfinder pays for path: every step weighted by the
+// tile it enters.
+func pathCost(w *World, path []tilePoint) int {
+	cost := 0
+	for i := 1; i < len(path); i += 1 {
+		cost += stepCost(path[i-1], path[i]) * w.tiles.cost[w.tileIndex(path[i].X, path[i].Y)]
+	}
+
+	return cost
+}
+
+// weightedReferenceCost is referenceCost with steps weighted like pathCost.
+func weightedReferenceCost(w *World, start, goal tilePoint) int {
+	costs := make([]int, w.mapWidth*w.mapHeight)
+	for i := range costs {
+		costs[i] = -1
+	}
+	directions := straightDirections
+	if w.diagonal {
+		directions = allDirections
+	}
+
+	startIndex := w.tileIndex(start.X, start.Y)
+	costs[startIndex] = 0
+	open := pathHeap{{index: startIndex}}
+	for len(open) > 0 {
+		current := open.pop()
+		if current.f > costs[current.index] {
+			continue
+		}
+		point := w.tileAt(current.index)
+		for _, direction := range directions {
+			next := tilePoint{X: point.X + direction.X, Y: point.Y + direction.Y}
+			if !w.canStep(point, next) {
+				continue
+			}
+			index := w.tileIndex(next.X, next.Y)
+			cost := current.f + stepCost(point, next)*w.tiles.cost[index]
+			if costs[index] >= 0 && cost >= costs[index] {
+				continue
+			}
+			costs[index] = cost
+			open.push(pathNode{index: index, f: cost})
+		}
+	}
+
+	return costs[w.tileIndex(goal.X, goal.Y)]
+}
+
+func TestValidateTileTypes(t *testing.T) {
+	tests := []struct {
+		name    string
+		types   []TileType
+		wantErr bool
+	}{
+		{name: "default table", types: nil},
+		{name: "custom table", types: []TileType{{ID: 0, Name: "grass", Walkable: true}, {ID: 2, Name: "wall"}, {ID: 5, Name: "road", Walkable: true, MoveCost: 0.5}}},
+		{name: "duplicate id", types: []TileType{{ID: 0, Walkable: true}, {ID: 0}}, wantErr: true},
+		{name: "negative cost", types: []TileType{{ID: 0, Walkable: true, MoveCost: -1}}, wantErr: true},
+		{name: "cost rounds to zero", types: []TileType{{ID: 0, Walkable: true, MoveCost: 0.001}}, wantErr: true},
+		{name: "tiny cost on a wall", types: []TileType{{ID: 0, Walkable: true}, {ID: 2, MoveCost: 0.001}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := testMap([]string{"..#"}, false)
+			data.TileTypes = tt.types
+			if err := validateMapData(data); (err != nil) != tt.wantErr {
+				t.Fatalf("validateMapData() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTileGrid(t *testing.T) {
+	data := mudMap([]string{".~#"}, 2.5, false)
+	// Tile ids without a type are blocked and opaque.
+	data.Tiles[0] = append(data.Tiles[0], 9)
+	data.Width += 1
+	w := NewWorld(data)
+
+	tests := []struct {
+		x           int
+		walkable    bool
+		cost        int
+		blocksSight bool
+	}{
+		{x: 0, walkable: true, cost: tileCostScale},
+		{x: 1, walkable: true, cost: 250},
+		{x: 2, walkable: false, blocksSight: true},
+		{x: 3, walkable: false, blocksSight: true},
+	}
+	for _, tt := range tests {
+		index := w.tileIndex(tt.x, 0)
+		if got := w.isWalkable(tt.x, 0); got != tt.walkable {
+			t.Fatalf("tile %d walkable = %v, want %v", tt.x, got, tt.walkable)
+		}
+		if got := w.tiles.cost[index]; got != tt.cost {
+			t.Fatalf("tile %d cost = %d, want %d", tt.x, got, tt.cost)
+		}
+		if got := w.tiles.blocksSight[index]; got != tt.blocksSight {
+			t.Fatalf("tile %d blocks sight = %v, want %v", tt.x, got, tt.blocksSight)
+		}
+	}
+	if w.tiles.minCost != tileCostScale {
+		t.Fatalf("min cost = %d, want %d", w.tiles.minCost, tileCostScale)
+	}
+
+	// Maps without a table get the default one.
+	plain := NewWorld(testMap([]string{".#"}, false))
+	if !plain.isWalkable(0, 0) || plain.isWalkable(1, 0) {
+		t.Fatal("default tile table not applied")
+	}
+}
+
+func TestFindPathWeighsTileCosts(t *testing.T) {
+	rows := []string{
+		".....",
+		".~~~.",
+		".....",
+	}
+	start := tilePoint{X: 0, Y: 1}
+	goal := tilePoint{X: 4, Y: 1}
+
+	tests := []struct {
+		name        string
+		mudCost     float64
+		wantThrough bool
+		wantCost    int
+	}{
+		{name: "around costly mud", mudCost: 4, wantCost: 6 * straightStepCost * tileCostScale},
+		{name: "through cheap mud", mudCost: 1.2, wantThrough: true, wantCost: 3*straightStepCost*120 + straightStepCost*tileCostScale},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewWorld(mudMap(rows, tt.mudCost, false))
+			path := w.findPath(start, goal, nil)
+			if path == nil {
+				t.Fatal("found no path")
+			}
+			checkPath(t, w, path, start, goal)
+
+			through := false
+			for _, tile := range path {
+				through = through || tile.Y == 1 && tile.X > 0 && tile.X < 4
+			}
+			if through != tt.wantThrough {
+				t.Fatalf("path %v crosses the mud = %v, want %v", path, through, tt.wantThrough)
+			}
+			if cost := pathCost(w, path); cost != tt.wantCost {
+				t.Fatalf("path %v costs %d, want %d", path, cost, tt.wantCost)
+			}
+		})
+	}
+}
+
+func TestFindPathOptimalWithTileCosts(t *testing.T) {
+	tests := []struct {
+		name     string
+		mudCost  float64
+		diagonal bool
+	}{
+		{name: "costly mud", mudCost: 3},
+		{name: "cheap mud", mudCost: 0.5},
+		{name: "costly mud, diagonal", mudCost: 3, diagonal: true},
+		{name: "cheap mud, diagonal", mudCost: 0.5, diagonal: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Scatter mud over a third of the open tiles of a noisy map.
+			data := noiseMap(40, 0.2, 3, tt.diagonal)
+			rng := rand.New(rand.NewSource(4))
+			for y, row := range data.Tiles {
+				for x, tile := range row {
+					if tile == TileGrass && rng.Intn(3) == 0 {
+						data.Tiles[y][x] = tileMud
+					}
+				}
+			}
+			data.TileTypes = append(DefaultTileTypes(), TileType{ID: tileMud, Name: "mud", Walkable: true, MoveCost: tt.mudCost})
+			w := NewWorld(data)
+
+			for i := 0; i < 50; i += 1 {
+				start := tilePoint{X: rng.Intn(w.mapWidth), Y: rng.Intn(w.mapHeight)}
+				goal := tilePoint{X: rng.Intn(w.mapWidth), Y: rng.Intn(w.mapHeight)}
+				if !w.isWalkable(start.X, start.Y) || !w.isWalkable(goal.X, goal.Y) {
+					continue
+				}
+
+				want := weightedReferenceCost(w, start, goal)
+				path := w.findPath(start, goal, nil)
+				if want < 0 {
+					if path != nil {
+						t.Fatalf("found path %v -> %v, want none", start, goal)
+					}
+					continue
+				}
+				if path == nil {
+					t.Fatalf("found no path %v -> %v", start, goal)
+				}
+				checkPath(t, w, path, start, goal)
+				if cost := pathCost(w, path); cost != want {
+					t.Fatalf("path %v -> %v costs %d, want %d", start, goal, cost, want)
+				}
+			}
+		})
+	}
+}
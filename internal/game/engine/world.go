@@ -1,13 +1,15 @@
 package engine
 
 import (
-	"fmt"
-	"math"
+	"math/rand"
 	"sync"
 )
 
+// Player is the view of a player entity exposed to the network and
+// persistence layers.
 type Player struct {
 	ID        string
+	EntityID  EntityID
 	X         int
 	Y         int
 	TargetX   int
@@ -15,93 +17,315 @@ type Player struct {
 	HasTarget bool
 	Path      []tilePoint
 	PathIndex int
+	Running   bool
+	RunEnergy int
+}
+
+func playerView(entity *Entity) Player {
+	player := Player{
+		ID:       entity.PlayerID,
+		EntityID: entity.ID,
+		X:        entity.X,
+		Y:        entity.Y,
+		TargetX:  entity.X,
+		TargetY:  entity.Y,
+	}
+	if movement := entity.Movement; movement != nil {
+		player.TargetX = movement.TargetX
+		player.TargetY = movement.TargetY
+		player.HasTarget = movement.HasTarget
+		player.Path = movement.Path
+		player.PathIndex = movement.PathIndex
+		player.Running = movement.Running
+		player.RunEnergy = movement.RunEnergy
+	}
+
+	return player
+}
+
+// Position is a point in scaled world units.
+type Position struct {
+	X int
+	Y int
 }
 
 type World struct {
-	mu        sync.RWMutex
-	players   map[string]*Player
-	mapData   [][]int
-	mapWidth  int
-	mapHeight int
-	dirty     bool
+	mu                sync.RWMutex
+	entities          map[EntityID]*Entity
+	order             []*Entity
+	players           map[string]*Entity
+	nextEntityID      EntityID
+	source            MapData
+	mapData           [][]int
+	tiles             tileGrid
+	markers           []MapMarker
+	spawns            map[string]SpawnPoint
+	mapWidth          int
+	mapHeight         int
+	dirty             bool
+	diagonal          bool
+	maxPathNodes      int
+	search            pathSearch
+	hierarchy         pathHierarchy
+	hierarchyMinTiles int
+	chunks            chunkIndex
+	commands          commandQueue
+	applied           []Command
+	recorder          *Recorder
+	seed              int64
+	rng               *rand.Rand
+	npcs              map[EntityID]*NPCDefinition
+	npcDefs           []NPCDefinition
+	respawns          []npcRespawn
+	objects           map[EntityID]*worldObject
+	objectUpdates     []ObjectUpdate
+	interactions      map[EntityID]interaction
+	occupants         []int32
+	portals           map[int32]Portal
+	transits          []PortalTransit
+	speedModifiers    []SpeedModifier
+	tick              int64
+	pathUpdates       []PlannedPath
+	pathUpdateIndex   map[EntityID]int
+	sight             sightCache
+	regions           []Region
+	regionTiles       map[int32][]int
+	regionsOf         map[EntityID][]string
+	regionEvents      []RegionEvent
+	name              string
+	bus               *EventBus
+	events            []Event
+	eventHolds        int
+	pendingMap        *MapData
+	mapReloaded       bool
+	tileUpdates       []TileUpdate
+	restored          *MapSnapshot
 }
 
 func NewWorld(mapData MapData) *World {
-	return &World{
-		players:   make(map[string]*Player),
-		mapData:   mapData.Tiles,
-		mapWidth:  mapData.Width,
-		mapHeight: mapData.Height,
+	w := &World{
+		entities:          make(map[EntityID]*Entity),
+		players:           make(map[string]*Entity),
+		maxPathNodes:      DefaultMaxPathNodes,
+		hierarchyMinTiles: DefaultHierarchicalMinTiles,
+		chunks:            newChunkIndex(DefaultChunkSizeTiles),
+		seed:              1,
+		rng:               rand.New(rand.NewSource(1)),
+		npcs:              make(map[EntityID]*NPCDefinition),
+		objects:           make(map[EntityID]*worldObject),
+		interactions:      make(map[EntityID]interaction),
+		pathUpdateIndex:   make(map[EntityID]int),
+		regionsOf:         make(map[EntityID][]string),
+		bus:               NewEventBus(),
 	}
+	w.loadMap(mapData)
+	w.placeObjects(mapData.Objects)
+
+	return w
 }
 
-func (w *World) AddPlayer(id string) {
+// loadMap installs the static map state. Entities are left alone; callers
+// replacing a map must re-register them with the occupancy grid.
+func (w *World) loadMap(mapData MapData) {
+	previous := w.tiles
+	// Entrance and in-cluster costs depend on the size and movement rules,
+	// so only reloads keeping both can patch the hierarchy tile by tile.
+	sameRules := mapData.Width == w.mapWidth && mapData.Height == w.mapHeight && mapData.Diagonal == w.diagonal
+	w.source = mapData
+	w.mapData = mapData.Tiles
+	w.tiles = newTileGrid(mapData)
+	w.markers = mapData.Markers
+	w.mapWidth = mapData.Width
+	w.mapHeight = mapData.Height
+	w.diagonal = mapData.Diagonal
+	w.occupants = make([]int32, mapData.Width*mapData.Height)
+	w.resetSightCache()
+	if sameRules {
+		w.invalidateChangedTiles(previous)
+	} else {
+		w.hierarchy.reset()
+	}
+	w.regions = mapData.Regions
+	w.regionTiles = regionTiles(mapData)
+
+	w.spawns = make(map[string]SpawnPoint, len(mapData.Spawns))
+	for _, spawn := range mapData.Spawns {
+		w.spawns[spawn.Name] = spawn
+	}
+	w.portals = make(map[int32]Portal, len(mapData.Portals))
+	for _, portal := range mapData.Portals {
+		w.portals[w.tileIndex(portal.X, portal.Y)] = portal
+	}
+}
+
+// MapData returns the map the world currently runs on.
+func (w *World) MapData() MapData {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	return w.source
+}
+
+// SetSeed reseeds the random source behind NPC spawning and behaviours. Call
+// it before SpawnNPCs so a recording can reproduce the same choices.
+func (w *World) SetSeed(seed int64) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
-	spawnX := w.tileCenter(w.mapWidth / 2)
-	spawnY := w.tileCenter(w.mapHeight / 2)
-
-	w.players[id] = &Player{
-		ID:        id,
-		X:         spawnX,
-		Y:         spawnY,
-		TargetX:   spawnX,
-		TargetY:   spawnY,
-		HasTarget: false,
-		Path:      nil,
-		PathIndex: 0,
+	w.seed = seed
+	w.rng = rand.New(rand.NewSource(seed))
+}
+
+// SetMaxPathNodes caps how many tiles a single path search may expand.
+// A limit <= 0 disables the cap.
+func (w *World) SetMaxPathNodes(limit int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.maxPathNodes = limit
+}
+
+// AddPlayer spawns a player at last, typically a saved position, when it is a
+// walkable point on this map and at the default spawn point otherwise. If
+// another entity stands there, the player spawns on the nearest free tile.
+func (w *World) AddPlayer(id string, last *Position) {
+	w.mu.Lock()
+	defer w.unlockAndNotify()
+
+	var self EntityID
+	if existing, ok := w.players[id]; ok {
+		self = existing.ID
+	}
+
+	if last != nil && last.X >= 0 && last.Y >= 0 {
+		tileX, tileY := w.toTileCoords(last.X, last.Y)
+		if w.isWalkable(tileX, tileY) {
+			tile := w.nearestFreeTile(tilePoint{X: tileX, Y: tileY}, self)
+			if tile.X == tileX && tile.Y == tileY {
+				w.addPlayerLocked(id, last.X, last.Y)
+			} else {
+				w.addPlayerLocked(id, w.tileCenter(tile.X), w.tileCenter(tile.Y))
+			}
+			return
+		}
 	}
 
-	w.dirty = true
+	tile := w.spawnTile(SpawnDefault, self)
+	w.addPlayerLocked(id, w.tileCenter(tile.X), w.tileCenter(tile.Y))
 }
 
-func (w *World) RemovePlayer(id string) {
+func (w *World) addPlayerAt(id string, spawnX, spawnY int) {
 	w.mu.Lock()
-	defer w.mu.Unlock()
+	defer w.unlockAndNotify()
 
-	delete(w.players, id)
+	w.addPlayerLocked(id, spawnX, spawnY)
+}
+
+func (w *World) addPlayerLocked(id string, spawnX, spawnY int) {
+	if existing, ok := w.players[id]; ok {
+		w.removeEntityLocked(existing)
+	}
 
-	w.dirty = true
+	w.addEntityLocked(&Entity{
+		Kind:     EntityPlayer,
+		PlayerID: id,
+		X:        spawnX,
+		Y:        spawnY,
+		Movement: &Movement{TargetX: spawnX, TargetY: spawnY, RunEnergy: MaxRunEnergy},
+	})
+	player := w.players[id]
+	w.emit(PlayerJoined{EventMeta: w.meta(), PlayerID: id, Entity: player.ID, Position: Position{X: spawnX, Y: spawnY}})
+	if w.recorder != nil {
+		w.recorder.recordEvent(RecordedEvent{Type: RecordedJoin, PlayerID: id, X: spawnX, Y: spawnY})
+	}
 }
 
-func (w *World) SetPlayerTarget(id string, x, y int) bool {
+// RemovePlayer deletes a player and returns its final state so callers can
+// persist it.
+func (w *World) RemovePlayer(id string) (Player, bool) {
 	w.mu.Lock()
-	defer w.mu.Unlock()
+	defer w.unlockAndNotify()
 
 	player, ok := w.players[id]
 	if !ok {
-		return false
+		return Player{}, false
 	}
 
-	targetTileX, targetTileY := w.toTileCoords(x, y)
-	if !w.isWalkable(targetTileX, targetTileY) {
-		return false
+	w.leaveRegions(player)
+	w.removeEntityLocked(player)
+	if w.recorder != nil {
+		w.recorder.recordEvent(RecordedEvent{Type: RecordedLeave, PlayerID: id})
 	}
 
-	startTileX, startTileY := w.toTileCoords(player.X, player.Y)
-	path := w.findPath(tilePoint{X: startTileX, Y: startTileY}, tilePoint{X: targetTileX, Y: targetTileY})
-	if len(path) == 0 {
+	return playerView(player), true
+}
+
+// SetPlayerTarget paths a player immediately, outside the tick, and returns
+// the path it planned. Network input should go through EnqueueCommand so it
+// is applied in order by Step.
+func (w *World) SetPlayerTarget(id string, x, y int) (PlannedPath, bool) {
+	w.mu.Lock()
+	defer w.unlockAndNotify()
+
+	if !w.setPlayerTarget(id, x, y) {
+		return PlannedPath{}, false
+	}
+
+	return w.plannedPath(w.players[id]), true
+}
+
+func (w *World) setPlayerTarget(id string, x, y int) bool {
+	player, ok := w.players[id]
+	if !ok {
 		return false
 	}
+	delete(w.interactions, player.ID)
 
-	player.Path = path
-	player.PathIndex = 1
-	if len(path) <= 1 {
-		player.TargetX = player.X
-		player.TargetY = player.Y
-		player.HasTarget = false
-		player.Path = nil
-		player.PathIndex = 0
-		return true
+	return w.setEntityTarget(player, x, y)
+}
+
+// Marker returns the first map marker with the given name.
+func (w *World) Marker(name string) (MapMarker, bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	for _, marker := range w.markers {
+		if marker.Name == name {
+			return marker, true
+		}
 	}
 
-	next := path[player.PathIndex]
-	player.TargetX = w.tileCenter(next.X)
-	player.TargetY = w.tileCenter(next.Y)
-	player.HasTarget = true
+	return MapMarker{}, false
+}
 
-	return true
+// MarkersOfType returns every map marker whose type or class matches.
+func (w *World) MarkersOfType(markerType string) []MapMarker {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	var markers []MapMarker
+	for _, marker := range w.markers {
+		if marker.Type == markerType {
+			markers = append(markers, marker)
+		}
+	}
+
+	return markers
+}
+
+// MarkersAt returns every map marker covering the given tile.
+func (w *World) MarkersAt(tileX, tileY int) []MapMarker {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	var markers []MapMarker
+	for _, marker := range w.markers {
+		if marker.Contains(tileX, tileY) {
+			markers = append(markers, marker)
+		}
+	}
+
+	return markers
 }
 
 func (w *World) SnapshotPlayers() []Player {
@@ -109,70 +333,84 @@ func (w *World) SnapshotPlayers() []Player {
 	defer w.mu.RUnlock()
 
 	players := make([]Player, 0, len(w.players))
-	for _, player := range w.players {
-		players = append(players, *player)
+	for _, entity := range w.order {
+		if entity.Kind == EntityPlayer {
+			players = append(players, playerView(entity))
+		}
 	}
 
 	return players
 }
 
+// Step advances the simulation by one tick. A pending map reload is swapped
+// in first, then queued commands are applied and the NPCs and objects that
+// are due respawn. Behaviours run next, then every movable entity advances,
+// in entity id order. Interactions resolve once their player has arrived,
+// and region changes are checked against where players ended up. Finally,
+// the events raised during the tick are published, in the order they
+// happened, after the world lock is released and before Step returns.
 func (w *World) Step(deltaSeconds float64) {
 	w.mu.Lock()
-	defer w.mu.Unlock()
+	defer w.unlockAndNotify()
 
+	// A recording started from here on can no longer begin at the
+	// restored snapshot.
+	w.restored = nil
+	if w.pendingMap != nil {
+		w.applyMapReload()
+	}
+	w.applyCommands()
+	w.respawnNPCs(deltaSeconds)
+	w.respawnObjects()
+	w.stepEntities(deltaSeconds)
+	w.resolveInteractions()
+	w.updateRegions()
+	w.tick += 1
+
+	if w.recorder != nil {
+		w.recorder.recordFrame(deltaSeconds, w.applied, w.checksum())
+	}
+}
+
+func (w *World) stepEntities(deltaSeconds float64) {
 	if deltaSeconds <= 0 {
 		return
 	}
 
-	const speed = 140.0
-	const speedScaled = speed * PositionScale
-
-	for _, player := range w.players {
-		if player.PathIndex >= len(player.Path) {
-			player.Path = nil
-			player.PathIndex = 0
-			player.HasTarget = false
-			continue
-		}
-
-		if !player.HasTarget {
-			next := player.Path[player.PathIndex]
-			player.TargetX = w.tileCenter(next.X)
-			player.TargetY = w.tileCenter(next.Y)
-			player.HasTarget = true
+	// Behaviours may add or remove entities, so iterate over a stable copy.
+	entities := append([]*Entity(nil), w.order...)
+	for _, entity := range entities {
+		if entity.Behaviour != nil && w.entities[entity.ID] == entity {
+			entity.Behaviour.Update(w, entity, deltaSeconds)
 		}
+	}
 
-		dx := float64(player.TargetX - player.X)
-		dy := float64(player.TargetY - player.Y)
-		distance := math.Hypot(dx, dy)
-		if distance == 0 {
-			player.PathIndex += 1
-			player.HasTarget = false
-			if player.PathIndex >= len(player.Path) {
-				player.Path = nil
-				player.PathIndex = 0
-			}
+	for _, entity := range w.order {
+		if entity.Movement == nil {
 			continue
 		}
-
-		step := speedScaled * deltaSeconds
-		if distance <= step {
-			player.X = player.TargetX
-			player.Y = player.TargetY
-			player.PathIndex += 1
-			player.HasTarget = false
-			if player.PathIndex >= len(player.Path) {
-				player.Path = nil
-				player.PathIndex = 0
-			}
-			w.dirty = true
-			continue
+		moved := isMoving(entity)
+		speed := w.speedOf(entity)
+		if moved && speed != entity.Movement.announced {
+			// Running or a speed modifier changed how fast the entity goes,
+			// so clients need its path again to keep predicting it.
+			w.notePath(entity)
+		}
+		fromX, fromY := w.toTileCoords(entity.X, entity.Y)
+		w.stepEntity(entity, speed*deltaSeconds)
+		w.updateRunEnergy(entity, moved)
+
+		toX, toY := w.toTileCoords(entity.X, entity.Y)
+		if toX != fromX || toY != fromY {
+			w.emit(EntityMoved{
+				EventMeta: w.meta(),
+				Entity:    entity.ID,
+				Kind:      entity.Kind,
+				PlayerID:  entity.PlayerID,
+				From:      MapPoint{X: fromX, Y: fromY},
+				To:        MapPoint{X: toX, Y: toY},
+			})
 		}
-
-		ratio := step / distance
-		player.X += int(math.Round(dx * ratio))
-		player.Y += int(math.Round(dy * ratio))
-		w.dirty = true
 	}
 }
 
@@ -188,35 +426,20 @@ func (w *World) DrainDirty() bool {
 	return true
 }
 
+// SnapshotPlayersInChunkRadius returns the players within chunkRadius chunks
+// of the given player.
 func (w *World) SnapshotPlayersInChunkRadius(id string, chunkRadius int, chunkSizeTiles int) ([]Player, bool) {
-	if chunkSizeTiles <= 0 {
-		chunkSizeTiles = 1
-	}
-	if chunkRadius < 0 {
-		chunkRadius = 0
-	}
-
-	w.mu.RLock()
-	player, ok := w.players[id]
+	entities, ok := w.SnapshotEntitiesInChunkRadius(id, chunkRadius, chunkSizeTiles)
 	if !ok {
-		w.mu.RUnlock()
 		return nil, false
 	}
 
-	centerTileX, centerTileY := w.toTileCoords(player.X, player.Y)
-	centerChunkX := centerTileX / chunkSizeTiles
-	centerChunkY := centerTileY / chunkSizeTiles
-
-	players := make([]Player, 0, len(w.players))
-	for _, other := range w.players {
-		tileX, tileY := w.toTileCoords(other.X, other.Y)
-		chunkX := tileX / chunkSizeTiles
-		chunkY := tileY / chunkSizeTiles
-		if absInt(chunkX-centerChunkX) <= chunkRadius && absInt(chunkY-centerChunkY) <= chunkRadius {
-			players = append(players, *other)
+	players := make([]Player, 0, len(entities))
+	for i := range entities {
+		if entities[i].Kind == EntityPlayer {
+			players = append(players, playerView(&entities[i]))
 		}
 	}
-	w.mu.RUnlock()
 
 	return players, true
 }
@@ -238,12 +461,17 @@ func (w *World) tileCenter(tile int) int {
 	return tile*tileWorldSize + tileWorldSize/2
 }
 
+func (w *World) isAtTileCenter(entity *Entity) bool {
+	tileX, tileY := w.toTileCoords(entity.X, entity.Y)
+	return entity.X == w.tileCenter(tileX) && entity.Y == w.tileCenter(tileY)
+}
+
 func (w *World) isWalkable(x, y int) bool {
 	if x < 0 || y < 0 || x >= w.mapWidth || y >= w.mapHeight {
 		return false
 	}
 
-	return w.mapData[y][x] != 2
+	return w.tiles.walkable[y*w.mapWidth+x]
 }
 
 func absInt(value int) int {
@@ -253,108 +481,3 @@ func absInt(value int) int {
 
 	return value
 }
-
-func (w *World) findPath(start, goal tilePoint) []tilePoint {
-	if !w.isWalkable(goal.X, goal.Y) {
-		return nil
-	}
-
-	startKey := keyFor(start.X, start.Y)
-	goalKey := keyFor(goal.X, goal.Y)
-
-	open := []pathNode{{
-		point: start,
-		f:     heuristic(start, goal),
-	}}
-	openSet := map[string]struct{}{startKey: {}}
-	cameFrom := map[string]string{}
-	gScore := map[string]int{startKey: 0}
-
-	for len(open) > 0 {
-		currentIndex := 0
-		for i := 1; i < len(open); i += 1 {
-			if open[i].f < open[currentIndex].f {
-				currentIndex = i
-			}
-		}
-
-		current := open[currentIndex]
-		open = append(open[:currentIndex], open[currentIndex+1:]...)
-		currentKey := keyFor(current.point.X, current.point.Y)
-		delete(openSet, currentKey)
-
-		if currentKey == goalKey {
-			return reconstructPath(cameFrom, goalKey)
-		}
-
-		neighbors := []tilePoint{
-			{X: current.point.X + 1, Y: current.point.Y},
-			{X: current.point.X - 1, Y: current.point.Y},
-			{X: current.point.X, Y: current.point.Y + 1},
-			{X: current.point.X, Y: current.point.Y - 1},
-		}
-
-		for _, neighbor := range neighbors {
-			if !w.isWalkable(neighbor.X, neighbor.Y) {
-				continue
-			}
-
-			neighborKey := keyFor(neighbor.X, neighbor.Y)
-			tentativeG := gScore[currentKey] + 1
-			if existingG, ok := gScore[neighborKey]; ok && tentativeG >= existingG {
-				continue
-			}
-
-			cameFrom[neighborKey] = currentKey
-			gScore[neighborKey] = tentativeG
-			fScore := tentativeG + heuristic(neighbor, goal)
-
-			if _, ok := openSet[neighborKey]; !ok {
-				open = append(open, pathNode{point: neighbor, f: fScore})
-				openSet[neighborKey] = struct{}{}
-			}
-		}
-	}
-
-	return nil
-}
-
-type pathNode struct {
-	point tilePoint
-	f     int
-}
-
-func keyFor(x, y int) string {
-	return fmt.Sprintf("%d,%d", x, y)
-}
-
-func heuristic(a, b tilePoint) int {
-	return absInt(a.X-b.X) + absInt(a.Y-b.Y)
-}
-
-func reconstructPath(cameFrom map[string]string, goalKey string) []tilePoint {
-	var path []tilePoint
-	currentKey := goalKey
-
-	for {
-		x, y := parseKey(currentKey)
-		path = append(path, tilePoint{X: x, Y: y})
-		prev, ok := cameFrom[currentKey]
-		if !ok {
-			break
-		}
-		currentKey = prev
-	}
-
-	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
-		path[i], path[j] = path[j], path[i]
-	}
-
-	return path
-}
-
-func parseKey(key string) (int, int) {
-	var x, y int
-	fmt.Sscanf(key, "%d,%d", &x, &y)
-	return x, y
-}
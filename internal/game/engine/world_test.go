@@ -0,0 +1,85 @@
+package engine
+
+import "testing"
+
+func TestAddPlayerRestoresPosition(t *testing.T) {
+	rows := []string{
+		".....",
+		".#...",
+		".....",
+		".....",
+		".....",
+	}
+
+	tests := []struct {
+		name string
+		last func(w *World) *Position
+		want func(w *World) Position
+	}{
+		{
+			name: "no saved position",
+			last: func(w *World) *Position { return nil },
+			want: func(w *World) Position { return Position{X: w.tileCenter(2), Y: w.tileCenter(2)} },
+		},
+		{
+			name: "saved on open ground",
+			last: func(w *World) *Position { return &Position{X: w.tileCenter(3) + 7, Y: w.tileCenter(4) - 3} },
+			want: func(w *World) Position { return Position{X: w.tileCenter(3) + 7, Y: w.tileCenter(4) - 3} },
+		},
+		{
+			name: "saved inside a wall",
+			last: func(w *World) *Position { return &Position{X: w.tileCenter(1), Y: w.tileCenter(1)} },
+			want: func(w *World) Position { return Position{X: w.tileCenter(2), Y: w.tileCenter(2)} },
+		},
+		{
+			name: "saved off the map",
+			last: func(w *World) *Position { return &Position{X: w.tileCenter(9), Y: w.tileCenter(1)} },
+			want: func(w *World) Position { return Position{X: w.tileCenter(2), Y: w.tileCenter(2)} },
+		},
+		{
+			name: "saved at negative coordinates",
+			last: func(w *World) *Position { return &Position{X: -1, Y: w.tileCenter(1)} },
+			want: func(w *World) Position { return Position{X: w.tileCenter(2), Y: w.tileCenter(2)} },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewWorld(testMap(rows, false))
+			w.AddPlayer("alice", tt.last(w))
+
+			players := w.SnapshotPlayers()
+			if len(players) != 1 {
+				t.Fatalf("world has %d players, want 1", len(players))
+			}
+			got := Position{X: players[0].X, Y: players[0].Y}
+			if want := tt.want(w); got != want {
+				t.Fatalf("spawned at %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestRemovePlayerReturnsFinalState(t *testing.T) {
+	w := NewWorld(testMap([]string{"....."}, false))
+	w.AddPlayer("alice", &Position{X: w.tileCenter(0), Y: w.tileCenter(0)})
+	w.SetPlayerTarget("alice", w.tileCenter(4), w.tileCenter(0))
+	for i := 0; i < 10; i += 1 {
+		w.Step(0.05)
+	}
+	moved := w.SnapshotPlayers()[0]
+
+	player, ok := w.RemovePlayer("alice")
+	if !ok {
+		t.Fatal("RemovePlayer reported an unknown player")
+	}
+	if player.X != moved.X || player.Y != moved.Y {
+		t.Fatalf("removed player at (%d, %d), want (%d, %d)", player.X, player.Y, moved.X, moved.Y)
+	}
+	if player.X == w.tileCenter(0) {
+		t.Fatal("player did not move before it was removed")
+	}
+	if _, ok := w.RemovePlayer("alice"); ok {
+		t.Fatal("removed a player twice")
+	}
+}
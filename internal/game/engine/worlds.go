@@ -0,0 +1,352 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// MapChange reports a player moved between maps by a portal.
+type MapChange struct {
+	PlayerID string
+	From     string
+	To       string
+}
+
+// WorldManager hosts one World per map and moves players between them
+// through portals. Each World keeps its own lock; the manager's lock guards
+// the set of maps and which map each player is on.
+type WorldManager struct {
+	mu         sync.RWMutex
+	worlds     map[string]*World
+	maps       map[string]MapData
+	names      []string
+	players    map[string]string
+	defaultMap string
+	bus        *EventBus
+}
+
+func NewWorldManager() *WorldManager {
+	return &WorldManager{
+		worlds:  make(map[string]*World),
+		maps:    make(map[string]MapData),
+		players: make(map[string]string),
+		bus:     NewEventBus(),
+	}
+}
+
+// Events returns the bus every hosted World publishes on.
+func (m *WorldManager) Events() *EventBus {
+	return m.bus
+}
+
+// AddMap validates a map and creates its World. The first map added becomes
+// the default one.
+func (m *WorldManager) AddMap(name string, data MapData) (*World, error) {
+	if name == "" {
+		return nil, fmt.Errorf("map without a name")
+	}
+	if err := validateMapData(data); err != nil {
+		return nil, fmt.Errorf("map %q: %w", name, err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.worlds[name]; ok {
+		return nil, fmt.Errorf("duplicate map %q", name)
+	}
+
+	world := NewWorld(data)
+	world.name = name
+	world.bus = m.bus
+	m.worlds[name] = world
+	m.maps[name] = data
+	m.names = append(m.names, name)
+	sort.Strings(m.names)
+	if m.defaultMap == "" {
+		m.defaultMap = name
+	}
+
+	return world, nil
+}
+
+// SetDefaultMap picks the map new players join and unknown maps fall back to.
+func (m *WorldManager) SetDefaultMap(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.worlds[name]; !ok {
+		return fmt.Errorf("unknown map %q", name)
+	}
+	m.defaultMap = name
+
+	return nil
+}
+
+func (m *WorldManager) DefaultMap() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.defaultMap
+}
+
+// Names returns the hosted maps in name order.
+func (m *WorldManager) Names() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return append([]string(nil), m.names...)
+}
+
+func (m *WorldManager) World(name string) (*World, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	world, ok := m.worlds[name]
+	return world, ok
+}
+
+// MapData returns the map a world currently runs, including tiles changed
+// with SetTile since it was loaded.
+func (m *WorldManager) MapData(name string) (MapData, bool) {
+	world, ok := m.World(name)
+	if !ok {
+		return MapData{}, false
+	}
+
+	return world.MapData(), true
+}
+
+// ValidatePortals checks that every portal leads to a hosted map and, when
+// it names one, a spawn point that map declares. Call it once every map has
+// been added.
+func (m *WorldManager) ValidatePortals() error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return validatePortalLinks(m.names, m.maps)
+}
+
+// ReloadMap validates data against the map and the portals linking it to the
+// other hosted maps, then schedules it on the map's World for the next Step.
+func (m *WorldManager) ReloadMap(name string, data MapData) error {
+	if err := validateMapData(data); err != nil {
+		return fmt.Errorf("map %q: %w", name, err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	world, ok := m.worlds[name]
+	if !ok {
+		return fmt.Errorf("unknown map %q", name)
+	}
+
+	maps := make(map[string]MapData, len(m.maps))
+	for other, otherData := range m.maps {
+		maps[other] = otherData
+	}
+	maps[name] = data
+	if err := validatePortalLinks(m.names, maps); err != nil {
+		return err
+	}
+
+	if err := world.ReloadMap(data); err != nil {
+		return fmt.Errorf("map %q: %w", name, err)
+	}
+	m.maps[name] = data
+
+	return nil
+}
+
+// holdEvents stops worlds from publishing while the manager lock is held and
+// returns them appended to held. A World called with the manager lock held
+// would otherwise run subscribers that cannot call back into the manager.
+func holdEvents(held []*World, worlds ...*World) []*World {
+	for _, world := range worlds {
+		world.mu.Lock()
+		world.eventHolds += 1
+		world.mu.Unlock()
+	}
+
+	return append(held, worlds...)
+}
+
+// takeEvents removes and returns the events the world queued while held.
+func (w *World) takeEvents() []Event {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	events := w.events
+	w.events = nil
+
+	return events
+}
+
+// releaseEvents undoes holdEvents and publishes what the worlds queued.
+func releaseEvents(worlds []*World) {
+	for _, world := range worlds {
+		world.mu.Lock()
+		world.eventHolds -= 1
+		world.unlockAndNotify()
+	}
+}
+
+func validatePortalLinks(names []string, maps map[string]MapData) error {
+	for _, name := range names {
+		for i, portal := range maps[name].Portals {
+			destination, ok := maps[portal.Map]
+			if !ok {
+				return fmt.Errorf("map %q: portal %d leads to unknown map %q", name, i, portal.Map)
+			}
+			if portal.Spawn == "" {
+				continue
+			}
+			if !hasSpawn(destination, portal.Spawn) {
+				return fmt.Errorf("map %q: portal %d leads to unknown spawn %q on %q", name, i, portal.Spawn, portal.Map)
+			}
+		}
+	}
+
+	return nil
+}
+
+func hasSpawn(data MapData, name string) bool {
+	for _, spawn := range data.Spawns {
+		if spawn.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PlayerWorld returns the World a player is on and its map name.
+func (m *WorldManager) PlayerWorld(id string) (*World, string, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	name, ok := m.players[id]
+	if !ok {
+		return nil, "", false
+	}
+
+	return m.worlds[name], name, true
+}
+
+// AddPlayer places a player on mapName, or on the default map when mapName
+// is not hosted, and returns the map it joined.
+func (m *WorldManager) AddPlayer(id, mapName string, last *Position) string {
+	m.mu.Lock()
+	var held []*World
+	defer func() { releaseEvents(held) }()
+	defer m.mu.Unlock()
+
+	if previous, ok := m.players[id]; ok {
+		held = holdEvents(held, m.worlds[previous])
+		m.worlds[previous].RemovePlayer(id)
+	}
+
+	world, ok := m.worlds[mapName]
+	if !ok {
+		mapName = m.defaultMap
+		world = m.worlds[mapName]
+		last = nil
+	}
+
+	held = holdEvents(held, world)
+	world.AddPlayer(id, last)
+	m.players[id] = mapName
+
+	return mapName
+}
+
+// RemovePlayer takes a player off whichever map it is on.
+func (m *WorldManager) RemovePlayer(id string) (Player, string, bool) {
+	m.mu.Lock()
+	var held []*World
+	defer func() { releaseEvents(held) }()
+	defer m.mu.Unlock()
+
+	name, ok := m.players[id]
+	if !ok {
+		return Player{}, "", false
+	}
+	delete(m.players, id)
+
+	held = holdEvents(held, m.worlds[name])
+	player, ok := m.worlds[name].RemovePlayer(id)
+	return player, name, ok
+}
+
+// EnqueueCommand queues cmd on the World its player is on. It reports false
+// for unknown players and full queues.
+func (m *WorldManager) EnqueueCommand(cmd Command) (uint64, bool) {
+	world, _, ok := m.PlayerWorld(cmd.PlayerID)
+	if !ok {
+		return 0, false
+	}
+
+	return world.EnqueueCommand(cmd)
+}
+
+// Step advances every World in map name order, then moves the players that
+// entered portals to their destination maps.
+func (m *WorldManager) Step(deltaSeconds float64) []MapChange {
+	m.mu.RLock()
+	worlds := make([]*World, 0, len(m.names))
+	for _, name := range m.names {
+		worlds = append(worlds, m.worlds[name])
+	}
+	m.mu.RUnlock()
+
+	var transits []PortalTransit
+	for _, world := range worlds {
+		world.Step(deltaSeconds)
+		transits = append(transits, world.DrainPortalTransits()...)
+	}
+	if len(transits) == 0 {
+		return nil
+	}
+
+	m.mu.Lock()
+	held := holdEvents(nil, worlds...)
+	changes := make([]MapChange, 0, len(transits))
+	var events []Event
+	for _, transit := range transits {
+		from, ok := m.players[transit.PlayerID]
+		if !ok {
+			continue
+		}
+		destination, ok := m.worlds[transit.Map]
+		if !ok {
+			continue
+		}
+		source := m.worlds[from]
+		if _, ok := source.RemovePlayer(transit.PlayerID); !ok {
+			continue
+		}
+
+		destination.AddPlayerAtSpawn(transit.PlayerID, transit.Spawn)
+		m.players[transit.PlayerID] = transit.Map
+		changes = append(changes, MapChange{PlayerID: transit.PlayerID, From: from, To: transit.Map})
+
+		// Each world queues its own events, so collect them per transit to
+		// keep leave, join and map change together and in order.
+		events = append(events, source.takeEvents()...)
+		events = append(events, destination.takeEvents()...)
+		events = append(events, PlayerChangedMap{
+			EventMeta: EventMeta{Map: transit.Map, Tick: destination.Tick()},
+			PlayerID:  transit.PlayerID,
+			From:      from,
+		})
+	}
+	m.mu.Unlock()
+
+	// Published outside the lock so subscribers may use the manager.
+	releaseEvents(held)
+	m.bus.Publish(events...)
+
+	return changes
+}
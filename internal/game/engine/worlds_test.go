@@ -0,0 +1,141 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWorldManagerAddMap(t *testing.T) {
+	m := NewWorldManager()
+	if _, err := m.AddMap("town", openMap(4)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.AddMap("forest", openMap(4)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.AddMap("town", openMap(4)); err == nil {
+		t.Fatal("added a map twice")
+	}
+	if _, err := m.AddMap("", openMap(4)); err == nil {
+		t.Fatal("added a map without a name")
+	}
+	if _, err := m.AddMap("broken", MapData{Width: 2, Height: 2}); err == nil {
+		t.Fatal("added an invalid map")
+	}
+
+	if got := m.Names(); !reflect.DeepEqual(got, []string{"forest", "town"}) {
+		t.Fatalf("Names() = %v, want forest and town", got)
+	}
+	if got := m.DefaultMap(); got != "town" {
+		t.Fatalf("DefaultMap() = %q, want the first map added", got)
+	}
+	if err := m.SetDefaultMap("cave"); err == nil {
+		t.Fatal("picked an unknown default map")
+	}
+	if err := m.SetDefaultMap("forest"); err != nil || m.DefaultMap() != "forest" {
+		t.Fatalf("SetDefaultMap(forest) = %v, default %q", err, m.DefaultMap())
+	}
+}
+
+func TestValidatePortals(t *testing.T) {
+	tests := []struct {
+		name    string
+		portal  Portal
+		wantErr bool
+	}{
+		{name: "default spawn", portal: Portal{X: 3, Y: 0, Map: "mine"}},
+		{name: "named spawn", portal: Portal{X: 3, Y: 0, Map: "mine", Spawn: "entrance"}},
+		{name: "unknown map", portal: Portal{X: 3, Y: 0, Map: "cave"}, wantErr: true},
+		{name: "unknown spawn", portal: Portal{X: 3, Y: 0, Map: "mine", Spawn: "exit"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			town := openMap(4)
+			town.Portals = []Portal{tt.portal}
+			mine := openMap(4)
+			mine.Spawns = []SpawnPoint{{Name: "entrance", X: 1, Y: 1}}
+
+			m := NewWorldManager()
+			if _, err := m.AddMap("town", town); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := m.AddMap("mine", mine); err != nil {
+				t.Fatal(err)
+			}
+			if err := m.ValidatePortals(); (err != nil) != tt.wantErr {
+				t.Fatalf("ValidatePortals() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPortalsMovePlayersBetweenMaps(t *testing.T) {
+	town := testMap([]string{"....."}, false)
+	town.Portals = []Portal{{X: 4, Y: 0, Map: "mine", Spawn: "entrance"}}
+	mine := openMap(4)
+	mine.Spawns = []SpawnPoint{{Name: "entrance", X: 2, Y: 3}}
+
+	m := NewWorldManager()
+	townWorld, _ := m.AddMap("town", town)
+	mineWorld, _ := m.AddMap("mine", mine)
+	if got := m.AddPlayer("alice", "town", &Position{X: townWorld.tileCenter(0), Y: townWorld.tileCenter(0)}); got != "town" {
+		t.Fatalf("alice joined %q, want town", got)
+	}
+	townWorld.SetPlayerTarget("alice", townWorld.tileCenter(4), townWorld.tileCenter(0))
+
+	var changes []MapChange
+	for i := 0; i < 100 && len(changes) == 0; i += 1 {
+		changes = m.Step(0.05)
+	}
+
+	want := []MapChange{{PlayerID: "alice", From: "town", To: "mine"}}
+	if !reflect.DeepEqual(changes, want) {
+		t.Fatalf("changes = %+v, want %+v", changes, want)
+	}
+	if _, name, _ := m.PlayerWorld("alice"); name != "mine" {
+		t.Fatalf("alice is on %q, want mine", name)
+	}
+	if len(townWorld.SnapshotPlayers()) != 0 {
+		t.Fatal("alice is still in the town world")
+	}
+	if got := playerTile(mineWorld, "alice"); got != (tilePoint{X: 2, Y: 3}) {
+		t.Fatalf("alice arrived at %v, want the entrance", got)
+	}
+	if mineWorld.SnapshotPlayers()[0].Path != nil {
+		t.Fatal("alice kept her town path in the mine")
+	}
+}
+
+func TestWorldManagerAddPlayer(t *testing.T) {
+	m := NewWorldManager()
+	town, _ := m.AddMap("town", openMap(4))
+	forest, _ := m.AddMap("forest", openMap(4))
+	last := &Position{X: town.tileCenter(3), Y: town.tileCenter(3)}
+
+	if got := m.AddPlayer("alice", "cave", last); got != "town" {
+		t.Fatalf("alice joined %q, want the default map", got)
+	}
+	if got := playerTile(town, "alice"); got == (tilePoint{X: 3, Y: 3}) {
+		t.Fatal("alice kept her position from an unknown map")
+	}
+
+	// Joining again moves the player rather than copying it.
+	if got := m.AddPlayer("alice", "forest", last); got != "forest" {
+		t.Fatalf("alice joined %q, want forest", got)
+	}
+	if len(town.SnapshotPlayers()) != 0 || len(forest.SnapshotPlayers()) != 1 {
+		t.Fatal("alice is on more than one map")
+	}
+
+	if _, name, ok := m.RemovePlayer("alice"); !ok || name != "forest" {
+		t.Fatalf("RemovePlayer(alice) = %q, %v", name, ok)
+	}
+	if _, _, ok := m.PlayerWorld("alice"); ok {
+		t.Fatal("alice is still hosted after leaving")
+	}
+	if _, ok := m.EnqueueCommand(Command{PlayerID: "alice", Type: CommandMoveTo}); ok {
+		t.Fatal("queued a command for a player that left")
+	}
+}
@@ -0,0 +1,298 @@
+// Package mapgen generates playable maps from a seed. The same Config always
+// produces the same map.
+package mapgen
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/felipemalacarne/etheria/internal/game/engine"
+)
+
+// Tile ids of generated maps. Grass, road and wall keep the ids of the
+// default tile types so generated maps render with the same tileset.
+const (
+	TileGrass  = engine.TileGrass
+	TileRoad   = engine.TileDirt
+	TileWall   = engine.TileWall
+	TileWater  = 3
+	TileForest = 4
+)
+
+// minPocketTiles is the smallest walkable pocket worth a road; smaller ones
+// cut off by water or rock are flooded instead.
+const minPocketTiles = 24
+
+// Config controls the generator. Levels are thresholds on noise rescaled to
+// 0..1: elevation below WaterLevel is a lake and above RockLevel is rock, and
+// vegetation above ForestLevel is forest. Towns are the road network's nodes
+// and get a spawn point each; the default spawn is the one nearest the
+// centre.
+type Config struct {
+	Seed        int64
+	Width       int
+	Height      int
+	Diagonal    bool
+	WaterLevel  float64
+	RockLevel   float64
+	ForestLevel float64
+	Towns       int
+	Trees       int
+}
+
+// DefaultConfig returns the settings used by the mapgen command for a map of
+// the given size.
+func DefaultConfig(seed int64, width, height int) Config {
+	area := width * height
+	return Config{
+		Seed:        seed,
+		Width:       width,
+		Height:      height,
+		Diagonal:    true,
+		WaterLevel:  0.3,
+		RockLevel:   0.88,
+		ForestLevel: 0.65,
+		Towns:       max(3, area/1500),
+		Trees:       area / 400,
+	}
+}
+
+func (c Config) validate() error {
+	if c.Width < 16 || c.Height < 16 {
+		return fmt.Errorf("map must be at least 16x16 tiles")
+	}
+	if c.Towns < 1 {
+		return fmt.Errorf("map needs at least one town")
+	}
+	if c.Trees < 0 {
+		return fmt.Errorf("negative tree count")
+	}
+
+	return nil
+}
+
+// TileTypes is the tile table of generated maps. Roads are cheaper to walk
+// so paths follow them, and forests are slow and block sight.
+func TileTypes() []engine.TileType {
+	return []engine.TileType{
+		{ID: TileGrass, Name: "grass", Walkable: true, MoveCost: 1},
+		{ID: TileRoad, Name: "road", Walkable: true, MoveCost: 0.8},
+		{ID: TileWall, Name: "rock", Walkable: false, BlocksSight: true},
+		{ID: TileWater, Name: "water", Walkable: false},
+		{ID: TileForest, Name: "forest", Walkable: true, MoveCost: 2, BlocksSight: true},
+	}
+}
+
+// generator holds the map being built. Tiles are stored row by row.
+type generator struct {
+	config Config
+	rng    *rand.Rand
+	width  int
+	height int
+	tiles  []int
+}
+
+// Generate builds a map: noise terrain with lakes, rock and forest inside a
+// rock border, towns joined by roads, every walkable tile reachable from the
+// default spawn, and trees to chop along the forest edges.
+func Generate(config Config) (engine.MapData, error) {
+	if err := config.validate(); err != nil {
+		return engine.MapData{}, err
+	}
+
+	g := &generator{
+		config: config,
+		rng:    rand.New(rand.NewSource(config.Seed)),
+		width:  config.Width,
+		height: config.Height,
+		tiles:  make([]int, config.Width*config.Height),
+	}
+	g.terrain()
+	towns := g.placeTowns()
+	g.connectTowns(towns)
+	g.connectPockets(towns[0])
+	objects := g.placeTrees(towns)
+
+	data := engine.MapData{
+		Width:     g.width,
+		Height:    g.height,
+		Tiles:     make([][]int, g.height),
+		Diagonal:  config.Diagonal,
+		TileTypes: TileTypes(),
+		Objects:   objects,
+		Properties: map[string]any{
+			"generator": "mapgen",
+			"seed":      config.Seed,
+		},
+	}
+	for y := range data.Tiles {
+		data.Tiles[y] = append([]int(nil), g.tiles[y*g.width:(y+1)*g.width]...)
+	}
+	data.Spawns = append(data.Spawns, engine.SpawnPoint{Name: engine.SpawnDefault, X: towns[0].x, Y: towns[0].y})
+	for i, town := range towns {
+		data.Spawns = append(data.Spawns, engine.SpawnPoint{Name: fmt.Sprintf("town-%d", i+1), X: town.x, Y: town.y})
+	}
+
+	return data, nil
+}
+
+type point struct {
+	x int
+	y int
+}
+
+func (g *generator) index(x, y int) int {
+	return y*g.width + x
+}
+
+func (g *generator) tile(x, y int) int {
+	return g.tiles[g.index(x, y)]
+}
+
+func (g *generator) interior(x, y int) bool {
+	return x > 0 && y > 0 && x < g.width-1 && y < g.height-1
+}
+
+func (g *generator) walkable(x, y int) bool {
+	switch g.tile(x, y) {
+	case TileGrass, TileRoad, TileForest:
+		return true
+	default:
+		return false
+	}
+}
+
+// terrain lays out lakes, rock and forest from two noise fields.
+func (g *generator) terrain() {
+	cell := max(8, min(g.width, g.height)/4)
+	elevation := newNoiseField(g.rng, g.width, g.height, cell, 4)
+	vegetation := newNoiseField(g.rng, g.width, g.height, cell/2, 3)
+
+	for y := 0; y < g.height; y += 1 {
+		for x := 0; x < g.width; x += 1 {
+			tile := TileGrass
+			switch {
+			case !g.interior(x, y):
+				tile = TileWall
+			case elevation.at(x, y) < g.config.WaterLevel:
+				tile = TileWater
+			case elevation.at(x, y) > g.config.RockLevel:
+				tile = TileWall
+			case vegetation.at(x, y) > g.config.ForestLevel:
+				tile = TileForest
+			}
+			g.tiles[g.index(x, y)] = tile
+		}
+	}
+}
+
+// placeTowns picks spread-out tiles for the towns and clears a square of
+// grass around each. The town nearest the centre comes first.
+func (g *generator) placeTowns() []point {
+	const clearing = 2
+	margin := clearing + 1
+	spacing := max(6, min(g.width, g.height)/(g.config.Towns+1))
+
+	var towns []point
+	for attempt := 0; attempt < g.config.Towns*200 && len(towns) < g.config.Towns; attempt += 1 {
+		candidate := point{
+			x: margin + g.rng.Intn(g.width-2*margin),
+			y: margin + g.rng.Intn(g.height-2*margin),
+		}
+		if g.tile(candidate.x, candidate.y) == TileWater || g.tile(candidate.x, candidate.y) == TileWall {
+			continue
+		}
+		if nearAny(candidate, towns, spacing) {
+			continue
+		}
+		towns = append(towns, candidate)
+	}
+	if len(towns) == 0 {
+		towns = append(towns, point{x: g.width / 2, y: g.height / 2})
+	}
+
+	center := point{x: g.width / 2, y: g.height / 2}
+	nearest := 0
+	for i, town := range towns {
+		if distanceSquared(town, center) < distanceSquared(towns[nearest], center) {
+			nearest = i
+		}
+	}
+	towns[0], towns[nearest] = towns[nearest], towns[0]
+
+	for _, town := range towns {
+		for y := town.y - clearing; y <= town.y+clearing; y += 1 {
+			for x := town.x - clearing; x <= town.x+clearing; x += 1 {
+				if g.interior(x, y) {
+					g.tiles[g.index(x, y)] = TileGrass
+				}
+			}
+		}
+	}
+
+	return towns
+}
+
+// placeTrees scatters chop-able trees on grass next to forest, away from the
+// towns and with a walkable tile beside each.
+func (g *generator) placeTrees(towns []point) []engine.MapObject {
+	var candidates []point
+	for y := 1; y < g.height-1; y += 1 {
+		for x := 1; x < g.width-1; x += 1 {
+			if g.tile(x, y) == TileGrass && g.touches(x, y, TileForest) {
+				candidates = append(candidates, point{x: x, y: y})
+			}
+		}
+	}
+	g.rng.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+
+	var objects []engine.MapObject
+	var placed []point
+	for _, candidate := range candidates {
+		if len(objects) >= g.config.Trees {
+			break
+		}
+		if nearAny(candidate, placed, 2) || nearAny(candidate, towns, 4) || !g.touches(candidate.x, candidate.y, TileGrass) {
+			continue
+		}
+		placed = append(placed, candidate)
+		objects = append(objects, engine.MapObject{
+			Type:         "tree",
+			X:            candidate.x,
+			Y:            candidate.y,
+			Actions:      []string{"chop"},
+			RespawnTicks: 600,
+		})
+	}
+
+	return objects
+}
+
+// touches reports whether a straight neighbour of the tile is of the given
+// type.
+func (g *generator) touches(x, y, tile int) bool {
+	for _, step := range steps {
+		if g.tile(x+step.x, y+step.y) == tile {
+			return true
+		}
+	}
+
+	return false
+}
+
+func nearAny(candidate point, others []point, spacing int) bool {
+	for _, other := range others {
+		if distanceSquared(candidate, other) < spacing*spacing {
+			return true
+		}
+	}
+
+	return false
+}
+
+func distanceSquared(a, b point) int {
+	dx, dy := a.x-b.x, a.y-b.y
+	return dx*dx + dy*dy
+}
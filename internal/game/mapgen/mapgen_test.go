@@ -0,0 +1,109 @@
+package mapgen
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/felipemalacarne/etheria/internal/game/engine"
+)
+
+func TestGenerateIsDeterministic(t *testing.T) {
+	straight := DefaultConfig(7, 64, 64)
+	straight.Diagonal = false
+	towns := DefaultConfig(3, 80, 48)
+	towns.Towns = 6
+	towns.Trees = 0
+
+	tests := []struct {
+		name   string
+		config Config
+	}{
+		{name: "default", config: DefaultConfig(1, engine.DefaultMapWidth, engine.DefaultMapHeight)},
+		{name: "smallest", config: DefaultConfig(2, 16, 16)},
+		{name: "straight movement", config: straight},
+		{name: "many towns, no trees", config: towns},
+		{name: "large", config: DefaultConfig(99, 256, 192)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first, err := Generate(tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			second, err := Generate(tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(first, second) {
+				t.Fatal("the same config generated different maps")
+			}
+
+			other := tt.config
+			other.Seed += 1
+			reseeded, err := Generate(other)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reflect.DeepEqual(first.Tiles, reseeded.Tiles) {
+				t.Fatal("another seed generated the same tiles")
+			}
+		})
+	}
+}
+
+func TestGenerateIsPlayable(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+	}{
+		{name: "default", config: DefaultConfig(1, engine.DefaultMapWidth, engine.DefaultMapHeight)},
+		{name: "smallest", config: DefaultConfig(5, 16, 16)},
+		{name: "wide", config: DefaultConfig(6, 200, 40)},
+		{name: "large", config: DefaultConfig(7, 256, 256)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Generate(tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			report := engine.AnalyzeMap(data, engine.DefaultChunkSizeTiles)
+			for _, issue := range report.Issues {
+				if issue.Severity == engine.MapIssueError {
+					t.Errorf("%s", issue.Message)
+				}
+			}
+			if len(report.Areas) != 1 {
+				t.Fatalf("walkable tiles form %d areas, want 1", len(report.Areas))
+			}
+		})
+	}
+}
+
+func TestGenerateRejectsInvalidConfigs(t *testing.T) {
+	negativeTrees := DefaultConfig(1, 32, 32)
+	negativeTrees.Trees = -1
+	noTowns := DefaultConfig(1, 32, 32)
+	noTowns.Towns = 0
+
+	tests := []struct {
+		name   string
+		config Config
+	}{
+		{name: "too narrow", config: DefaultConfig(1, 15, 32)},
+		{name: "too short", config: DefaultConfig(1, 32, 15)},
+		{name: "no towns", config: noTowns},
+		{name: "negative trees", config: negativeTrees},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Generate(tt.config); err == nil {
+				t.Fatal("generated a map from an invalid config")
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package mapgen
+
+import "math/rand"
+
+// noiseField is fractal value noise sampled once per tile and rescaled to
+// 0..1, so thresholds cover roughly the same share of every map.
+type noiseField struct {
+	width  int
+	values []float64
+}
+
+// newNoiseField sums octaves of value noise, each with half the lattice
+// spacing and half the weight of the one before, starting at cell tiles.
+func newNoiseField(rng *rand.Rand, width, height, cell, octaves int) noiseField {
+	field := noiseField{width: width, values: make([]float64, width*height)}
+
+	amplitude := 1.0
+	for octave := 0; octave < octaves && cell > 0; octave += 1 {
+		lattice := newLattice(rng, width, height, cell)
+		for y := 0; y < height; y += 1 {
+			for x := 0; x < width; x += 1 {
+				field.values[y*width+x] += amplitude * lattice.sample(x, y)
+			}
+		}
+		amplitude /= 2
+		cell /= 2
+	}
+
+	low, high := field.values[0], field.values[0]
+	for _, value := range field.values {
+		low = min(low, value)
+		high = max(high, value)
+	}
+	if high > low {
+		for i, value := range field.values {
+			field.values[i] = (value - low) / (high - low)
+		}
+	}
+
+	return field
+}
+
+func (f noiseField) at(x, y int) float64 {
+	return f.values[y*f.width+x]
+}
+
+// lattice holds random values at every cell-th tile, smoothly interpolated
+// in between.
+type lattice struct {
+	cell    int
+	columns int
+	values  []float64
+}
+
+func newLattice(rng *rand.Rand, width, height, cell int) lattice {
+	columns := width/cell + 2
+	rows := height/cell + 2
+	values := make([]float64, columns*rows)
+	for i := range values {
+		values[i] = rng.Float64()
+	}
+
+	return lattice{cell: cell, columns: columns, values: values}
+}
+
+func (l lattice) sample(x, y int) float64 {
+	cellX, cellY := x/l.cell, y/l.cell
+	tx := smoothstep(float64(x%l.cell) / float64(l.cell))
+	ty := smoothstep(float64(y%l.cell) / float64(l.cell))
+
+	top := lerp(l.value(cellX, cellY), l.value(cellX+1, cellY), tx)
+	bottom := lerp(l.value(cellX, cellY+1), l.value(cellX+1, cellY+1), tx)
+
+	return lerp(top, bottom, ty)
+}
+
+func (l lattice) value(x, y int) float64 {
+	return l.values[y*l.columns+x]
+}
+
+func smoothstep(t float64) float64 {
+	return t * t * (3 - 2*t)
+}
+
+func lerp(a, b, t float64) float64 {
+	return a + (b-a)*t
+}
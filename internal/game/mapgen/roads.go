@@ -0,0 +1,226 @@
+package mapgen
+
+import "container/heap"
+
+// steps are the straight moves roads and connectivity checks use. Straight
+// connectivity implies diagonal connectivity, so maps stay connected whether
+// or not diagonal movement is enabled.
+var steps = []point{{x: 1, y: 0}, {x: -1, y: 0}, {x: 0, y: 1}, {x: 0, y: -1}}
+
+// roadCost is the price of laying road over a tile: existing roads are
+// reused, forests avoided, and lakes and rock bridged or tunnelled only when
+// going around costs more.
+func roadCost(tile int) int {
+	switch tile {
+	case TileRoad:
+		return 1
+	case TileGrass:
+		return 2
+	case TileForest:
+		return 4
+	case TileWater:
+		return 8
+	default:
+		return 12
+	}
+}
+
+// connectTowns joins the towns with roads along a minimum spanning tree,
+// growing it from the first town.
+func (g *generator) connectTowns(towns []point) {
+	connected := make([]bool, len(towns))
+	connected[0] = true
+	for added := 1; added < len(towns); added += 1 {
+		from, to := -1, -1
+		for i := range towns {
+			if !connected[i] {
+				continue
+			}
+			for j := range towns {
+				if connected[j] {
+					continue
+				}
+				if from < 0 || distanceSquared(towns[i], towns[j]) < distanceSquared(towns[from], towns[to]) {
+					from, to = i, j
+				}
+			}
+		}
+
+		connected[to] = true
+		g.layRoad(towns[from], towns[to])
+	}
+}
+
+// connectPockets makes every walkable tile reachable from start. Pockets too
+// small to matter are flooded; larger ones get a road to start.
+func (g *generator) connectPockets(start point) {
+	for {
+		reached := g.reachable(start)
+		pocket, ok := g.firstUnreached(reached)
+		if !ok {
+			return
+		}
+
+		tiles := g.reachable(pocket)
+		if countReached(tiles) < minPocketTiles {
+			for i, inPocket := range tiles {
+				if inPocket {
+					g.tiles[i] = TileWater
+				}
+			}
+			continue
+		}
+		g.layRoad(pocket, start)
+	}
+}
+
+func (g *generator) firstUnreached(reached []bool) (point, bool) {
+	for y := 1; y < g.height-1; y += 1 {
+		for x := 1; x < g.width-1; x += 1 {
+			if g.walkable(x, y) && !reached[g.index(x, y)] {
+				return point{x: x, y: y}, true
+			}
+		}
+	}
+
+	return point{}, false
+}
+
+// reachable flood-fills the walkable tiles connected to start.
+func (g *generator) reachable(start point) []bool {
+	reached := make([]bool, len(g.tiles))
+	reached[g.index(start.x, start.y)] = true
+	queue := []point{start}
+	for head := 0; head < len(queue); head += 1 {
+		current := queue[head]
+		for _, step := range steps {
+			next := point{x: current.x + step.x, y: current.y + step.y}
+			index := g.index(next.x, next.y)
+			if !g.interior(next.x, next.y) || reached[index] || !g.walkable(next.x, next.y) {
+				continue
+			}
+			reached[index] = true
+			queue = append(queue, next)
+		}
+	}
+
+	return reached
+}
+
+func countReached(reached []bool) int {
+	count := 0
+	for _, ok := range reached {
+		if ok {
+			count += 1
+		}
+	}
+
+	return count
+}
+
+// layRoad turns the cheapest route between two tiles into road.
+func (g *generator) layRoad(from, to point) {
+	for _, index := range g.roadRoute(from, to) {
+		g.tiles[index] = TileRoad
+	}
+}
+
+// roadRoute runs A* over the interior with roadCost weights. Ties are broken
+// by insertion order, so routes depend only on the map.
+func (g *generator) roadRoute(from, to point) []int {
+	goal := g.index(to.x, to.y)
+	cost := make([]int, len(g.tiles))
+	cameFrom := make([]int, len(g.tiles))
+	for i := range cost {
+		cost[i] = -1
+	}
+
+	start := g.index(from.x, from.y)
+	cost[start] = 0
+	cameFrom[start] = start
+	var open routeQueue
+	heap.Push(&open, routeNode{index: start, priority: g.manhattan(from, to)})
+
+	for open.Len() > 0 {
+		current := heap.Pop(&open).(routeNode)
+		if current.index == goal {
+			break
+		}
+		x, y := current.index%g.width, current.index/g.width
+		if current.priority-g.manhattan(point{x: x, y: y}, to) > cost[current.index] {
+			continue
+		}
+
+		for _, step := range steps {
+			nextX, nextY := x+step.x, y+step.y
+			if !g.interior(nextX, nextY) {
+				continue
+			}
+			next := g.index(nextX, nextY)
+			nextCost := cost[current.index] + roadCost(g.tiles[next])
+			if cost[next] >= 0 && nextCost >= cost[next] {
+				continue
+			}
+			cost[next] = nextCost
+			cameFrom[next] = current.index
+			heap.Push(&open, routeNode{
+				index:    next,
+				priority: nextCost + g.manhattan(point{x: nextX, y: nextY}, to),
+			})
+		}
+	}
+	if cost[goal] < 0 {
+		return nil
+	}
+
+	var route []int
+	for index := goal; index != start; index = cameFrom[index] {
+		route = append(route, index)
+	}
+
+	return append(route, start)
+}
+
+func (g *generator) manhattan(a, b point) int {
+	return max(a.x-b.x, b.x-a.x) + max(a.y-b.y, b.y-a.y)
+}
+
+type routeNode struct {
+	index    int
+	priority int
+	sequence int
+}
+
+// routeQueue is a container/heap min-heap ordered by priority, breaking ties
+// on the order nodes were pushed in.
+type routeQueue struct {
+	nodes    []routeNode
+	sequence int
+}
+
+func (q *routeQueue) Len() int { return len(q.nodes) }
+
+func (q *routeQueue) Less(i, j int) bool {
+	if q.nodes[i].priority != q.nodes[j].priority {
+		return q.nodes[i].priority < q.nodes[j].priority
+	}
+
+	return q.nodes[i].sequence < q.nodes[j].sequence
+}
+
+func (q *routeQueue) Swap(i, j int) { q.nodes[i], q.nodes[j] = q.nodes[j], q.nodes[i] }
+
+func (q *routeQueue) Push(value any) {
+	node := value.(routeNode)
+	node.sequence = q.sequence
+	q.sequence += 1
+	q.nodes = append(q.nodes, node)
+}
+
+func (q *routeQueue) Pop() any {
+	last := len(q.nodes) - 1
+	node := q.nodes[last]
+	q.nodes = q.nodes[:last]
+
+	return node
+}
@@ -0,0 +1,128 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/felipemalacarne/etheria/internal/domain/character"
+)
+
+// CharacterRepository persists character state in a JSON file (dev convenience).
+type CharacterRepository struct {
+	mu     sync.RWMutex
+	path   string
+	states map[string]character.State
+}
+
+type characterPayload struct {
+	Characters []character.State `json:"characters"`
+}
+
+func NewCharacterRepository(path string) (*CharacterRepository, error) {
+	repo := &CharacterRepository{
+		path:   path,
+		states: make(map[string]character.State),
+	}
+
+	if err := repo.load(); err != nil {
+		return nil, err
+	}
+
+	return repo, nil
+}
+
+func (r *CharacterRepository) Get(_ context.Context, userID string) (character.State, bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	state, ok := r.states[userID]
+	return state, ok, nil
+}
+
+func (r *CharacterRepository) Save(ctx context.Context, state character.State) error {
+	return r.SaveMany(ctx, []character.State{state})
+}
+
+func (r *CharacterRepository) SaveMany(_ context.Context, states []character.State) error {
+	if len(states) == 0 {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	previous := make(map[string]character.State, len(states))
+	for _, state := range states {
+		if old, ok := r.states[state.UserID]; ok {
+			previous[state.UserID] = old
+		}
+		r.states[state.UserID] = state
+	}
+
+	if err := r.saveLocked(); err != nil {
+		for _, state := range states {
+			if old, ok := previous[state.UserID]; ok {
+				r.states[state.UserID] = old
+			} else {
+				delete(r.states, state.UserID)
+			}
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (r *CharacterRepository) load() error {
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	var payload characterPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return err
+	}
+
+	for _, state := range payload.Characters {
+		r.states[state.UserID] = state
+	}
+
+	return nil
+}
+
+func (r *CharacterRepository) saveLocked() error {
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return err
+	}
+
+	payload := characterPayload{Characters: make([]character.State, 0, len(r.states))}
+	ids := make([]string, 0, len(r.states))
+	for id := range r.states {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		payload.Characters = append(payload.Characters, r.states[id])
+	}
+
+	data, err := json.MarshalIndent(payload, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmpPath := r.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, r.path)
+}
@@ -0,0 +1,137 @@
+package file
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/felipemalacarne/etheria/internal/domain/character"
+)
+
+func TestCharacterRepositoryPersists(t *testing.T) {
+	ctx := context.Background()
+	savedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		saves [][]character.State
+		want  map[string]character.State
+	}{
+		{
+			name: "single save",
+			saves: [][]character.State{
+				{{UserID: "alice", X: 10, Y: 20, UpdatedAt: savedAt}},
+			},
+			want: map[string]character.State{
+				"alice": {UserID: "alice", X: 10, Y: 20, UpdatedAt: savedAt},
+			},
+		},
+		{
+			name: "later save wins",
+			saves: [][]character.State{
+				{{UserID: "alice", X: 10, Y: 20, UpdatedAt: savedAt}},
+				{{UserID: "alice", X: 30, Y: 40, UpdatedAt: savedAt.Add(time.Minute)}},
+			},
+			want: map[string]character.State{
+				"alice": {UserID: "alice", X: 30, Y: 40, UpdatedAt: savedAt.Add(time.Minute)},
+			},
+		},
+		{
+			name: "batch keeps other characters",
+			saves: [][]character.State{
+				{{UserID: "alice", X: 1, Y: 2, UpdatedAt: savedAt}},
+				{
+					{UserID: "bob", X: 3, Y: 4, UpdatedAt: savedAt},
+					{UserID: "carol", X: 5, Y: 6, UpdatedAt: savedAt},
+				},
+			},
+			want: map[string]character.State{
+				"alice": {UserID: "alice", X: 1, Y: 2, UpdatedAt: savedAt},
+				"bob":   {UserID: "bob", X: 3, Y: 4, UpdatedAt: savedAt},
+				"carol": {UserID: "carol", X: 5, Y: 6, UpdatedAt: savedAt},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "data", "characters.json")
+			repo, err := NewCharacterRepository(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, states := range tt.saves {
+				if err := repo.SaveMany(ctx, states); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// A fresh repository must read back what the first one wrote.
+			reopened, err := NewCharacterRepository(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for id, want := range tt.want {
+				got, ok, err := reopened.Get(ctx, id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !ok {
+					t.Fatalf("character %s was not persisted", id)
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Fatalf("character %s = %+v, want %+v", id, got, want)
+				}
+			}
+			if _, ok, _ := reopened.Get(ctx, "nobody"); ok {
+				t.Fatal("found a character that was never saved")
+			}
+		})
+	}
+}
+
+func TestCharacterRepositoryKeepsStateOnFailedSave(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "characters.json")
+	repo, err := NewCharacterRepository(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Save(ctx, character.State{UserID: "alice", X: 1, Y: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A directory where the temporary file should go makes the write fail.
+	if err := os.Mkdir(path+".tmp", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	err = repo.SaveMany(ctx, []character.State{
+		{UserID: "alice", X: 9, Y: 9},
+		{UserID: "bob", X: 3, Y: 4},
+	})
+	if err == nil {
+		t.Fatal("saved over a blocked temporary file")
+	}
+
+	if got, _, _ := repo.Get(ctx, "alice"); got.X != 1 || got.Y != 2 {
+		t.Fatalf("alice = %+v after a failed save, want the previous state", got)
+	}
+	if _, ok, _ := repo.Get(ctx, "bob"); ok {
+		t.Fatal("bob was kept after a failed save")
+	}
+}
+
+func TestNewCharacterRepositoryRejectsCorruptFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "characters.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewCharacterRepository(path); err == nil {
+		t.Fatal("loaded a corrupt character file")
+	}
+}
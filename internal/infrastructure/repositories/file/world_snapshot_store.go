@@ -0,0 +1,122 @@
+package file
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	snapshotPrefix = "world-"
+	snapshotExt    = ".json"
+)
+
+// DefaultSnapshotsKept is how many snapshot files a WorldSnapshotStore keeps
+// when not told otherwise.
+const DefaultSnapshotsKept = 3
+
+// WorldSnapshotStore writes encoded world snapshots to a directory, one file
+// per snapshot named after the time it was taken, and keeps the newest few so
+// a damaged file can fall back to the one before it. Encoding is left to the
+// caller.
+type WorldSnapshotStore struct {
+	mu   sync.Mutex
+	dir  string
+	keep int
+}
+
+func NewWorldSnapshotStore(dir string, keep int) *WorldSnapshotStore {
+	if keep <= 0 {
+		keep = DefaultSnapshotsKept
+	}
+
+	return &WorldSnapshotStore{dir: dir, keep: keep}
+}
+
+// Save atomically writes data as the snapshot taken at savedAt and prunes the
+// oldest files.
+func (s *WorldSnapshotStore) Save(savedAt time.Time, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return err
+	}
+
+	path := filepath.Join(s.dir, fmt.Sprintf("%s%020d%s", snapshotPrefix, savedAt.UnixNano(), snapshotExt))
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+
+	paths, err := s.pathsLocked()
+	if err != nil {
+		return err
+	}
+	for _, old := range paths[min(s.keep, len(paths)):] {
+		if err := os.Remove(old); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Latest hands the stored snapshots to decode, newest first, and returns the
+// file of the first one it accepts. Unreadable files and ones decode rejects,
+// such as damaged or outdated snapshots, are skipped.
+func (s *WorldSnapshotStore) Latest(decode func(data []byte) error) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	paths, err := s.pathsLocked()
+	if err != nil {
+		return "", false, err
+	}
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if err := decode(data); err != nil {
+			continue
+		}
+
+		return path, true, nil
+	}
+
+	return "", false, nil
+}
+
+// pathsLocked lists the snapshot files, newest first.
+func (s *WorldSnapshotStore) pathsLocked() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, snapshotPrefix) || !strings.HasSuffix(name, snapshotExt) {
+			continue
+		}
+		paths = append(paths, filepath.Join(s.dir, name))
+	}
+	// Names hold zero-padded timestamps, so they sort chronologically.
+	sort.Sort(sort.Reverse(sort.StringSlice(paths)))
+
+	return paths, nil
+}
@@ -0,0 +1,81 @@
+package file
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWorldSnapshotStoreKeepsNewest(t *testing.T) {
+	dir := t.TempDir()
+	store := NewWorldSnapshotStore(dir, 2)
+	savedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	for i, data := range []string{"first", "second", "third"} {
+		if err := store.Save(savedAt.Add(time.Duration(i)*time.Minute), []byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("store holds %d files, want 2", len(entries))
+	}
+
+	var got string
+	if _, ok, err := store.Latest(func(data []byte) error {
+		got = string(data)
+		return nil
+	}); err != nil || !ok {
+		t.Fatalf("Latest() = %v, %v, want a snapshot", ok, err)
+	}
+	if got != "third" {
+		t.Fatalf("latest snapshot %q, want %q", got, "third")
+	}
+}
+
+func TestWorldSnapshotStoreSkipsRejectedSnapshots(t *testing.T) {
+	store := NewWorldSnapshotStore(t.TempDir(), 0)
+	savedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	for i, data := range []string{"good", "damaged"} {
+		if err := store.Save(savedAt.Add(time.Duration(i)*time.Minute), []byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var tried []string
+	path, ok, err := store.Latest(func(data []byte) error {
+		tried = append(tried, string(data))
+		if string(data) == "damaged" {
+			return errors.New("damaged")
+		}
+		return nil
+	})
+	if err != nil || !ok {
+		t.Fatalf("Latest() = %v, %v, want a snapshot", ok, err)
+	}
+	if len(tried) != 2 || tried[0] != "damaged" || tried[1] != "good" {
+		t.Fatalf("tried %q, want the damaged snapshot before the good one", tried)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != "good" {
+		t.Fatalf("Latest() returned %s holding %q, want the good snapshot", path, data)
+	}
+}
+
+func TestWorldSnapshotStoreWithoutSnapshots(t *testing.T) {
+	store := NewWorldSnapshotStore(filepath.Join(t.TempDir(), "missing"), 0)
+
+	_, ok, err := store.Latest(func(data []byte) error {
+		t.Fatal("decode called without snapshots")
+		return nil
+	})
+	if err != nil || ok {
+		t.Fatalf("Latest() = %v, %v, want no snapshot and no error", ok, err)
+	}
+}
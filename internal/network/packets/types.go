@@ -7,6 +7,14 @@ const (
 	PacketStateSnapshot = "STATE_SNAPSHOT"
 	PacketStateDelta    = "STATE_DELTA"
 	PacketWelcome       = "WELCOME"
+	PacketInteract      = "INTERACT"
+	PacketObjectUpdate  = "OBJECT_UPDATE"
+	PacketMapChange     = "MAP_CHANGE"
+	PacketMapReload     = "MAP_RELOAD"
+	PacketRunToggle     = "RUN_TOGGLE"
+	PacketMovement      = "MOVEMENT"
+	PacketRegion        = "REGION"
+	PacketTileChange    = "TILE_CHANGE"
 )
 
 type Packet struct {
@@ -19,25 +27,103 @@ type MoveIntent struct {
 	Y int `json:"y"`
 }
 
-type PlayerState struct {
-	ID string `json:"id"`
-	X  int    `json:"x"`
-	Y  int    `json:"y"`
+// Interact asks the server to walk next to an object and act on it.
+type Interact struct {
+	TargetID string `json:"targetId"`
+	Action   string `json:"action"`
+}
+
+// RunToggle switches the player between walking and running.
+type RunToggle struct {
+	Run bool `json:"run"`
+}
+
+// EntityState is the network view of an entity. Players are identified by
+// their user id, other entities by an opaque id, and Kind tells them apart.
+// Type and State are only set for NPCs and objects. Running and RunEnergy
+// (a whole percentage) are only sent to the player they belong to.
+type EntityState struct {
+	ID        string `json:"id"`
+	Kind      string `json:"kind"`
+	Type      string `json:"type,omitempty"`
+	State     string `json:"state,omitempty"`
+	X         int    `json:"x"`
+	Y         int    `json:"y"`
+	Running   bool   `json:"running,omitempty"`
+	RunEnergy int    `json:"runEnergy,omitempty"`
 }
 
 type StateSnapshot struct {
-	Tick    int64         `json:"tick"`
-	Players []PlayerState `json:"players"`
+	Tick     int64         `json:"tick"`
+	Entities []EntityState `json:"entities"`
 }
 
 type StateDelta struct {
-	Tick    int64         `json:"tick"`
-	Players []PlayerState `json:"players"`
-	Removed []string      `json:"removed"`
+	Tick     int64         `json:"tick"`
+	Entities []EntityState `json:"entities"`
+	Removed  []string      `json:"removed"`
+}
+
+// ObjectUpdate announces an object changing state. ActorID and Action are
+// empty when the object respawned on its own.
+type ObjectUpdate struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	State   string `json:"state"`
+	ActorID string `json:"actorId,omitempty"`
+	Action  string `json:"action,omitempty"`
+}
+
+// Movement is the path an entity started on StartTick. Clients walk it at
+// Speed (scaled units per second) through Waypoints, correcting only when
+// the state updates drift too far from it. Cancelled means the entity
+// stopped and carries no waypoints.
+type Movement struct {
+	ID        string     `json:"id"`
+	Waypoints []Waypoint `json:"waypoints,omitempty"`
+	StartTick int64      `json:"startTick"`
+	Speed     float64    `json:"speed"`
+	Cancelled bool       `json:"cancelled,omitempty"`
+}
+
+type Waypoint struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// Region tells a player it entered or left a region. Flags lists the
+// region's boolean properties that are set, such as "safe" or "pvp".
+type Region struct {
+	Name       string         `json:"name"`
+	Entered    bool           `json:"entered"`
+	Flags      []string       `json:"flags,omitempty"`
+	Properties map[string]any `json:"properties,omitempty"`
 }
 
 type Welcome struct {
-	ID string `json:"id"`
+	ID  string `json:"id"`
+	Map string `json:"map"`
+}
+
+// MapChange tells the client its player moved to another map, which it
+// should fetch from /map?name=<map> before applying the snapshot that
+// follows.
+type MapChange struct {
+	Map string `json:"map"`
+}
+
+// MapReload tells the client the map it is on was replaced: it should
+// refetch /map?name=<map> before applying the snapshot that follows.
+type MapReload struct {
+	Map string `json:"map"`
+}
+
+// TileChange tells the client one tile of the map it is on changed type.
+type TileChange struct {
+	Map  string `json:"map"`
+	X    int    `json:"x"`
+	Y    int    `json:"y"`
+	Tile int    `json:"tile"`
 }
 
 func NewPacket(packetType string, payload any) (Packet, error) {
@@ -1,6 +1,7 @@
 package websocket
 
 import (
+	"context"
 	"encoding/json"
 	"log"
 	"net/http"
@@ -11,6 +12,7 @@ import (
 	"github.com/gorilla/websocket"
 
 	appauth "github.com/felipemalacarne/etheria/internal/app/auth"
+	"github.com/felipemalacarne/etheria/internal/domain/character"
 	"github.com/felipemalacarne/etheria/internal/game/engine"
 	"github.com/felipemalacarne/etheria/internal/network/packets"
 )
@@ -18,7 +20,7 @@ import (
 const (
 	writeTimeout   = 5 * time.Second
 	sendBuffer     = 16
-	ChunkSizeTiles = 8
+	ChunkSizeTiles = engine.DefaultChunkSizeTiles
 	ChunkRadius    = 1
 )
 
@@ -31,8 +33,9 @@ var upgrader = websocket.Upgrader{
 }
 
 type Server struct {
-	world         *engine.World
+	worlds        *engine.WorldManager
 	auth          *appauth.Service
+	characters    character.Repository
 	clients       map[*client]struct{}
 	clientsByUser map[string]*client
 	mu            sync.RWMutex
@@ -45,20 +48,42 @@ type client struct {
 	send      chan packets.Packet
 	closeOnce sync.Once
 	mu        sync.Mutex
-	lastSent  map[string]packets.PlayerState
+	closed    bool
+	lastSent  map[string]packets.EntityState
 }
 
 func (c *client) close() {
 	c.closeOnce.Do(func() {
+		c.mu.Lock()
+		c.closed = true
 		close(c.send)
+		c.mu.Unlock()
 		_ = c.conn.Close()
 	})
 }
 
-func NewServer(world *engine.World, authManager *appauth.Service) *Server {
+// queue adds a packet to the send buffer without blocking. It reports false
+// when the buffer is full or the client was closed.
+func (c *client) queue(packet packets.Packet) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return false
+	}
+	select {
+	case c.send <- packet:
+		return true
+	default:
+		return false
+	}
+}
+
+func NewServer(worlds *engine.WorldManager, authManager *appauth.Service, characters character.Repository) *Server {
 	return &Server{
-		world:         world,
+		worlds:        worlds,
 		auth:          authManager,
+		characters:    characters,
 		clients:       make(map[*client]struct{}),
 		clientsByUser: make(map[string]*client),
 	}
@@ -90,30 +115,211 @@ func (s *Server) HandleWS(w http.ResponseWriter, r *http.Request) {
 	go s.readLoop(client)
 }
 
+// BroadcastState sends tile changes, deltas and object updates to the clients
+// on every map whose world changed since the last broadcast. Clients on a
+// reloaded map are told to refetch it and get a full snapshot instead.
 func (s *Server) BroadcastState(tick int64) {
 	atomic.StoreInt64(&s.lastTick, tick)
 
-	s.mu.RLock()
-	clients := make([]*client, 0, len(s.clients))
-	for client := range s.clients {
-		clients = append(clients, client)
+	dirty := make(map[string]*engine.World)
+	updates := make(map[string][]engine.ObjectUpdate)
+	paths := make(map[string][]engine.PlannedPath)
+	tiles := make(map[string][]engine.TileUpdate)
+	var regionEvents []engine.RegionEvent
+	reloaded := make(map[string]bool)
+	for _, name := range s.worlds.Names() {
+		world, _ := s.worlds.World(name)
+		if world.DrainMapReload() {
+			reloaded[name] = true
+		}
+		if drained := world.DrainTileUpdates(); len(drained) > 0 {
+			tiles[name] = drained
+		}
+		if world.DrainDirty() {
+			dirty[name] = world
+		}
+		if drained := world.DrainObjectUpdates(); len(drained) > 0 {
+			updates[name] = drained
+		}
+		if drained := world.DrainPathUpdates(); len(drained) > 0 {
+			paths[name] = drained
+		}
+		regionEvents = append(regionEvents, world.DrainRegionEvents()...)
+	}
+	s.sendRegionEvents(regionEvents)
+	if len(dirty) == 0 && len(updates) == 0 && len(paths) == 0 && len(tiles) == 0 {
+		return
 	}
-	s.mu.RUnlock()
 
-	for _, client := range clients {
-		s.sendDelta(client, tick)
+	for _, client := range s.snapshotClients() {
+		_, name, ok := s.worlds.PlayerWorld(client.userID)
+		if !ok {
+			continue
+		}
+		if reloaded[name] {
+			if s.sendControl(client, packets.PacketMapReload, packets.MapReload{Map: name}) {
+				s.sendSnapshot(client)
+			}
+			continue
+		}
+		// Tiles go first so the delta that follows already fits the map.
+		s.sendTileChanges(client, name, tiles[name])
+		var entered map[string]bool
+		if world, ok := dirty[name]; ok {
+			entered = s.sendDelta(client, world, tick)
+		}
+		s.sendObjectUpdates(client, updates[name])
+		s.sendPathUpdates(client, paths[name], entered)
 	}
 }
 
-func (s *Server) Close() {
+// ApplyMapChanges tells the clients of players that went through a portal
+// to load their new map and sends them a fresh snapshot of it.
+func (s *Server) ApplyMapChanges(changes []engine.MapChange) {
+	for _, change := range changes {
+		s.mu.RLock()
+		client, ok := s.clientsByUser[change.PlayerID]
+		s.mu.RUnlock()
+		if !ok {
+			continue
+		}
+
+		if !s.sendControl(client, packets.PacketMapChange, packets.MapChange{Map: change.To}) {
+			continue
+		}
+		s.sendSnapshot(client)
+	}
+}
+
+func (s *Server) snapshotClients() []*client {
 	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	clients := make([]*client, 0, len(s.clients))
 	for client := range s.clients {
 		clients = append(clients, client)
 	}
-	s.mu.RUnlock()
 
-	for _, client := range clients {
+	return clients
+}
+
+// sendTileChanges forwards every tile change on the client's map; clients
+// hold the whole map, so none are filtered by range.
+func (s *Server) sendTileChanges(client *client, mapName string, tiles []engine.TileUpdate) {
+	for _, tile := range tiles {
+		s.sendPacket(client, packets.PacketTileChange, packets.TileChange{
+			Map:  mapName,
+			X:    tile.X,
+			Y:    tile.Y,
+			Tile: tile.Tile,
+		})
+	}
+}
+
+// sendObjectUpdates forwards the object updates whose object the client has
+// in range, judged by what its last state packet contained.
+func (s *Server) sendObjectUpdates(client *client, updates []engine.ObjectUpdate) {
+	for _, update := range updates {
+		id := engine.Entity{ID: update.ID, Kind: engine.EntityObject}.NetID()
+		client.mu.Lock()
+		_, inRange := client.lastSent[id]
+		client.mu.Unlock()
+		if !inRange {
+			continue
+		}
+
+		s.sendPacket(client, packets.PacketObjectUpdate, packets.ObjectUpdate{
+			ID:      id,
+			Type:    update.Type,
+			State:   string(update.State),
+			ActorID: update.Actor,
+			Action:  update.Action,
+		})
+	}
+}
+
+// sendPathUpdates forwards the planned paths of entities the client has in
+// range, judged like sendObjectUpdates. Entities in entered just came into
+// range and were sent their current path already.
+func (s *Server) sendPathUpdates(client *client, paths []engine.PlannedPath, entered map[string]bool) {
+	for _, path := range paths {
+		id := engine.Entity{ID: path.Entity, Kind: path.Kind, PlayerID: path.PlayerID}.NetID()
+		client.mu.Lock()
+		_, inRange := client.lastSent[id]
+		client.mu.Unlock()
+		if !inRange || entered[id] {
+			continue
+		}
+
+		s.sendPacket(client, packets.PacketMovement, movementPacket(id, path))
+	}
+}
+
+// sendCurrentPaths sends the paths moving entities are already following to
+// a client that just started seeing them.
+func (s *Server) sendCurrentPaths(client *client, world *engine.World, moving []engine.EntityID) {
+	if len(moving) == 0 {
+		return
+	}
+
+	for _, path := range world.CurrentPaths(moving) {
+		id := engine.Entity{ID: path.Entity, Kind: path.Kind, PlayerID: path.PlayerID}.NetID()
+		s.sendPacket(client, packets.PacketMovement, movementPacket(id, path))
+	}
+}
+
+func movementPacket(id string, path engine.PlannedPath) packets.Movement {
+	movement := packets.Movement{
+		ID:        id,
+		StartTick: path.StartTick,
+		Speed:     path.Speed,
+		Cancelled: path.Cancelled,
+	}
+	for _, waypoint := range path.Waypoints {
+		movement.Waypoints = append(movement.Waypoints, packets.Waypoint{X: waypoint.X, Y: waypoint.Y})
+	}
+
+	return movement
+}
+
+// sendRegionEvents tells each player about the regions it entered or left.
+func (s *Server) sendRegionEvents(events []engine.RegionEvent) {
+	for _, event := range events {
+		s.mu.RLock()
+		client, ok := s.clientsByUser[event.PlayerID]
+		s.mu.RUnlock()
+		if !ok {
+			continue
+		}
+
+		s.sendPacket(client, packets.PacketRegion, packets.Region{
+			Name:       event.Region.Name,
+			Entered:    event.Entered,
+			Flags:      event.Region.Flags(),
+			Properties: event.Region.Properties,
+		})
+	}
+}
+
+// SaveCharacters persists the state of every player on every map.
+func (s *Server) SaveCharacters(ctx context.Context) error {
+	now := time.Now().UTC()
+	var states []character.State
+	for _, name := range s.worlds.Names() {
+		world, _ := s.worlds.World(name)
+		for _, player := range world.SnapshotPlayers() {
+			states = append(states, characterState(player, name, now))
+		}
+	}
+	if len(states) == 0 {
+		return nil
+	}
+
+	return s.characters.SaveMany(ctx, states)
+}
+
+func (s *Server) Close() {
+	for _, client := range s.snapshotClients() {
 		s.removeClient(client)
 	}
 }
@@ -123,7 +329,7 @@ func (s *Server) newClient(conn *websocket.Conn, userID string) *client {
 		userID:   userID,
 		conn:     conn,
 		send:     make(chan packets.Packet, sendBuffer),
-		lastSent: make(map[string]packets.PlayerState),
+		lastSent: make(map[string]packets.EntityState),
 	}
 }
 
@@ -133,7 +339,7 @@ func (s *Server) addClient(client *client) {
 		delete(s.clients, existing)
 		delete(s.clientsByUser, client.userID)
 		s.mu.Unlock()
-		s.world.RemovePlayer(existing.userID)
+		s.removePlayer(existing.userID)
 		existing.close()
 		s.mu.Lock()
 	}
@@ -141,8 +347,9 @@ func (s *Server) addClient(client *client) {
 	s.clientsByUser[client.userID] = client
 	s.mu.Unlock()
 
-	s.world.AddPlayer(client.userID)
-	s.sendPacket(client, packets.PacketWelcome, packets.Welcome{ID: client.userID})
+	mapName, position := s.loadCharacter(client.userID)
+	mapName = s.worlds.AddPlayer(client.userID, mapName, position)
+	s.sendPacket(client, packets.PacketWelcome, packets.Welcome{ID: client.userID, Map: mapName})
 	s.sendSnapshot(client)
 }
 
@@ -158,10 +365,46 @@ func (s *Server) removeClient(client *client) {
 	}
 	s.mu.Unlock()
 
-	s.world.RemovePlayer(client.userID)
+	s.removePlayer(client.userID)
 	client.close()
 }
 
+func (s *Server) removePlayer(userID string) {
+	player, mapName, ok := s.worlds.RemovePlayer(userID)
+	if !ok {
+		return
+	}
+
+	if err := s.characters.Save(context.Background(), characterState(player, mapName, time.Now().UTC())); err != nil {
+		log.Printf("character save failed (%s): %v", userID, err)
+	}
+}
+
+// loadCharacter returns the saved map and position of a user, or an empty
+// map name and nil position for new characters.
+func (s *Server) loadCharacter(userID string) (string, *engine.Position) {
+	state, ok, err := s.characters.Get(context.Background(), userID)
+	if err != nil {
+		log.Printf("character load failed (%s): %v", userID, err)
+		return "", nil
+	}
+	if !ok {
+		return "", nil
+	}
+
+	return state.Map, &engine.Position{X: state.X, Y: state.Y}
+}
+
+func characterState(player engine.Player, mapName string, now time.Time) character.State {
+	return character.State{
+		UserID:    player.ID,
+		Map:       mapName,
+		X:         player.X,
+		Y:         player.Y,
+		UpdatedAt: now,
+	}
+}
+
 func (s *Server) readLoop(client *client) {
 	defer s.removeClient(client)
 
@@ -202,7 +445,47 @@ func (s *Server) handlePacket(client *client, packet packets.Packet) {
 			log.Printf("invalid move intent (%s): %v", client.userID, err)
 			return
 		}
-		if ok := s.world.SetPlayerTarget(client.userID, intent.X, intent.Y); !ok {
+		if _, ok := s.worlds.EnqueueCommand(engine.Command{
+			PlayerID: client.userID,
+			Type:     engine.CommandMoveTo,
+			X:        intent.X,
+			Y:        intent.Y,
+		}); !ok {
+			log.Printf("input queue full, dropping move intent (%s)", client.userID)
+			return
+		}
+	case packets.PacketInteract:
+		var interact packets.Interact
+		if err := json.Unmarshal(packet.Payload, &interact); err != nil {
+			log.Printf("invalid interact (%s): %v", client.userID, err)
+			return
+		}
+		target, ok := engine.ParseNetID(interact.TargetID)
+		if !ok {
+			log.Printf("invalid interact target (%s): %q", client.userID, interact.TargetID)
+			return
+		}
+		if _, ok := s.worlds.EnqueueCommand(engine.Command{
+			PlayerID: client.userID,
+			Type:     engine.CommandInteract,
+			Target:   target,
+			Action:   interact.Action,
+		}); !ok {
+			log.Printf("input queue full, dropping interact (%s)", client.userID)
+			return
+		}
+	case packets.PacketRunToggle:
+		var toggle packets.RunToggle
+		if err := json.Unmarshal(packet.Payload, &toggle); err != nil {
+			log.Printf("invalid run toggle (%s): %v", client.userID, err)
+			return
+		}
+		if _, ok := s.worlds.EnqueueCommand(engine.Command{
+			PlayerID: client.userID,
+			Type:     engine.CommandRun,
+			Run:      toggle.Run,
+		}); !ok {
+			log.Printf("input queue full, dropping run toggle (%s)", client.userID)
 			return
 		}
 	default:
@@ -216,64 +499,90 @@ func (s *Server) sendPacket(client *client, packetType string, payload any) {
 		return
 	}
 
-	select {
-	case client.send <- packet:
-	default:
+	client.queue(packet)
+}
+
+// sendControl sends a packet the client cannot recover from missing, such as
+// a map change or a snapshot. If the send buffer is full the client is
+// disconnected instead, so it reconnects and starts from a fresh snapshot.
+func (s *Server) sendControl(client *client, packetType string, payload any) bool {
+	packet, err := packets.NewPacket(packetType, payload)
+	if err != nil {
+		log.Printf("packet encode failed (%s): %v", client.userID, err)
+		return false
 	}
+	if client.queue(packet) {
+		return true
+	}
+
+	log.Printf("send buffer full, disconnecting (%s): %s", client.userID, packetType)
+	s.removeClient(client)
+	return false
 }
 
 func (s *Server) sendSnapshot(client *client) {
 	tick := atomic.LoadInt64(&s.lastTick)
-	players, ok := s.world.SnapshotPlayersInChunkRadius(client.userID, ChunkRadius, ChunkSizeTiles)
+	world, _, ok := s.worlds.PlayerWorld(client.userID)
+	if !ok {
+		return
+	}
+	entities, ok := world.SnapshotEntitiesInChunkRadius(client.userID, ChunkRadius, ChunkSizeTiles)
 	if !ok {
 		return
 	}
 
-	statePlayers := make([]packets.PlayerState, 0, len(players))
-	nextSent := make(map[string]packets.PlayerState, len(players))
+	states := make([]packets.EntityState, 0, len(entities))
+	nextSent := make(map[string]packets.EntityState, len(entities))
+	var moving []engine.EntityID
 
-	for _, player := range players {
-		state := packets.PlayerState{
-			ID: player.ID,
-			X:  player.X,
-			Y:  player.Y,
+	for _, entity := range entities {
+		state := entityState(entity, client.userID)
+		states = append(states, state)
+		nextSent[state.ID] = state
+		if entity.Movement != nil && entity.Movement.Path != nil {
+			moving = append(moving, entity.ID)
 		}
-		statePlayers = append(statePlayers, state)
-		nextSent[player.ID] = state
 	}
 
 	client.mu.Lock()
 	client.lastSent = nextSent
 	client.mu.Unlock()
 
-	s.sendPacket(client, packets.PacketStateSnapshot, packets.StateSnapshot{
-		Tick:    tick,
-		Players: statePlayers,
-	})
+	if s.sendControl(client, packets.PacketStateSnapshot, packets.StateSnapshot{
+		Tick:     tick,
+		Entities: states,
+	}) {
+		s.sendCurrentPaths(client, world, moving)
+	}
 }
 
-func (s *Server) sendDelta(client *client, tick int64) {
-	players, ok := s.world.SnapshotPlayersInChunkRadius(client.userID, ChunkRadius, ChunkSizeTiles)
+// sendDelta sends the entities that changed since the client's last state
+// packet, followed by the paths of the moving ones that just came into range,
+// and returns those.
+func (s *Server) sendDelta(client *client, world *engine.World, tick int64) map[string]bool {
+	entities, ok := world.SnapshotEntitiesInChunkRadius(client.userID, ChunkRadius, ChunkSizeTiles)
 	if !ok {
-		return
+		return nil
 	}
 
-	statePlayers := make([]packets.PlayerState, 0, len(players))
-	nextSent := make(map[string]packets.PlayerState, len(players))
+	states := make([]packets.EntityState, 0, len(entities))
+	nextSent := make(map[string]packets.EntityState, len(entities))
+	entered := make(map[string]bool)
+	var moving []engine.EntityID
 
 	client.mu.Lock()
 	prevSent := client.lastSent
-	for _, player := range players {
-		state := packets.PlayerState{
-			ID: player.ID,
-			X:  player.X,
-			Y:  player.Y,
-		}
-		nextSent[player.ID] = state
+	for _, entity := range entities {
+		state := entityState(entity, client.userID)
+		nextSent[state.ID] = state
 
-		prev, ok := prevSent[player.ID]
-		if !ok || prev.X != state.X || prev.Y != state.Y {
-			statePlayers = append(statePlayers, state)
+		prev, ok := prevSent[state.ID]
+		if !ok || prev != state {
+			states = append(states, state)
+		}
+		if !ok && entity.Movement != nil && entity.Movement.Path != nil {
+			entered[state.ID] = true
+			moving = append(moving, entity.ID)
 		}
 	}
 
@@ -284,17 +593,39 @@ func (s *Server) sendDelta(client *client, tick int64) {
 		}
 	}
 
-	if len(statePlayers) == 0 && len(removed) == 0 {
+	if len(states) == 0 && len(removed) == 0 {
 		client.mu.Unlock()
-		return
+		return nil
 	}
 
 	client.lastSent = nextSent
 	client.mu.Unlock()
 
 	s.sendPacket(client, packets.PacketStateDelta, packets.StateDelta{
-		Tick:    tick,
-		Players: statePlayers,
-		Removed: removed,
+		Tick:     tick,
+		Entities: states,
+		Removed:  removed,
 	})
+	s.sendCurrentPaths(client, world, moving)
+
+	return entered
+}
+
+// entityState converts an entity for the client of userID, which alone sees
+// its own run state.
+func entityState(entity engine.Entity, userID string) packets.EntityState {
+	state := packets.EntityState{
+		ID:    entity.NetID(),
+		Kind:  string(entity.Kind),
+		Type:  entity.Type,
+		State: string(entity.State),
+		X:     entity.X,
+		Y:     entity.Y,
+	}
+	if entity.Kind == engine.EntityPlayer && entity.PlayerID == userID && entity.Movement != nil {
+		state.Running = entity.Movement.Running
+		state.RunEnergy = entity.Movement.RunEnergy / 100
+	}
+
+	return state
 }
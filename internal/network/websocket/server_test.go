@@ -0,0 +1,209 @@
+package websocket
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/felipemalacarne/etheria/internal/game/engine"
+	"github.com/felipemalacarne/etheria/internal/network/packets"
+)
+
+// testServer hosts one corridor map with alice at its west end, watched
+// through a client that is never connected.
+func testServer(t *testing.T, width int) (*Server, *client, *engine.World) {
+	t.Helper()
+
+	worlds := engine.NewWorldManager()
+	world, err := worlds.AddMap("town", engine.MapData{Width: width, Height: 1, Tiles: [][]int{make([]int, width)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	worlds.AddPlayer("alice", "town", &engine.Position{X: tileCenter(0), Y: tileCenter(0)})
+
+	s := NewServer(worlds, nil, nil)
+	c := &client{userID: "alice", send: make(chan packets.Packet, 1024), lastSent: make(map[string]packets.EntityState)}
+	s.clients[c] = struct{}{}
+	s.clientsByUser[c.userID] = c
+
+	return s, c, world
+}
+
+func tileCenter(tile int) int {
+	return (tile*32 + 16) * engine.PositionScale
+}
+
+// drainMovements returns the movement packets queued for c, by entity id,
+// and whether a state packet mentioned id.
+func drainMovements(t *testing.T, c *client, id string) (map[string]packets.Movement, bool) {
+	t.Helper()
+
+	movements := make(map[string]packets.Movement)
+	seen := false
+	for {
+		select {
+		case packet := <-c.send:
+			switch packet.Type {
+			case packets.PacketMovement:
+				var movement packets.Movement
+				if err := json.Unmarshal(packet.Payload, &movement); err != nil {
+					t.Fatal(err)
+				}
+				movements[movement.ID] = movement
+			case packets.PacketStateSnapshot, packets.PacketStateDelta:
+				var state struct {
+					Entities []packets.EntityState `json:"entities"`
+				}
+				if err := json.Unmarshal(packet.Payload, &state); err != nil {
+					t.Fatal(err)
+				}
+				for _, entity := range state.Entities {
+					seen = seen || entity.ID == id
+				}
+			}
+		default:
+			return movements, seen
+		}
+	}
+}
+
+func TestEntitiesEnteringViewGetTheirPath(t *testing.T) {
+	tests := []struct {
+		name string
+		// bobStart is where bob starts walking towards alice from.
+		bobStart int
+		// snapshot sends a full snapshot after bob has started walking
+		// instead of waiting for his deltas.
+		snapshot bool
+	}{
+		{name: "walks into range", bobStart: 39},
+		{name: "in range on the first snapshot", bobStart: 10, snapshot: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, c, world := testServer(t, 40)
+			world.AddPlayer("bob", &engine.Position{X: tileCenter(tt.bobStart), Y: tileCenter(0)})
+			world.SetPlayerTarget("bob", tileCenter(1), tileCenter(0))
+			world.DrainPathUpdates()
+
+			var movements map[string]packets.Movement
+			seen := false
+			if tt.snapshot {
+				world.Step(0.05)
+				s.sendSnapshot(c)
+				movements, seen = drainMovements(t, c, "bob")
+			} else {
+				s.sendSnapshot(c)
+				if _, seen := drainMovements(t, c, "bob"); seen {
+					t.Fatal("bob is in range before walking")
+				}
+				for tick := int64(1); tick < 400 && !seen; tick += 1 {
+					s.worlds.Step(0.05)
+					s.BroadcastState(tick)
+					movements, seen = drainMovements(t, c, "bob")
+				}
+			}
+
+			if !seen {
+				t.Fatal("bob never came into range")
+			}
+			movement, ok := movements["bob"]
+			if !ok {
+				t.Fatal("bob came into range without his path")
+			}
+			if movement.Cancelled || len(movement.Waypoints) < 2 {
+				t.Fatalf("bob's path = %+v, want the rest of his walk", movement)
+			}
+			if last := movement.Waypoints[len(movement.Waypoints)-1]; last.X != tileCenter(1) {
+				t.Fatalf("bob's path ends at x=%d, want next to alice", last.X)
+			}
+		})
+	}
+}
+
+func TestPathUpdatesOnlyReachClientsInRange(t *testing.T) {
+	s, c, world := testServer(t, 40)
+	world.AddPlayer("bob", &engine.Position{X: tileCenter(39), Y: tileCenter(0)})
+	world.AddPlayer("carol", &engine.Position{X: tileCenter(2), Y: tileCenter(0)})
+	s.sendSnapshot(c)
+	drainMovements(t, c, "")
+
+	world.SetPlayerTarget("bob", tileCenter(30), tileCenter(0))
+	world.SetPlayerTarget("carol", tileCenter(5), tileCenter(0))
+	s.worlds.Step(0.05)
+	s.BroadcastState(1)
+
+	movements, _ := drainMovements(t, c, "")
+	if _, ok := movements["bob"]; ok {
+		t.Fatal("sent the path of an entity out of range")
+	}
+	if _, ok := movements["carol"]; !ok {
+		t.Fatal("did not send the path of an entity in range")
+	}
+}
+
+// drainTypes returns the types of the packets queued for c, in order, and
+// the tile changes among them.
+func drainTypes(t *testing.T, c *client) ([]string, []packets.TileChange) {
+	t.Helper()
+
+	var types []string
+	var tiles []packets.TileChange
+	for {
+		select {
+		case packet := <-c.send:
+			types = append(types, packet.Type)
+			if packet.Type == packets.PacketTileChange {
+				var tile packets.TileChange
+				if err := json.Unmarshal(packet.Payload, &tile); err != nil {
+					t.Fatal(err)
+				}
+				tiles = append(tiles, tile)
+			}
+		default:
+			return types, tiles
+		}
+	}
+}
+
+func TestTileChangesReachClients(t *testing.T) {
+	s, c, world := testServer(t, 10)
+	s.BroadcastState(world.Tick())
+	drainTypes(t, c)
+
+	if err := world.SetTile(5, 0, engine.TileWall); err != nil {
+		t.Fatal(err)
+	}
+	world.Step(0.05)
+	s.BroadcastState(world.Tick())
+
+	types, tiles := drainTypes(t, c)
+	want := packets.TileChange{Map: "town", X: 5, Y: 0, Tile: engine.TileWall}
+	if len(tiles) != 1 || tiles[0] != want {
+		t.Fatalf("tile changes %+v, want %+v", tiles, want)
+	}
+	for _, packetType := range types {
+		if packetType == packets.PacketMapReload {
+			t.Fatal("a tile change made the client refetch the map")
+		}
+	}
+
+	// Clients refetch a reloaded map, so tile changes from before the
+	// reload are not sent.
+	if err := world.SetTile(6, 0, engine.TileWall); err != nil {
+		t.Fatal(err)
+	}
+	if err := world.ReloadMap(engine.MapData{Width: 10, Height: 1, Tiles: [][]int{make([]int, 10)}}); err != nil {
+		t.Fatal(err)
+	}
+	world.Step(0.05)
+	s.BroadcastState(world.Tick())
+
+	types, tiles = drainTypes(t, c)
+	if len(tiles) != 0 {
+		t.Fatalf("tile changes %+v sent along with a reload", tiles)
+	}
+	if len(types) == 0 || types[0] != packets.PacketMapReload {
+		t.Fatalf("packets %v, want a map reload first", types)
+	}
+}
@@ -33,7 +33,8 @@ const PhaserGame = dynamic(() => import("@/components/game/PhaserGame"), {
 export default function PlayPage() {
   const router = useRouter();
   const { token, user, clearSession } = useAuthStore();
-  const { hp, maxHp, isConnected, playerId } = useGameStore();
+  const { hp, maxHp, runEnergy, running, regions, isConnected, playerId } =
+    useGameStore();
   const { players } = usePlayers({ enabled: Boolean(token), token });
 
   useEffect(() => {
@@ -89,8 +90,8 @@ export default function PlayPage() {
             playerClass="Web Dev Warrior"
             customStats={[
               {
-                label: "Stamina",
-                value: 72,
+                label: running ? "Run energy (running)" : "Run energy",
+                value: runEnergy,
                 max: 100,
                 color: "bg-green-500",
               },
@@ -113,6 +114,18 @@ export default function PlayPage() {
             <div className="mt-1 text-muted-foreground">
               Player ID: {playerId ?? "—"}
             </div>
+            <div className="mt-1 text-muted-foreground">
+              Region:{" "}
+              {regions.length > 0
+                ? regions
+                    .map((region) =>
+                      region.flags.length > 0
+                        ? `${region.name} (${region.flags.join(", ")})`
+                        : region.name,
+                    )
+                    .join(" / ")
+                : "Wilderness"}
+            </div>
           </Card>
         </div>
 
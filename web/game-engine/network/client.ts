@@ -1,11 +1,27 @@
 import {
+  Interact,
+  MapChange,
+  MapReload,
+  Movement,
+  ObjectUpdate,
+  Region,
   Packet,
+  PacketInteract,
+  PacketMapChange,
+  PacketMapReload,
+  PacketMovement,
   PacketMoveIntent,
+  PacketObjectUpdate,
+  PacketRegion,
+  PacketRunToggle,
   PacketStateDelta,
   PacketStateSnapshot,
+  PacketTileChange,
   PacketWelcome,
+  RunToggle,
   StateDelta,
   StateSnapshot,
+  TileChange,
   Welcome,
 } from "./packets";
 
@@ -13,6 +29,12 @@ type NetworkHandlers = {
   onStateSnapshot?: (snapshot: StateSnapshot) => void;
   onStateDelta?: (delta: StateDelta) => void;
   onWelcome?: (welcome: Welcome) => void;
+  onObjectUpdate?: (update: ObjectUpdate) => void;
+  onMapChange?: (change: MapChange) => void;
+  onMapReload?: (reload: MapReload) => void;
+  onMovement?: (movement: Movement) => void;
+  onRegion?: (region: Region) => void;
+  onTileChange?: (change: TileChange) => void;
   onConnectionChange?: (connected: boolean) => void;
 };
 
@@ -67,6 +89,24 @@ export class NetworkClient {
         case PacketWelcome:
           this.handlers.onWelcome?.(packet.payload as Welcome);
           break;
+        case PacketObjectUpdate:
+          this.handlers.onObjectUpdate?.(packet.payload as ObjectUpdate);
+          break;
+        case PacketMapChange:
+          this.handlers.onMapChange?.(packet.payload as MapChange);
+          break;
+        case PacketMapReload:
+          this.handlers.onMapReload?.(packet.payload as MapReload);
+          break;
+        case PacketMovement:
+          this.handlers.onMovement?.(packet.payload as Movement);
+          break;
+        case PacketRegion:
+          this.handlers.onRegion?.(packet.payload as Region);
+          break;
+        case PacketTileChange:
+          this.handlers.onTileChange?.(packet.payload as TileChange);
+          break;
         default:
           break;
       }
@@ -86,6 +126,14 @@ export class NetworkClient {
     this.send(PacketMoveIntent, { x, y });
   }
 
+  sendInteract(targetId: string, action: string) {
+    this.send<Interact>(PacketInteract, { targetId, action });
+  }
+
+  sendRunToggle(run: boolean) {
+    this.send<RunToggle>(PacketRunToggle, { run });
+  }
+
   private send<T>(type: string, payload: T) {
     if (!this.socket || this.socket.readyState !== WebSocket.OPEN) {
       return;
@@ -2,6 +2,14 @@ export const PacketMoveIntent = "MOVE_INTENT";
 export const PacketStateSnapshot = "STATE_SNAPSHOT";
 export const PacketStateDelta = "STATE_DELTA";
 export const PacketWelcome = "WELCOME";
+export const PacketInteract = "INTERACT";
+export const PacketObjectUpdate = "OBJECT_UPDATE";
+export const PacketMapChange = "MAP_CHANGE";
+export const PacketMapReload = "MAP_RELOAD";
+export const PacketRunToggle = "RUN_TOGGLE";
+export const PacketMovement = "MOVEMENT";
+export const PacketRegion = "REGION";
+export const PacketTileChange = "TILE_CHANGE";
 export const POSITION_SCALE = 100;
 
 export type Packet<T = unknown> = {
@@ -14,23 +22,86 @@ export type MoveIntent = {
   y: number;
 };
 
-export type PlayerState = {
+export type Interact = {
+  targetId: string;
+  action: string;
+};
+
+export type RunToggle = {
+  run: boolean;
+};
+
+export type EntityKind = "player" | "npc" | "object";
+
+export type ObjectState = "available" | "depleted";
+
+export type EntityState = {
   id: string;
+  kind: EntityKind;
+  type?: string;
+  state?: ObjectState;
   x: number;
   y: number;
+  // Only set on the local player's own entity.
+  running?: boolean;
+  runEnergy?: number;
+};
+
+export type ObjectUpdate = {
+  id: string;
+  type: string;
+  state: ObjectState;
+  actorId?: string;
+  action?: string;
 };
 
 export type StateSnapshot = {
   tick: number;
-  players: PlayerState[];
+  entities: EntityState[];
 };
 
 export type StateDelta = {
   tick: number;
-  players: PlayerState[];
+  entities: EntityState[];
   removed: string[];
 };
 
+export type Waypoint = {
+  x: number;
+  y: number;
+};
+
+export type Movement = {
+  id: string;
+  waypoints?: Waypoint[];
+  startTick: number;
+  speed: number;
+  cancelled?: boolean;
+};
+
+export type Region = {
+  name: string;
+  entered: boolean;
+  flags?: string[];
+  properties?: Record<string, unknown>;
+};
+
 export type Welcome = {
   id: string;
+  map: string;
+};
+
+export type MapChange = {
+  map: string;
+};
+
+export type MapReload = {
+  map: string;
+};
+
+export type TileChange = {
+  map: string;
+  x: number;
+  y: number;
+  tile: number;
 };
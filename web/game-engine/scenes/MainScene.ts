@@ -1,26 +1,71 @@
 import { Scene } from "phaser";
 import { NetworkClient } from "@/game-engine/network/client";
 import {
-  PlayerState,
+  EntityKind,
+  EntityState,
+  MapChange,
+  MapReload,
+  Movement,
+  ObjectUpdate,
   POSITION_SCALE,
   StateDelta,
   StateSnapshot,
+  TileChange,
   Welcome,
 } from "@/game-engine/network/packets";
 import { getApiBaseUrl, getWsBaseUrl } from "@/lib/config";
 import { authStoreApi } from "@/store/authStore";
 import { gameStoreApi } from "@/store/gameStore";
 
+type TileType = {
+  id: number;
+  name: string;
+  walkable: boolean;
+  moveCost?: number;
+  blocksSight?: boolean;
+};
+
 type SharedMap = {
   width: number;
   height: number;
   tiles: number[][];
+  diagonal?: boolean;
+  tileTypes?: TileType[];
+};
+
+const entityColors: Record<EntityKind, number> = {
+  player: 0x4d96ff,
+  npc: 0xf4b400,
+  object: 0x8d6e63,
+};
+
+// Default action sent when clicking an object of each type.
+const objectActions: Record<string, string> = {
+  tree: "chop",
+  rock: "mine",
 };
 
+// Server tick length, used to catch up on paths that started before their
+// MOVEMENT packet arrived.
+const serverTickSeconds = 0.05;
+
+type RemotePath = {
+  points: { x: number; y: number }[];
+  index: number;
+  speed: number;
+};
+
+const defaultTileTypes: TileType[] = [
+  { id: 0, name: "grass", walkable: true, moveCost: 1 },
+  { id: 1, name: "dirt", walkable: true, moveCost: 1 },
+  { id: 2, name: "wall", walkable: false, blocksSight: true },
+];
+
 export class MainScene extends Scene {
   private network: NetworkClient | null = null;
   private playerSprites = new Map<string, Phaser.GameObjects.Rectangle>();
   private playerTargets = new Map<string, { x: number; y: number }>();
+  private objects = new Map<string, EntityState>();
   private localPlayerId: string | null = null;
   private isFollowing = false;
   private playerSize = 18;
@@ -29,14 +74,23 @@ export class MainScene extends Scene {
   private mapWidth = 0;
   private mapHeight = 0;
   private mapData: number[][] = [];
+  private mapName: string | null = null;
+  private mapLayer: Phaser.Tilemaps.TilemapLayer | null = null;
+  private diagonal = false;
+  private tileTypes = new Map<number, TileType>();
+  private minMoveCost = 1;
   private localPath: { x: number; y: number }[] = [];
   private localPathIndex = 0;
   private localPredicted: { x: number; y: number } | null = null;
   private localServerPos: { x: number; y: number } | null = null;
   private interpolationSpeed = 220;
   private clientMoveSpeed = 140;
+  private runSpeedMultiplier = 2;
+  private running = false;
   private correctionRate = 8;
   private snapDistance = 96;
+  private remotePaths = new Map<string, RemotePath>();
+  private serverTick = 0;
 
   constructor() {
     super("MainScene");
@@ -47,8 +101,43 @@ export class MainScene extends Scene {
   }
 
   create() {
+    this.applyMap(this.getSharedMap("shared-map"));
+    this.playerSize = this.tileSize * 0.6;
+
+    this.setupNetwork();
+
+    this.input.on("pointerdown", (pointer: Phaser.Input.Pointer) => {
+      const worldPoint = this.cameras.main.getWorldPoint(pointer.x, pointer.y);
+      const object = this.objectAt(worldPoint.x, worldPoint.y);
+      if (object && this.network) {
+        this.localPath = [];
+        this.localPathIndex = 0;
+        this.network.sendInteract(object.id, objectActions[object.type ?? ""] ?? "use");
+        return;
+      }
+      this.queuePathTo(worldPoint.x, worldPoint.y);
+    });
+
+    this.input.keyboard?.on("keydown-R", () => {
+      this.network?.sendRunToggle(!this.running);
+    });
+
+    this.events.once("shutdown", () => {
+      this.isShuttingDown = true;
+      this.network?.disconnect();
+      this.playerSprites.clear();
+      this.playerTargets.clear();
+      this.remotePaths.clear();
+      this.objects.clear();
+      this.localPath = [];
+      this.localPathIndex = 0;
+      this.localPredicted = null;
+      this.localServerPos = null;
+    });
+  }
+
+  private applyMap(mapSource: SharedMap) {
     const tileSize = 32;
-    const mapSource = this.getSharedMap();
     const mapWidth = mapSource.width;
     const mapHeight = mapSource.height;
     const tilesKey = "basic-tiles";
@@ -59,6 +148,18 @@ export class MainScene extends Scene {
     this.mapWidth = mapWidth;
     this.mapHeight = mapHeight;
     this.mapData = mapSource.tiles;
+    this.diagonal = mapSource.diagonal === true;
+    this.tileTypes = new Map(
+      (mapSource.tileTypes?.length ? mapSource.tileTypes : defaultTileTypes).map(
+        (tileType) => [tileType.id, tileType],
+      ),
+    );
+    this.minMoveCost = Math.min(
+      1,
+      ...[...this.tileTypes.values()]
+        .filter((tileType) => tileType.walkable)
+        .map((tileType) => tileType.moveCost || 1),
+    );
 
     const map = this.make.tilemap({
       data: this.mapData,
@@ -74,28 +175,11 @@ export class MainScene extends Scene {
       0,
       0,
     );
-    map.createLayer(0, tileset, 0, 0);
+    this.mapLayer?.destroy();
+    this.mapLayer = map.createLayer(0, tileset, 0, 0);
+    this.mapLayer?.setDepth(-1);
 
     this.cameras.main.setBounds(0, 0, map.widthInPixels, map.heightInPixels);
-    this.playerSize = tileSize * 0.6;
-
-    this.setupNetwork();
-
-    this.input.on("pointerdown", (pointer: Phaser.Input.Pointer) => {
-      const worldPoint = this.cameras.main.getWorldPoint(pointer.x, pointer.y);
-      this.queuePathTo(worldPoint.x, worldPoint.y);
-    });
-
-    this.events.once("shutdown", () => {
-      this.isShuttingDown = true;
-      this.network?.disconnect();
-      this.playerSprites.clear();
-      this.playerTargets.clear();
-      this.localPath = [];
-      this.localPathIndex = 0;
-      this.localPredicted = null;
-      this.localServerPos = null;
-    });
   }
 
   update(_time: number, delta: number) {
@@ -130,6 +214,29 @@ export class MainScene extends Scene {
       onStateDelta: (delta) => {
         this.applyDelta(delta);
       },
+      onObjectUpdate: (update) => {
+        this.applyObjectUpdate(update);
+      },
+      onMapChange: (change) => {
+        this.handleMapChange(change);
+      },
+      onMapReload: (reload) => {
+        this.handleMapReload(reload);
+      },
+      onTileChange: (change) => {
+        this.applyTileChange(change);
+      },
+      onMovement: (movement) => {
+        this.applyMovement(movement);
+      },
+      onRegion: (region) => {
+        const store = gameStoreApi.getState();
+        if (region.entered) {
+          store.enterRegion(region.name, region.flags ?? []);
+        } else {
+          store.leaveRegion(region.name);
+        }
+      },
     });
 
     const wsUrl = this.getWebSocketUrl();
@@ -151,31 +258,91 @@ export class MainScene extends Scene {
     if (sprite) {
       sprite.setFillStyle(0xff6b6b);
     }
+    if (welcome.map && welcome.map !== this.mapName) {
+      this.loadMap(welcome.map);
+    }
 
     this.followPlayerIfReady();
   }
 
+  private handleMapChange(change: MapChange) {
+    if (this.isShuttingDown || !this.sys.isActive()) {
+      return;
+    }
+
+    for (const sprite of this.playerSprites.values()) {
+      sprite.destroy();
+    }
+    this.playerSprites.clear();
+    this.playerTargets.clear();
+    this.remotePaths.clear();
+    this.objects.clear();
+    gameStoreApi.getState().clearRegions();
+    this.cameras.main.stopFollow();
+    this.resetLocalState();
+    this.loadMap(change.map);
+  }
+
+  // A reloaded map keeps its name, so drop the cached copy before refetching
+  // it. The snapshot that follows rebuilds every entity.
+  private handleMapReload(reload: MapReload) {
+    this.cache.json.remove(`map:${reload.map}`);
+    this.handleMapChange({ map: reload.map });
+  }
+
+  // The tiles are shared with the cached map, so a later reload of the same
+  // map from the cache sees the change too.
+  private applyTileChange(change: TileChange) {
+    if (change.map !== this.mapName || !this.mapData[change.y]) {
+      return;
+    }
+
+    this.mapData[change.y][change.x] = change.tile;
+    this.mapLayer?.putTileAt(change.tile, change.x, change.y);
+  }
+
+  private loadMap(name: string) {
+    this.mapName = name;
+    const key = `map:${name}`;
+    if (this.cache.json.exists(key)) {
+      this.applyMap(this.getSharedMap(key));
+      return;
+    }
+
+    this.load.json(key, this.getMapUrl(name));
+    this.load.once("complete", () => {
+      if (this.isShuttingDown || this.mapName !== name) {
+        return;
+      }
+      this.applyMap(this.getSharedMap(key));
+    });
+    this.load.start();
+  }
+
   private applySnapshot(snapshot: StateSnapshot) {
     if (this.isShuttingDown || !this.sys.isActive()) {
       return;
     }
 
-    const players = snapshot.players;
-    const seen = new Set(players.map((player) => player.id));
+    this.serverTick = snapshot.tick;
+    const entities = snapshot.entities;
+    const seen = new Set(entities.map((entity) => entity.id));
 
     for (const [id, sprite] of this.playerSprites) {
       if (!seen.has(id)) {
         sprite.destroy();
         this.playerSprites.delete(id);
         this.playerTargets.delete(id);
+        this.remotePaths.delete(id);
+        this.objects.delete(id);
         if (id === this.localPlayerId) {
           this.resetLocalState();
         }
       }
     }
 
-    for (const player of players) {
-      this.upsertPlayer(player);
+    for (const entity of entities) {
+      this.upsertEntity(entity);
     }
 
     this.followPlayerIfReady();
@@ -186,12 +353,15 @@ export class MainScene extends Scene {
       return;
     }
 
+    this.serverTick = delta.tick;
     for (const id of delta.removed) {
       const sprite = this.playerSprites.get(id);
       if (sprite) {
         sprite.destroy();
         this.playerSprites.delete(id);
         this.playerTargets.delete(id);
+        this.remotePaths.delete(id);
+        this.objects.delete(id);
       }
 
       if (id === this.localPlayerId) {
@@ -199,8 +369,8 @@ export class MainScene extends Scene {
       }
     }
 
-    for (const player of delta.players) {
-      this.upsertPlayer(player);
+    for (const entity of delta.entities) {
+      this.upsertEntity(entity);
     }
 
     this.followPlayerIfReady();
@@ -225,7 +395,7 @@ export class MainScene extends Scene {
     this.isFollowing = true;
   }
 
-  private getMapUrl() {
+  private getMapUrl(name?: string) {
     const base = getApiBaseUrl();
     if (!base) {
       return "";
@@ -233,11 +403,14 @@ export class MainScene extends Scene {
 
     const url = new URL(base);
     url.pathname = `${url.pathname.replace(/\/$/, "")}/map`;
+    if (name) {
+      url.searchParams.set("name", name);
+    }
     return url.toString();
   }
 
-  private getSharedMap(): SharedMap {
-    const map = this.cache.json.get("shared-map") as SharedMap | undefined;
+  private getSharedMap(key: string): SharedMap {
+    const map = this.cache.json.get(key) as SharedMap | undefined;
     if (
       !map ||
       typeof map.width !== "number" ||
@@ -301,6 +474,9 @@ export class MainScene extends Scene {
       if (id === this.localPlayerId) {
         continue;
       }
+      if (this.followRemotePath(id, sprite, deltaSeconds)) {
+        continue;
+      }
       const target = this.playerTargets.get(id);
       if (!target) {
         continue;
@@ -323,6 +499,85 @@ export class MainScene extends Scene {
     }
   }
 
+  // Remote entities walk the path the server planned for them, so they move
+  // smoothly between state updates. The state updates only take over again
+  // when the entity drifts too far from its path.
+  private applyMovement(movement: Movement) {
+    if (this.isShuttingDown || movement.id === this.localPlayerId) {
+      return;
+    }
+    if (movement.cancelled || !movement.waypoints || movement.waypoints.length < 2) {
+      this.remotePaths.delete(movement.id);
+      return;
+    }
+
+    const path: RemotePath = {
+      points: movement.waypoints.map((point) => ({
+        x: this.fromNetworkPosition(point.x),
+        y: this.fromNetworkPosition(point.y),
+      })),
+      index: 1,
+      speed: this.fromNetworkPosition(movement.speed),
+    };
+
+    // Skip the waypoints the entity already passed since the path started.
+    const elapsedTicks = Math.max(0, this.serverTick - movement.startTick + 1);
+    let travelled = path.speed * elapsedTicks * serverTickSeconds;
+    while (path.index < path.points.length - 1) {
+      const from = path.points[path.index - 1];
+      const to = path.points[path.index];
+      const length = Math.hypot(to.x - from.x, to.y - from.y);
+      if (travelled < length) {
+        break;
+      }
+      travelled -= length;
+      path.index += 1;
+    }
+
+    this.remotePaths.set(movement.id, path);
+  }
+
+  private followRemotePath(
+    id: string,
+    sprite: Phaser.GameObjects.Rectangle,
+    deltaSeconds: number,
+  ) {
+    const path = this.remotePaths.get(id);
+    if (!path) {
+      return false;
+    }
+
+    const server = this.playerTargets.get(id);
+    if (server && Math.hypot(server.x - sprite.x, server.y - sprite.y) > this.snapDistance) {
+      this.remotePaths.delete(id);
+      return false;
+    }
+
+    let step = path.speed * deltaSeconds;
+    while (step > 0 && path.index < path.points.length) {
+      const target = path.points[path.index];
+      const dx = target.x - sprite.x;
+      const dy = target.y - sprite.y;
+      const distance = Math.hypot(dx, dy);
+      if (distance <= step) {
+        sprite.setPosition(target.x, target.y);
+        step -= distance;
+        path.index += 1;
+        continue;
+      }
+
+      sprite.x += (dx / distance) * step;
+      sprite.y += (dy / distance) * step;
+      step = 0;
+    }
+
+    if (path.index >= path.points.length) {
+      this.remotePaths.delete(id);
+    }
+
+    return true;
+  }
+
   private queuePathTo(worldX: number, worldY: number) {
     if (!this.network) {
       return;
@@ -389,7 +644,10 @@ export class MainScene extends Scene {
       const dx = target.x - this.localPredicted.x;
       const dy = target.y - this.localPredicted.y;
       const distance = Math.hypot(dx, dy);
-      const step = this.clientMoveSpeed * deltaSeconds;
+      const speed = this.running
+        ? this.clientMoveSpeed * this.runSpeedMultiplier
+        : this.clientMoveSpeed;
+      const step = speed * deltaSeconds;
 
       if (distance <= step) {
         this.localPredicted.x = target.x;
@@ -429,18 +687,20 @@ export class MainScene extends Scene {
     this.localPredicted.y += dy * correction;
   }
 
-  private upsertPlayer(player: PlayerState) {
-    const isLocal = player.id === this.localPlayerId;
-    let sprite = this.playerSprites.get(player.id);
-    const worldX = this.fromNetworkPosition(player.x);
-    const worldY = this.fromNetworkPosition(player.y);
+  private upsertEntity(entity: EntityState) {
+    const isLocal = entity.id === this.localPlayerId;
+    let sprite = this.playerSprites.get(entity.id);
+    const worldX = this.fromNetworkPosition(entity.x);
+    const worldY = this.fromNetworkPosition(entity.y);
 
-    this.playerTargets.set(player.id, { x: worldX, y: worldY });
+    this.playerTargets.set(entity.id, { x: worldX, y: worldY });
     if (isLocal) {
       this.localServerPos = { x: worldX, y: worldY };
       if (!this.localPredicted) {
         this.localPredicted = { x: worldX, y: worldY };
       }
+      this.running = entity.running === true;
+      gameStoreApi.getState().updateRun(this.running, entity.runEnergy ?? 0);
     }
 
     if (!sprite) {
@@ -449,15 +709,47 @@ export class MainScene extends Scene {
         worldY,
         this.playerSize,
         this.playerSize,
-        isLocal ? 0xff6b6b : 0x4d96ff,
+        isLocal ? 0xff6b6b : (entityColors[entity.kind] ?? entityColors.player),
       );
-      this.playerSprites.set(player.id, sprite);
+      this.playerSprites.set(entity.id, sprite);
       if (isLocal && this.localPredicted) {
         sprite.setPosition(this.localPredicted.x, this.localPredicted.y);
       }
     } else if (isLocal) {
       sprite.setFillStyle(0xff6b6b);
     }
+
+    if (entity.kind === "object") {
+      this.objects.set(entity.id, entity);
+      sprite.setAlpha(entity.state === "depleted" ? 0.35 : 1);
+    }
+  }
+
+  private applyObjectUpdate(update: ObjectUpdate) {
+    if (this.isShuttingDown || !this.sys.isActive()) {
+      return;
+    }
+
+    const object = this.objects.get(update.id);
+    if (object) {
+      object.state = update.state;
+    }
+    this.playerSprites.get(update.id)?.setAlpha(update.state === "depleted" ? 0.35 : 1);
+  }
+
+  private objectAt(worldX: number, worldY: number) {
+    const grid = this.worldToGrid(worldX, worldY);
+    for (const object of this.objects.values()) {
+      const objectGrid = this.worldToGrid(
+        this.fromNetworkPosition(object.x),
+        this.fromNetworkPosition(object.y),
+      );
+      if (objectGrid.x === grid.x && objectGrid.y === grid.y) {
+        return object;
+      }
+    }
+
+    return null;
   }
 
   private resetLocalState() {
@@ -487,7 +779,11 @@ export class MainScene extends Scene {
       return false;
     }
 
-    return this.mapData[y][x] !== 2;
+    return this.tileTypes.get(this.mapData[y][x])?.walkable === true;
+  }
+
+  private tileCost(x: number, y: number) {
+    return this.tileTypes.get(this.mapData[y][x])?.moveCost || 1;
   }
 
   private findPath(
@@ -534,14 +830,25 @@ export class MainScene extends Scene {
         { x: current.x, y: current.y + 1 },
         { x: current.x, y: current.y - 1 },
       ];
+      if (this.diagonal) {
+        neighbors.push(
+          { x: current.x + 1, y: current.y + 1 },
+          { x: current.x + 1, y: current.y - 1 },
+          { x: current.x - 1, y: current.y + 1 },
+          { x: current.x - 1, y: current.y - 1 },
+        );
+      }
 
       for (const neighbor of neighbors) {
-        if (!this.isWalkable(neighbor.x, neighbor.y)) {
+        if (!this.canStep(current, neighbor)) {
           continue;
         }
 
         const neighborKey = `${neighbor.x},${neighbor.y}`;
-        const tentativeG = (gScore.get(currentKey) ?? 0) + 1;
+        const isDiagonal = neighbor.x !== current.x && neighbor.y !== current.y;
+        const tentativeG =
+          (gScore.get(currentKey) ?? 0) +
+          (isDiagonal ? 14 : 10) * this.tileCost(neighbor.x, neighbor.y);
         const existingG = gScore.get(neighborKey);
         if (existingG !== undefined && tentativeG >= existingG) {
           continue;
@@ -574,14 +881,43 @@ export class MainScene extends Scene {
     return path.reverse();
   }
 
+  private canStep(
+    from: { x: number; y: number },
+    to: { x: number; y: number },
+  ) {
+    if (!this.isWalkable(to.x, to.y)) {
+      return false;
+    }
+
+    const dx = to.x - from.x;
+    const dy = to.y - from.y;
+    if (dx === 0 || dy === 0) {
+      return true;
+    }
+
+    return (
+      this.diagonal &&
+      this.isWalkable(from.x + dx, from.y) &&
+      this.isWalkable(from.x, from.y + dy)
+    );
+  }
+
   private heuristic(a: { x: number; y: number }, b: { x: number; y: number }) {
-    return Math.abs(a.x - b.x) + Math.abs(a.y - b.y);
+    const dx = Math.abs(a.x - b.x);
+    const dy = Math.abs(a.y - b.y);
+    if (!this.diagonal) {
+      return 10 * (dx + dy) * this.minMoveCost;
+    }
+
+    return (
+      (10 * Math.max(dx, dy) + 4 * Math.min(dx, dy)) * this.minMoveCost
+    );
   }
 
   private ensureTilesetTexture(key: string, tileSize: number) {
     if (this.textures.exists(key)) return;
 
-    const texture = this.textures.createCanvas(key, tileSize * 3, tileSize);
+    const texture = this.textures.createCanvas(key, tileSize * 5, tileSize);
     const ctx = texture.getContext();
 
     ctx.fillStyle = "#2f6f3e";
@@ -593,6 +929,13 @@ export class MainScene extends Scene {
     ctx.fillStyle = "#2b5a8b";
     ctx.fillRect(tileSize * 2, 0, tileSize, tileSize);
 
+    // Water and forest, used by generated maps.
+    ctx.fillStyle = "#3d8fd1";
+    ctx.fillRect(tileSize * 3, 0, tileSize, tileSize);
+
+    ctx.fillStyle = "#1d4a2a";
+    ctx.fillRect(tileSize * 4, 0, tileSize, tileSize);
+
     texture.refresh();
   }
 
@@ -5,12 +5,19 @@ interface GameState {
   playerId: string | null;
   hp: number;
   maxHp: number;
+  running: boolean;
+  runEnergy: number;
+  regions: { name: string; flags: string[] }[];
   inventoryOpen: boolean;
 
   // Actions
   setConnected: (status: boolean) => void;
   setPlayerId: (id: string | null) => void;
   updateHp: (current: number, max: number) => void;
+  updateRun: (running: boolean, energy: number) => void;
+  enterRegion: (name: string, flags: string[]) => void;
+  leaveRegion: (name: string) => void;
+  clearRegions: () => void;
   toggleInventory: () => void;
 }
 
@@ -19,11 +26,27 @@ export const useGameStore = create<GameState>((set) => ({
   playerId: null,
   hp: 10,
   maxHp: 10,
+  running: false,
+  runEnergy: 100,
+  regions: [],
   inventoryOpen: false,
 
   setConnected: (status) => set({ isConnected: status }),
   setPlayerId: (id) => set({ playerId: id }),
   updateHp: (current, max) => set({ hp: current, maxHp: max }),
+  updateRun: (running, energy) => set({ running, runEnergy: energy }),
+  enterRegion: (name, flags) =>
+    set((state) => ({
+      regions: [
+        ...state.regions.filter((region) => region.name !== name),
+        { name, flags },
+      ],
+    })),
+  leaveRegion: (name) =>
+    set((state) => ({
+      regions: state.regions.filter((region) => region.name !== name),
+    })),
+  clearRegions: () => set({ regions: [] }),
   toggleInventory: () =>
     set((state) => ({ inventoryOpen: !state.inventoryOpen })),
 }));